@@ -26,7 +26,7 @@ func main() {
 	// init logger
 	logger, err := zap.NewProduction()
 	if err != nil {
-		log.Fatalf("fail to initialize logger: %w", err)
+		log.Fatalf("fail to initialize logger: %v", err)
 	}
 	defer logger.Sync()
 
@@ -108,24 +108,83 @@ func main() {
 	mediaRepo := models.NewMediaRepository(database.Pool)
 	activityRepo := activitypub.NewActivityPubRepository(database.Pool)
 	followerRepo := activitypub.NewFollowerRepository(database.Pool)
+	followingRepo := activitypub.NewFollowingRepository(database.Pool)
+	followRequestRepo := activitypub.NewFollowRequestRepository(database.Pool)
+	deliveryRepo := activitypub.NewDeliveryRepository(database.Pool)
+	remoteActorRepo := models.NewRemoteActorRepository(database.Pool)
+	likeRepo := models.NewLikeRepository(database.Pool)
+	shareRepo := models.NewShareRepository(database.Pool)
+	domainBlockRepo := models.NewDomainBlockRepository(database.Pool)
+	blockRepo := models.NewBlockRepository(database.Pool)
+	notificationRepo := models.NewNotificationRepository(database.Pool)
 
 	// init services
 	actorService := activitypub.NewActorService(userRepo)
 	userService := services.NewUserService(userRepo, actorService)
-	checkinService := services.NewCheckinService(checkinRepo, mediaRepo, storageService)
 	mediaService := services.NewMediaService(mediaRepo, storageService)
 
 	// init ActivityPub services
 	apClientService := activitypub.NewActivityPubClientService(nil)
+
+	// start remote actor cache, it fetches expired actor documents again in background
+	actorCache := activitypub.NewRemoteActorCache(remoteActorRepo, domainBlockRepo, apClientService, activitypub.RemoteActorCacheConfig{
+		TTL:              cfg.ActorCache.TTL,
+		RefreshInterval:  cfg.ActorCache.RefreshInterval,
+		RefreshBatchSize: cfg.ActorCache.RefreshBatchSize,
+	}, logger)
+	actorCache.Start()
+
 	apServerService := activitypub.NewActivityPubServerService(
 		activityRepo,
 		followerRepo,
+		followingRepo,
+		followRequestRepo,
+		deliveryRepo,
 		userRepo,
 		checkinRepo,
+		remoteActorRepo,
+		actorCache,
+		likeRepo,
+		shareRepo,
+		domainBlockRepo,
+		blockRepo,
+		notificationRepo,
 		actorService,
 		apClientService,
 		cfg.Server.Host,
+		cfg.GetServerBaseURL(),
 	)
+	signatureVerifier := activitypub.NewSignatureVerifier(actorCache)
+
+	// checkin service resolves mentions with ActivityPub server service
+	checkinService := services.NewCheckinService(checkinRepo, mediaRepo, storageService, apServerService)
+
+	// start inbox worker, it processes activities stored by inbox handlers
+	inboxWorker := activitypub.NewInboxWorker(activityRepo, apServerService, activitypub.InboxConfig{
+		Workers:      cfg.Inbox.Workers,
+		PollInterval: cfg.Inbox.PollInterval,
+		BaseBackoff:  cfg.Inbox.BaseBackoff,
+		MaxBackoff:   cfg.Inbox.MaxBackoff,
+		MaxAttempts:  cfg.Inbox.MaxAttempts,
+	}, logger)
+	inboxWorker.Start()
+
+	// start delivery worker, it posts queued activities to remote inboxes
+	deliveryWorker := activitypub.NewDeliveryWorker(deliveryRepo, userRepo, domainBlockRepo, apClientService, activitypub.DeliveryConfig{
+		Workers:      cfg.Delivery.Workers,
+		PollInterval: cfg.Delivery.PollInterval,
+		BaseBackoff:  cfg.Delivery.BaseBackoff,
+		MaxBackoff:   cfg.Delivery.MaxBackoff,
+		RetryHorizon: cfg.Delivery.RetryHorizon,
+	}, logger)
+	deliveryWorker.Start()
+	followService := services.NewFollowService(userRepo, followingRepo, deliveryRepo, apClientService, cfg.GetServerBaseURL())
+	likeService := services.NewLikeService(userRepo, checkinRepo, likeRepo, remoteActorRepo, deliveryRepo, cfg.GetServerBaseURL())
+	shareService := services.NewShareService(userRepo, checkinRepo, shareRepo, remoteActorRepo, apServerService, cfg.GetServerBaseURL())
+	moveService := services.NewMoveService(userRepo, apClientService, apServerService, cfg.GetServerBaseURL())
+	domainBlockService := services.NewDomainBlockService(userRepo, domainBlockRepo, apServerService, cfg.Server.Host)
+	blockService := services.NewBlockService(userRepo, blockRepo, apClientService, actorCache, apServerService, cfg.GetServerBaseURL())
+	notificationService := services.NewNotificationService(notificationRepo)
 
 	// init JWT auth
 	tokenAuth := jwtauth.New("HS256", []byte(cfg.JWT.Secret), nil)
@@ -136,10 +195,19 @@ func main() {
 		userService,
 		checkinService,
 		mediaService,
+		followService,
+		likeService,
+		shareService,
+		moveService,
+		domainBlockService,
+		blockService,
+		notificationService,
 		apServerService,
 		actorService,
+		signatureVerifier,
 		tokenAuth,
 		cfg.Server.Host,
+		cfg.GetServerBaseURL(),
 	)
 
 	// create HTTP server
@@ -183,5 +251,22 @@ func main() {
 		logger.Fatal("server force to shutdown", zap.Error(err))
 	}
 
+	// no more activity is received, wait for activities in flight, they may queue deliveries
+	err = inboxWorker.Shutdown(ctx)
+	if err != nil {
+		logger.Error("inbox worker force to shutdown", zap.Error(err))
+	}
+
+	// no more activity is queued, wait for deliveries in flight
+	err = deliveryWorker.Shutdown(ctx)
+	if err != nil {
+		logger.Error("delivery worker force to shutdown", zap.Error(err))
+	}
+
+	err = actorCache.Shutdown(ctx)
+	if err != nil {
+		logger.Error("remote actor cache force to shutdown", zap.Error(err))
+	}
+
 	logger.Info("server is shut down!")
 }
@@ -9,13 +9,15 @@ import (
 	"fmt"
 	"je-suis-ici-activitypub/internal/db/models"
 	"net/url"
+
+	"github.com/google/uuid"
 )
 
 type ActorService interface {
 	GenerateKeyPair() (string, string, error)
-	GenerateActorID(serverHost, username string) string
-	CreateActor(ctx context.Context, user *models.User, serverHost string) error
-	GetActor(ctx context.Context, user *models.User, serverHost string) (*Person, error)
+	GenerateActorID(baseURL string, userID uuid.UUID) string
+	CreateActor(ctx context.Context, user *models.User, baseURL string) error
+	GetActor(ctx context.Context, user *models.User, baseURL string) (*Person, error)
 }
 
 type ActorServiceImplement struct {
@@ -48,8 +50,9 @@ func (as *ActorServiceImplement) GenerateKeyPair() (string, string, error) {
 	if err != nil {
 		return "", "", fmt.Errorf("fail to marshal public key: %w", err)
 	}
+	// PKIX encoded key uses "PUBLIC KEY" block type, remote servers reject "RSA PUBLIC KEY" with PKIX bytes
 	publicKeyPEM := pem.EncodeToMemory(&pem.Block{
-		Type:  "RSA PUBLIC KEY",
+		Type:  "PUBLIC KEY",
 		Bytes: publicKeyBytes,
 	})
 
@@ -57,29 +60,31 @@ func (as *ActorServiceImplement) GenerateKeyPair() (string, string, error) {
 
 }
 
-func (as *ActorServiceImplement) GenerateActorID(serverHost, username string) string {
-	// encode username to make sure username is safe
-	safeUsername := url.PathEscape(username)
-
-	actorId := fmt.Sprintf("http://%s/users/%s", serverHost, safeUsername)
+// GenerateActorID generate actor ID from user ID, it stays the same when username changes
+// baseURL is scheme and host of this server, e.g. "https://ici.example"
+func (as *ActorServiceImplement) GenerateActorID(baseURL string, userID uuid.UUID) string {
+	return fmt.Sprintf("%s/users/%s", baseURL, userID)
+}
 
-	return actorId
+// ProfileURL get URL of user's profile page, it's based on current username
+func ProfileURL(baseURL, username string) string {
+	return fmt.Sprintf("%s/users/%s", baseURL, url.PathEscape(username))
 }
 
 // CreateActor create user's ActivityPub Actor
-func (as *ActorServiceImplement) CreateActor(ctx context.Context, user *models.User, serverHost string) error {
+func (as *ActorServiceImplement) CreateActor(ctx context.Context, user *models.User, baseURL string) error {
 	// generate private and public key pair
 	privateKey, publicKey, err := as.GenerateKeyPair()
 	if err != nil {
 		return fmt.Errorf("fail to generate private and public key pair: %w", err)
 	}
 
-	// encode username to make sure username is safe
-	safeUsername := url.PathEscape(user.Username)
-
 	// set actor id
+	if user.ID == uuid.Nil {
+		user.ID = uuid.New()
+	}
 	if user.ActorID == "" {
-		user.ActorID = fmt.Sprintf("http://%s/users/%s", serverHost, safeUsername)
+		user.ActorID = as.GenerateActorID(baseURL, user.ID)
 	}
 
 	user.PrivateKey = privateKey
@@ -93,9 +98,12 @@ func (as *ActorServiceImplement) CreateActor(ctx context.Context, user *models.U
 	return nil
 }
 
-// GetActor
-func (as *ActorServiceImplement) GetActor(ctx context.Context, user *models.User, serverHost string) (*Person, error) {
-	actorID := fmt.Sprintf("http://%s/user/%s", serverHost, user.Username)
+// GetActor build user's ActivityPub Person document
+func (as *ActorServiceImplement) GetActor(ctx context.Context, user *models.User, baseURL string) (*Person, error) {
+	actorID := user.ActorID
+	if actorID == "" {
+		actorID = as.GenerateActorID(baseURL, user.ID)
+	}
 
 	actor := &Person{
 		Context:           DefaultContext(),
@@ -107,26 +115,52 @@ func (as *ActorServiceImplement) GetActor(ctx context.Context, user *models.User
 		Outbox:            fmt.Sprintf("%s/outbox", actorID),
 		Following:         fmt.Sprintf("%s/following", actorID),
 		Followers:         fmt.Sprintf("%s/followers", actorID),
-		Liked:             fmt.Sprintf("%s/linked", actorID),
-		URL:               actorID,
-		Published:         user.CreatedAt,
-		Updated:           user.UpdatedAt,
+		Liked:             fmt.Sprintf("%s/liked", actorID),
+		URL:               IRIProperty(ProfileURL(baseURL, user.Username)),
+		AlsoKnownAs:       user.AlsoKnownAs,
+		MovedTo:           user.MovedTo,
+		Endpoints: &Endpoints{
+			SharedInbox: fmt.Sprintf("%s/inbox", baseURL),
+		},
+		Published:                 user.CreatedAt,
+		Updated:                   user.UpdatedAt,
+		ManuallyApprovesFollowers: user.Locked,
 	}
 
 	if user.AvatarURL != "" {
-		actor.Icon = &Image{
+		actor.Icon = ObjectProperty(&Image{
 			Type: "Image",
-			URL:  user.AvatarURL,
-		}
+			URL:  IRIProperty(user.AvatarURL),
+		})
 	}
 
 	if user.PublicKey != "" {
 		actor.PublicKey = PublicKey{
 			ID:           fmt.Sprintf("%s#main-key", actorID),
 			Owner:        actorID,
-			PublicKeyPem: user.PublicKey,
+			PublicKeyPem: normalizePublicKeyPEM(user.PublicKey),
 		}
 	}
 
 	return actor, nil
 }
+
+// normalizePublicKeyPEM rewrite keys generated with "RSA PUBLIC KEY" block type around PKIX bytes
+// to the standard "PUBLIC KEY" block type
+func normalizePublicKeyPEM(publicKeyPEM string) string {
+	block, _ := pem.Decode([]byte(publicKeyPEM))
+	if block == nil || block.Type != "RSA PUBLIC KEY" {
+		return publicKeyPEM
+	}
+
+	// PKCS#1 encoded key is valid with "RSA PUBLIC KEY" block type
+	_, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return publicKeyPEM
+	}
+
+	return string(pem.EncodeToMemory(&pem.Block{
+		Type:  "PUBLIC KEY",
+		Bytes: block.Bytes,
+	}))
+}
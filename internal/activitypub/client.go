@@ -3,26 +3,24 @@ package activitypub
 import (
 	"bytes"
 	"context"
-	"crypto"
-	"crypto/rand"
-	"crypto/rsa"
-	"crypto/sha256"
-	"crypto/x509"
-	"encoding/base64"
 	"encoding/json"
-	"encoding/pem"
 	"fmt"
 	"je-suis-ici-activitypub/internal/db/models"
 	"net/http"
+	"net/url"
+	"strings"
 	"time"
 )
 
 // ActivityPubClientService interact with other activitypub servers
 type ActivityPubClientService interface {
 	FetchActorPublicInformation(ctx context.Context, actorURL string) (*Person, error)
+	FetchObject(ctx context.Context, objectURL string) (*Object, error)
 	SendActivityToTargetInbox(ctx context.Context, activity *Activity, user *models.User, targetInbox string) error
+	SendActivityJSONToTargetInbox(ctx context.Context, activityJSON []byte, user *models.User, targetInbox string) error
 	GetActorInbox(ctx context.Context, actorURL string) (string, error)
 	GetActorFollowers(ctx context.Context, followersURL string) ([]string, error)
+	ResolveAccount(ctx context.Context, account string) (*Person, error)
 }
 
 // HTTPClient send http request and return http response
@@ -31,7 +29,8 @@ type HTTPClient interface {
 }
 
 type ActivityPubClientServiceImplement struct {
-	httpClient HTTPClient
+	httpClient       HTTPClient
+	signatureSchemes *HostSignatureSchemes
 }
 
 func NewActivityPubClientService(httpClient HTTPClient) ActivityPubClientService {
@@ -42,7 +41,8 @@ func NewActivityPubClientService(httpClient HTTPClient) ActivityPubClientService
 	}
 
 	return &ActivityPubClientServiceImplement{
-		httpClient: httpClient,
+		httpClient:       httpClient,
+		signatureSchemes: NewHostSignatureSchemes(SignatureSchemeCavage),
 	}
 }
 
@@ -78,9 +78,52 @@ func (ac *ActivityPubClientServiceImplement) FetchActorPublicInformation(ctx con
 		return nil, fmt.Errorf("fail to decode actor public information: %w", err)
 	}
 
+	// a server can only serve its own actors
+	if person.ID != "" && !isSameHost(person.ID, actorURL) {
+		return nil, fmt.Errorf("actor document fetched at %s has id %s", actorURL, person.ID)
+	}
+
 	return &person, nil
 }
 
+// FetchObject get object, like a Note, from its origin server
+func (ac *ActivityPubClientServiceImplement) FetchObject(ctx context.Context, objectURL string) (*Object, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, objectURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("fail to create http request: %w", err)
+	}
+
+	req.Header.Set("Accept", "application/activity+json")
+	req.Header.Set("User-Agent", "je-suis-ici-activitypub")
+
+	resp, err := ac.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fail to send http request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("receive error status: %d", resp.StatusCode)
+	}
+
+	var object Object
+	err = json.NewDecoder(resp.Body).Decode(&object)
+	if err != nil {
+		return nil, fmt.Errorf("fail to decode object: %w", err)
+	}
+
+	return &object, nil
+}
+
+// InboxStatusError remote inbox answered with a non 2xx status
+type InboxStatusError struct {
+	StatusCode int
+}
+
+func (e *InboxStatusError) Error() string {
+	return fmt.Sprintf("receiver error status: %d", e.StatusCode)
+}
+
 // SendActivityToTargetInbox
 func (ac *ActivityPubClientServiceImplement) SendActivityToTargetInbox(ctx context.Context, activity *Activity, user *models.User, targetInbox string) error {
 	// parse activity to json
@@ -89,10 +132,53 @@ func (ac *ActivityPubClientServiceImplement) SendActivityToTargetInbox(ctx conte
 		return fmt.Errorf("fail to parse activity to json: %w", err)
 	}
 
+	return ac.SendActivityJSONToTargetInbox(ctx, activityJSON, user, targetInbox)
+}
+
+// SendActivityJSONToTargetInbox post serialized activity to target inbox
+// request is signed with the signature scheme the target host accepts
+// when the host rejects the signature, it's sent again once with another scheme
+func (ac *ActivityPubClientServiceImplement) SendActivityJSONToTargetInbox(ctx context.Context, activityJSON []byte, user *models.User, targetInbox string) error {
+	inboxURL, err := url.Parse(targetInbox)
+	if err != nil {
+		return fmt.Errorf("fail to parse target inbox: %w", err)
+	}
+
+	scheme := ac.signatureSchemes.Get(inboxURL.Host)
+
+	statusCode, err := ac.postActivity(ctx, activityJSON, user, targetInbox, scheme)
+	if err != nil {
+		return err
+	}
+
+	// retry with another signature scheme, and remember it if the host accepts it
+	if statusCode == http.StatusUnauthorized && user.PrivateKey != "" {
+		scheme = alternativeSignatureScheme(scheme)
+
+		statusCode, err = ac.postActivity(ctx, activityJSON, user, targetInbox, scheme)
+		if err != nil {
+			return err
+		}
+
+		if statusCode >= 200 && statusCode < 300 {
+			ac.signatureSchemes.Set(inboxURL.Host, scheme)
+		}
+	}
+
+	// check response status
+	if statusCode < 200 || statusCode >= 300 {
+		return &InboxStatusError{StatusCode: statusCode}
+	}
+
+	return nil
+}
+
+// postActivity post activity JSON to target inbox, return response status code
+func (ac *ActivityPubClientServiceImplement) postActivity(ctx context.Context, activityJSON []byte, user *models.User, targetInbox string, scheme SignatureScheme) (int, error) {
 	// create http request
 	req, err := http.NewRequestWithContext(ctx, http.MethodPost, targetInbox, bytes.NewBuffer(activityJSON))
 	if err != nil {
-		return fmt.Errorf("fail to create http request: %w", err)
+		return 0, fmt.Errorf("fail to create http request: %w", err)
 	}
 
 	// set header
@@ -103,25 +189,95 @@ func (ac *ActivityPubClientServiceImplement) SendActivityToTargetInbox(ctx conte
 	// if the user has a private key, sign the HTTP request for authentication
 	// this is crucial for ActivityPub's security model
 	if user.PrivateKey != "" {
-		err := ac.signRequest(req, user)
+		err := ac.signRequest(req, activityJSON, user, scheme)
 		if err != nil {
-			return fmt.Errorf("fail to sign request: %w", err)
+			return 0, fmt.Errorf("fail to sign request: %w", err)
 		}
 	}
 
 	// send activity request
 	resp, err := ac.httpClient.Do(req)
 	if err != nil {
-		return fmt.Errorf("fail to send activity request: %w", err)
+		return 0, fmt.Errorf("fail to send activity request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	return resp.StatusCode, nil
+}
+
+// ResolveAccount resolve "user@host" account to its actor with WebFinger
+// actor must be on the account's host, another server can't answer for it
+// https://docs.joinmastodon.org/spec/webfinger/
+func (ac *ActivityPubClientServiceImplement) ResolveAccount(ctx context.Context, account string) (*Person, error) {
+	username, host, err := ParseAcctResource(account)
+	if err != nil {
+		return nil, err
+	}
+
+	// create http request
+	webFingerURL := fmt.Sprintf("https://%s/.well-known/webfinger?resource=%s",
+		host, url.QueryEscape(fmt.Sprintf("acct:%s@%s", username, host)))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, webFingerURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("fail to create http request: %w", err)
+	}
+
+	// set header
+	req.Header.Set("Accept", ContentTypeJRDJSON)
+	req.Header.Set("User-Agent", "je-suis-ici-activitypub")
+
+	// send request
+	resp, err := ac.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fail to send webfinger request: %w", err)
 	}
 	defer resp.Body.Close()
 
 	// check response status
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return fmt.Errorf("rceiver error status: %d", resp.StatusCode)
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("receive error status: %d", resp.StatusCode)
 	}
 
-	return nil
+	var webFinger WebFinger
+	err = json.NewDecoder(resp.Body).Decode(&webFinger)
+	if err != nil {
+		return nil, fmt.Errorf("fail to decode webfinger response: %w", err)
+	}
+
+	// find actor ID in "self" link
+	var actorID string
+	for _, link := range webFinger.Links {
+		if link.Rel != WebFingerRelSelf || link.Href == "" {
+			continue
+		}
+
+		mediaType, _, _ := strings.Cut(link.Type, ";")
+		if mediaType == ContentTypeActivityJSON || mediaType == "application/ld+json" {
+			actorID = link.Href
+			break
+		}
+	}
+
+	if actorID == "" {
+		return nil, fmt.Errorf("account %s@%s doesn't have an ActivityPub actor", username, host)
+	}
+
+	accountURL := "https://" + host
+	if !isSameHost(actorID, accountURL) {
+		return nil, fmt.Errorf("actor %s of account %s@%s isn't on the account's host", actorID, username, host)
+	}
+
+	person, err := ac.FetchActorPublicInformation(ctx, actorID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !isSameHost(person.ID, accountURL) {
+		return nil, fmt.Errorf("actor %s of account %s@%s isn't on the account's host", person.ID, username, host)
+	}
+
+	return person, nil
 }
 
 // GetActorInbox
@@ -197,56 +353,15 @@ func (ac *ActivityPubClientServiceImplement) GetActorFollowers(ctx context.Conte
 	return followers, nil
 }
 
-// signRequest sign an HTTP request using RSA cryptography
-func (ac *ActivityPubClientServiceImplement) signRequest(req *http.Request, user *models.User) error {
-	// decodes the PEM-encoded private key
-	block, _ := pem.Decode([]byte(user.PrivateKey))
-	if block == nil {
-		return fmt.Errorf("fail to decode private key")
-	}
-
-	// parse the decoded key bytes as an RSA private key
-	privateKey, err := x509.ParsePKCS1PrivateKey(block.Bytes)
-	if err != nil {
-		return fmt.Errorf("fail to parse private key: %w", err)
-	}
-
-	// extract values needed for the signature
-	method := req.Method
-	path := req.URL.Path
-	host := req.URL.Host
-	// create a formatted UTC timestamp and set it as the Date to request header
-	date := time.Now().UTC().Format(http.TimeFormat)
-	req.Header.Set("Date", date)
-
-	// create the string to be signed
-	// follow HTTP Signature specification
-	signString := fmt.Sprintf("(request-target): %s %s\nhost: %s\ndate: %s",
-		method, path, host, date)
-
-	// compute the SHA-256 hash of the string to be signed
-	h := sha256.New()
-	h.Write([]byte(signString))
-	digest := h.Sum(nil)
-
-	// sign the digest using the RSA private key with PKCS#1 v1.5 padding
-	signature, err := rsa.SignPKCS1v15(rand.Reader, privateKey, crypto.SHA256, digest)
+// signRequest sign an HTTP request and its body with user's RSA private key
+func (ac *ActivityPubClientServiceImplement) signRequest(req *http.Request, body []byte, user *models.User, scheme SignatureScheme) error {
+	privateKey, err := ParsePrivateKeyPEM(user.PrivateKey)
 	if err != nil {
-		return fmt.Errorf("fail to sign: %w", err)
+		return err
 	}
 
-	// encode the binary signature as a Base64 string
-	encodedSignature := base64.StdEncoding.EncodeToString(signature)
-
 	// create a key identifier using the user's ActivityPub Actor ID
 	keyId := fmt.Sprintf("%s#main-key", user.ActorID)
 
-	// format the HTTP Signature header with key ID, algorithm, signed headers, and the signature
-	signatureHeader := fmt.Sprintf(`keyId="%s",algorithm="rsa-sha256",headers="(request-target) host date",signature="%s"`,
-		keyId, encodedSignature)
-
-	// add the signature header to the HTTP request
-	req.Header.Set("Signature", signatureHeader)
-
-	return nil
+	return NewRequestSigner(scheme).SignRequest(req, body, keyId, privateKey)
 }
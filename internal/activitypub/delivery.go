@@ -0,0 +1,469 @@
+package activitypub
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"je-suis-ici-activitypub/internal/db/models"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+// delivery status
+const (
+	DeliveryStatusPending    = "pending"
+	DeliveryStatusDelivering = "delivering"
+	DeliveryStatusDelivered  = "delivered"
+	DeliveryStatusDead       = "dead"
+
+	// a claimed delivery not finished within the lease is claimed again, e.g. after a crash
+	deliveryLease = 5 * time.Minute
+)
+
+// Delivery activity waiting to be posted to a remote inbox
+type Delivery struct {
+	ID            uuid.UUID
+	ActivityID    string
+	SenderID      uuid.UUID
+	Inbox         string
+	Payload       []byte
+	Status        string
+	Attempts      int
+	NextAttemptAt time.Time
+	LastError     string
+	CreatedAt     time.Time
+}
+
+// DeliveryRepository persist outbound deliveries so they survive restarts
+type DeliveryRepository interface {
+	EnqueueDelivery(ctx context.Context, activityID string, senderID uuid.UUID, inbox string, payload []byte) error
+	ClaimDeliveries(ctx context.Context, limit int) ([]Delivery, error)
+	MarkDelivered(ctx context.Context, id uuid.UUID) error
+	MarkRetry(ctx context.Context, id uuid.UUID, nextAttemptAt time.Time, lastError string) error
+	MarkDead(ctx context.Context, id uuid.UUID, lastError string) error
+	ReleaseDelivery(ctx context.Context, id uuid.UUID) error
+	GetInboxesByActivityID(ctx context.Context, activityID string) ([]string, error)
+}
+
+// DeliveryRepositoryImplement
+type DeliveryRepositoryImplement struct {
+	pool *pgxpool.Pool
+}
+
+// NewDeliveryRepository
+func NewDeliveryRepository(pool *pgxpool.Pool) DeliveryRepository {
+	return &DeliveryRepositoryImplement{pool: pool}
+}
+
+// EnqueueDelivery add a pending delivery, same activity is only queued once per inbox
+func (dr *DeliveryRepositoryImplement) EnqueueDelivery(ctx context.Context, activityID string, senderID uuid.UUID, inbox string, payload []byte) error {
+	query := `
+		INSERT INTO deliveries(activity_id, sender_id, inbox, payload, status)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (activity_id, inbox) DO NOTHING
+	`
+
+	_, err := dr.pool.Exec(ctx, query, activityID, senderID, inbox, payload, DeliveryStatusPending)
+	if err != nil {
+		return fmt.Errorf("fail to enqueue delivery: %w", err)
+	}
+
+	return nil
+}
+
+// ClaimDeliveries claim due deliveries for this worker
+// rows locked by another worker are skipped, deliveries whose lease expired are claimed again
+func (dr *DeliveryRepositoryImplement) ClaimDeliveries(ctx context.Context, limit int) ([]Delivery, error) {
+	query := `
+		UPDATE deliveries
+		SET status = $1, attempts = attempts + 1, locked_until = now() + $2 * INTERVAL '1 second', updated_at = now()
+		WHERE id IN (
+			SELECT id FROM deliveries
+			WHERE (status = $3 AND next_attempt_at <= now())
+				OR (status = $1 AND locked_until < now())
+			ORDER BY next_attempt_at
+			LIMIT $4
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, activity_id, sender_id, inbox, payload, status, attempts, next_attempt_at,
+			COALESCE(last_error, ''), created_at
+	`
+
+	rows, err := dr.pool.Query(ctx, query, DeliveryStatusDelivering, int(deliveryLease.Seconds()), DeliveryStatusPending, limit)
+	if err != nil {
+		return nil, fmt.Errorf("fail to claim deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	var deliveries []Delivery
+
+	for rows.Next() {
+		var delivery Delivery
+		err := rows.Scan(
+			&delivery.ID, &delivery.ActivityID, &delivery.SenderID, &delivery.Inbox, &delivery.Payload,
+			&delivery.Status, &delivery.Attempts, &delivery.NextAttemptAt, &delivery.LastError, &delivery.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("fail to scan delivery: %w", err)
+		}
+
+		deliveries = append(deliveries, delivery)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("error on iterating delivery rows: %w", err)
+	}
+
+	return deliveries, nil
+}
+
+// MarkDelivered
+func (dr *DeliveryRepositoryImplement) MarkDelivered(ctx context.Context, id uuid.UUID) error {
+	return dr.updateStatus(ctx, id, DeliveryStatusDelivered, nil, "")
+}
+
+// MarkRetry put delivery back to queue until nextAttemptAt
+func (dr *DeliveryRepositoryImplement) MarkRetry(ctx context.Context, id uuid.UUID, nextAttemptAt time.Time, lastError string) error {
+	return dr.updateStatus(ctx, id, DeliveryStatusPending, &nextAttemptAt, lastError)
+}
+
+// MarkDead stop retrying delivery
+func (dr *DeliveryRepositoryImplement) MarkDead(ctx context.Context, id uuid.UUID, lastError string) error {
+	return dr.updateStatus(ctx, id, DeliveryStatusDead, nil, lastError)
+}
+
+// ReleaseDelivery give back a claimed delivery which wasn't attempted, e.g. on shutdown
+func (dr *DeliveryRepositoryImplement) ReleaseDelivery(ctx context.Context, id uuid.UUID) error {
+	query := `
+		UPDATE deliveries
+		SET status = $1, attempts = attempts - 1, locked_until = NULL, updated_at = now()
+		WHERE id = $2 AND status = $3
+	`
+
+	_, err := dr.pool.Exec(ctx, query, DeliveryStatusPending, id, DeliveryStatusDelivering)
+	if err != nil {
+		return fmt.Errorf("fail to release delivery: %w", err)
+	}
+
+	return nil
+}
+
+// GetInboxesByActivityID get inboxes an activity was queued for
+func (dr *DeliveryRepositoryImplement) GetInboxesByActivityID(ctx context.Context, activityID string) ([]string, error) {
+	query := `SELECT DISTINCT inbox FROM deliveries WHERE activity_id = $1`
+
+	rows, err := dr.pool.Query(ctx, query, activityID)
+	if err != nil {
+		return nil, fmt.Errorf("fail to get inboxes of activity: %w", err)
+	}
+	defer rows.Close()
+
+	var inboxes []string
+
+	for rows.Next() {
+		var inbox string
+		err := rows.Scan(&inbox)
+		if err != nil {
+			return nil, fmt.Errorf("fail to scan inbox: %w", err)
+		}
+
+		inboxes = append(inboxes, inbox)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("error on iterating inbox rows: %w", err)
+	}
+
+	return inboxes, nil
+}
+
+// updateStatus finish a claimed delivery
+func (dr *DeliveryRepositoryImplement) updateStatus(ctx context.Context, id uuid.UUID, status string, nextAttemptAt *time.Time, lastError string) error {
+	query := `
+		UPDATE deliveries
+		SET status = $1, next_attempt_at = COALESCE($2, next_attempt_at), last_error = NULLIF($3, ''),
+			locked_until = NULL, updated_at = now()
+		WHERE id = $4
+	`
+
+	_, err := dr.pool.Exec(ctx, query, status, nextAttemptAt, lastError, id)
+	if err != nil {
+		return fmt.Errorf("fail to update delivery status: %w", err)
+	}
+
+	return nil
+}
+
+// EnqueueActivity queue activity for every inbox, it's posted by DeliveryWorker
+func EnqueueActivity(ctx context.Context, deliveryRepo DeliveryRepository, activity *Activity, sender *models.User, inboxes ...string) error {
+	activityJSON, err := json.Marshal(activity)
+	if err != nil {
+		return fmt.Errorf("fail to parse activity to json: %w", err)
+	}
+
+	seen := make(map[string]bool)
+	var errs []error
+
+	for _, inbox := range inboxes {
+		if inbox == "" || seen[inbox] {
+			continue
+		}
+		seen[inbox] = true
+
+		err := deliveryRepo.EnqueueDelivery(ctx, activity.ID, sender.ID, inbox, activityJSON)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// DeliveryConfig
+type DeliveryConfig struct {
+	// Workers number of deliveries posted at the same time
+	Workers int
+	// PollInterval how often queue is checked for due deliveries
+	PollInterval time.Duration
+	// BaseBackoff wait time before the first retry, doubled on each attempt
+	BaseBackoff time.Duration
+	// MaxBackoff upper bound of wait time between attempts
+	MaxBackoff time.Duration
+	// RetryHorizon give up delivery when it can't be done within this time after it's queued
+	RetryHorizon time.Duration
+}
+
+// DeliveryWorker post queued activities to remote inboxes with a pool of workers
+type DeliveryWorker struct {
+	deliveryRepo    DeliveryRepository
+	userRepo        models.UserRepository
+	domainBlockRepo models.DomainBlockRepository
+	clientService   ActivityPubClientService
+	config          DeliveryConfig
+	logger          *zap.Logger
+
+	// stop claiming new deliveries
+	stopClaiming context.CancelFunc
+	// cancel deliveries in flight
+	cancelDeliveries context.CancelFunc
+	wg               sync.WaitGroup
+}
+
+// NewDeliveryWorker
+func NewDeliveryWorker(deliveryRepo DeliveryRepository, userRepo models.UserRepository, domainBlockRepo models.DomainBlockRepository, clientService ActivityPubClientService, config DeliveryConfig, logger *zap.Logger) *DeliveryWorker {
+	if config.Workers <= 0 {
+		config.Workers = 1
+	}
+	if config.PollInterval <= 0 {
+		config.PollInterval = time.Second
+	}
+	if config.BaseBackoff <= 0 {
+		config.BaseBackoff = 30 * time.Second
+	}
+	if config.MaxBackoff < config.BaseBackoff {
+		config.MaxBackoff = config.BaseBackoff
+	}
+
+	return &DeliveryWorker{
+		deliveryRepo:    deliveryRepo,
+		userRepo:        userRepo,
+		domainBlockRepo: domainBlockRepo,
+		clientService:   clientService,
+		config:          config,
+		logger:          logger,
+	}
+}
+
+// Start claim due deliveries in background and post them with the worker pool
+func (dw *DeliveryWorker) Start() {
+	claimCtx, stopClaiming := context.WithCancel(context.Background())
+	deliverCtx, cancelDeliveries := context.WithCancel(context.Background())
+	dw.stopClaiming = stopClaiming
+	dw.cancelDeliveries = cancelDeliveries
+
+	jobs := make(chan Delivery)
+
+	// workers
+	for i := 0; i < dw.config.Workers; i++ {
+		dw.wg.Add(1)
+		go func() {
+			defer dw.wg.Done()
+			for delivery := range jobs {
+				dw.deliver(deliverCtx, delivery)
+			}
+		}()
+	}
+
+	// poller, it closes jobs when claiming stops so workers exit after their current delivery
+	dw.wg.Add(1)
+	go func() {
+		defer dw.wg.Done()
+		defer close(jobs)
+
+		ticker := time.NewTicker(dw.config.PollInterval)
+		defer ticker.Stop()
+
+		for {
+			deliveries, err := dw.deliveryRepo.ClaimDeliveries(claimCtx, dw.config.Workers)
+			if err != nil && claimCtx.Err() == nil {
+				dw.logger.Error("fail to claim deliveries", zap.Error(err))
+			}
+
+			for i, delivery := range deliveries {
+				select {
+				case jobs <- delivery:
+				case <-claimCtx.Done():
+					// give back deliveries no worker took
+					for _, unsent := range deliveries[i:] {
+						dw.release(unsent)
+					}
+					return
+				}
+			}
+
+			// a full batch means more deliveries may be due
+			if len(deliveries) == dw.config.Workers {
+				continue
+			}
+
+			select {
+			case <-ticker.C:
+			case <-claimCtx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// Shutdown stop claiming deliveries and wait for deliveries in flight
+// when ctx is done first, deliveries in flight are canceled and put back to queue
+func (dw *DeliveryWorker) Shutdown(ctx context.Context) error {
+	if dw.stopClaiming == nil {
+		return nil
+	}
+	dw.stopClaiming()
+
+	done := make(chan struct{})
+	go func() {
+		dw.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		dw.cancelDeliveries()
+		return nil
+	case <-ctx.Done():
+		dw.cancelDeliveries()
+		<-done
+		return ctx.Err()
+	}
+}
+
+// deliver post one delivery and record result
+func (dw *DeliveryWorker) deliver(ctx context.Context, delivery Delivery) {
+	logger := dw.logger.With(
+		zap.String("activity_id", delivery.ActivityID),
+		zap.String("inbox", delivery.Inbox),
+		zap.Int("attempts", delivery.Attempts),
+	)
+
+	// nothing is delivered to suspended domains, even when it was queued before the block
+	err := checkDomainBlocked(ctx, dw.domainBlockRepo, delivery.Inbox)
+
+	var sender *models.User
+	if err == nil {
+		sender, err = dw.userRepo.GetByID(ctx, delivery.SenderID)
+	}
+	if err == nil {
+		err = dw.clientService.SendActivityJSONToTargetInbox(ctx, delivery.Payload, sender, delivery.Inbox)
+	}
+
+	// use a fresh context, deliver context may be canceled by shutdown
+	resultCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	switch {
+	case err == nil:
+		err = dw.deliveryRepo.MarkDelivered(resultCtx, delivery.ID)
+
+	case ctx.Err() != nil:
+		// canceled by shutdown, it's retried after restart
+		err = dw.deliveryRepo.ReleaseDelivery(resultCtx, delivery.ID)
+
+	case isPermanentDeliveryError(err):
+		logger.Warn("delivery failed permanently", zap.Error(err))
+		err = dw.deliveryRepo.MarkDead(resultCtx, delivery.ID, err.Error())
+
+	default:
+		nextAttemptAt := time.Now().Add(dw.backoff(delivery.Attempts))
+
+		if dw.config.RetryHorizon > 0 && nextAttemptAt.After(delivery.CreatedAt.Add(dw.config.RetryHorizon)) {
+			logger.Warn("delivery retry horizon exceeded", zap.Error(err))
+			err = dw.deliveryRepo.MarkDead(resultCtx, delivery.ID, fmt.Sprintf("retry horizon exceeded: %v", err))
+			break
+		}
+
+		logger.Info("delivery failed, retry later", zap.Time("next_attempt_at", nextAttemptAt), zap.Error(err))
+		err = dw.deliveryRepo.MarkRetry(resultCtx, delivery.ID, nextAttemptAt, err.Error())
+	}
+
+	if err != nil {
+		logger.Error("fail to record delivery result", zap.Error(err))
+	}
+}
+
+// release give back a claimed delivery which wasn't sent to a worker
+func (dw *DeliveryWorker) release(delivery Delivery) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	err := dw.deliveryRepo.ReleaseDelivery(ctx, delivery.ID)
+	if err != nil {
+		dw.logger.Error("fail to release delivery", zap.String("activity_id", delivery.ActivityID), zap.Error(err))
+	}
+}
+
+// backoff exponential wait time before next attempt with jitter
+// it's between half and full of BaseBackoff * 2^(attempts-1), capped by MaxBackoff
+func (dw *DeliveryWorker) backoff(attempts int) time.Duration {
+	backoff := dw.config.BaseBackoff
+	for i := 1; i < attempts && backoff < dw.config.MaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > dw.config.MaxBackoff {
+		backoff = dw.config.MaxBackoff
+	}
+
+	half := backoff / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// isPermanentDeliveryError check if retrying can't succeed
+// remote server rejected the request with 4xx, except timeout and rate limit
+func isPermanentDeliveryError(err error) bool {
+	if errors.Is(err, ErrDomainBlocked) {
+		return true
+	}
+
+	var statusErr *InboxStatusError
+	if !errors.As(err, &statusErr) {
+		return false
+	}
+
+	switch statusErr.StatusCode {
+	case http.StatusRequestTimeout, http.StatusTooManyRequests:
+		return false
+	}
+
+	return statusErr.StatusCode >= 400 && statusErr.StatusCode < 500
+}
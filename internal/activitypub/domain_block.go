@@ -0,0 +1,37 @@
+package activitypub
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"je-suis-ici-activitypub/internal/db/models"
+	"net/url"
+	"regexp"
+)
+
+// ErrDomainBlocked activity or delivery involves a suspended domain
+var ErrDomainBlocked = errors.New("domain is blocked")
+
+// checkDomainBlocked return ErrDomainBlocked when host of rawURL or one of its parent domains is suspended
+func checkDomainBlocked(ctx context.Context, domainBlockRepo models.DomainBlockRepository, rawURL string) error {
+	parsedURL, err := url.Parse(rawURL)
+	if err != nil || parsedURL.Host == "" {
+		return nil
+	}
+
+	block, err := domainBlockRepo.FindDomainBlock(ctx, parsedURL.Host)
+	if err != nil {
+		return err
+	}
+
+	if block != nil && block.Severity == models.DomainBlockSeveritySuspend {
+		return fmt.Errorf("%w: %s", ErrDomainBlocked, block.Domain)
+	}
+
+	return nil
+}
+
+// domainURLPattern regular expression matching URLs on domain or its subdomains
+func domainURLPattern(domain string) string {
+	return `^https?://([^/]*\.)?` + regexp.QuoteMeta(domain) + `(:[0-9]+)?(/|$)`
+}
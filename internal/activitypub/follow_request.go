@@ -0,0 +1,162 @@
+package activitypub
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ErrFollowRequestNotFound follow request doesn't exist or belongs to another user
+var ErrFollowRequestNotFound = errors.New("follow request not found")
+
+// FollowRequest Follow of a remote actor waiting for a locked user's approval
+type FollowRequest struct {
+	ID          uuid.UUID `json:"id"`
+	UserID      uuid.UUID `json:"user_id"`
+	ActorID     string    `json:"actor_id"`
+	Inbox       string    `json:"inbox"`
+	SharedInbox string    `json:"shared_inbox,omitempty"`
+	// ActivityID id of the Follow activity, Accept and Reject refer to it
+	ActivityID string    `json:"activity_id"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// FollowRequestRepository manage follow requests of locked users
+type FollowRequestRepository interface {
+	AddFollowRequest(ctx context.Context, request *FollowRequest) error
+	RemoveFollowRequest(ctx context.Context, userID uuid.UUID, actorID string) error
+	RemoveFollowRequestsByDomain(ctx context.Context, domain string) error
+	GetFollowRequest(ctx context.Context, userID, id uuid.UUID) (*FollowRequest, error)
+	GetFollowRequests(ctx context.Context, userID uuid.UUID, cursor uuid.UUID, limit int) ([]FollowRequest, error)
+}
+
+// FollowRequestRepositoryImplement
+type FollowRequestRepositoryImplement struct {
+	pool *pgxpool.Pool
+}
+
+// NewFollowRequestRepository
+func NewFollowRequestRepository(pool *pgxpool.Pool) FollowRequestRepository {
+	return &FollowRequestRepositoryImplement{pool: pool}
+}
+
+// followRequestColumns columns selected for a FollowRequest, in scanFollowRequest order
+const followRequestColumns = `id, user_id, actor_id, inbox, COALESCE(shared_inbox, ''), activity_id, created_at`
+
+// scanFollowRequest scan a row selected with followRequestColumns
+func scanFollowRequest(row pgx.Row) (*FollowRequest, error) {
+	request := &FollowRequest{}
+
+	err := row.Scan(
+		&request.ID, &request.UserID, &request.ActorID, &request.Inbox,
+		&request.SharedInbox, &request.ActivityID, &request.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return request, nil
+}
+
+// AddFollowRequest store follow request, a new Follow of the same actor replaces the pending one
+func (frr *FollowRequestRepositoryImplement) AddFollowRequest(ctx context.Context, request *FollowRequest) error {
+	query := `
+		INSERT INTO follow_requests (user_id, actor_id, inbox, shared_inbox, activity_id)
+		VALUES ($1, $2, $3, NULLIF($4, ''), $5)
+		ON CONFLICT (user_id, actor_id) DO UPDATE
+		SET inbox = excluded.inbox, shared_inbox = excluded.shared_inbox, activity_id = excluded.activity_id
+		RETURNING id, created_at
+	`
+
+	err := frr.pool.QueryRow(ctx, query,
+		request.UserID, request.ActorID, request.Inbox, request.SharedInbox, request.ActivityID,
+	).Scan(&request.ID, &request.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("fail to add follow request: %w", err)
+	}
+
+	return nil
+}
+
+// RemoveFollowRequest
+func (frr *FollowRequestRepositoryImplement) RemoveFollowRequest(ctx context.Context, userID uuid.UUID, actorID string) error {
+	query := `DELETE FROM follow_requests WHERE user_id = $1 AND actor_id = $2`
+
+	_, err := frr.pool.Exec(ctx, query, userID, actorID)
+	if err != nil {
+		return fmt.Errorf("fail to remove follow request: %w", err)
+	}
+
+	return nil
+}
+
+// RemoveFollowRequestsByDomain remove follow requests of all users from actors on domain or its subdomains
+func (frr *FollowRequestRepositoryImplement) RemoveFollowRequestsByDomain(ctx context.Context, domain string) error {
+	query := `DELETE FROM follow_requests WHERE actor_id ~ $1`
+
+	_, err := frr.pool.Exec(ctx, query, domainURLPattern(domain))
+	if err != nil {
+		return fmt.Errorf("fail to remove follow requests of domain: %w", err)
+	}
+
+	return nil
+}
+
+// GetFollowRequest get user's follow request by id
+func (frr *FollowRequestRepositoryImplement) GetFollowRequest(ctx context.Context, userID, id uuid.UUID) (*FollowRequest, error) {
+	query := `SELECT ` + followRequestColumns + ` FROM follow_requests WHERE id = $1 AND user_id = $2`
+
+	request, err := scanFollowRequest(frr.pool.QueryRow(ctx, query, id, userID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrFollowRequestNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("fail to get follow request: %w", err)
+	}
+
+	return request, nil
+}
+
+// GetFollowRequests get user's follow requests after cursor, ordered by id
+func (frr *FollowRequestRepositoryImplement) GetFollowRequests(ctx context.Context, userID uuid.UUID, cursor uuid.UUID, limit int) ([]FollowRequest, error) {
+	query := `
+		SELECT ` + followRequestColumns + `
+		FROM follow_requests
+		WHERE user_id = $1 AND id > $2
+		ORDER BY id
+		LIMIT $3
+	`
+
+	if limit <= 0 {
+		limit = 10
+	}
+
+	rows, err := frr.pool.Query(ctx, query, userID, cursor, limit)
+	if err != nil {
+		return nil, fmt.Errorf("fail to get follow requests: %w", err)
+	}
+	defer rows.Close()
+
+	var requests []FollowRequest
+
+	for rows.Next() {
+		request, err := scanFollowRequest(rows)
+		if err != nil {
+			return nil, fmt.Errorf("fail to scan follow request: %w", err)
+		}
+
+		requests = append(requests, *request)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("error on iterating follow request rows: %w", err)
+	}
+
+	return requests, nil
+}
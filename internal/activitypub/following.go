@@ -0,0 +1,270 @@
+package activitypub
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// following status
+const (
+	FollowingStatusPending  = "pending"
+	FollowingStatusAccepted = "accepted"
+	FollowingStatusRejected = "rejected"
+)
+
+// Following remote actor followed by a local user
+type Following struct {
+	ID            uuid.UUID `json:"id"`
+	UserID        uuid.UUID `json:"user_id"`
+	TargetActorID string    `json:"target_actor_id"`
+	TargetInbox   string    `json:"target_inbox"`
+	Status        string    `json:"status"`
+	ActivityID    string    `json:"activity_id"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// FollowingRepository manage remote actors followed by local users
+type FollowingRepository interface {
+	AddFollowing(ctx context.Context, userID uuid.UUID, targetActorID, targetInbox, activityID string) (*Following, error)
+	UpdateFollowingStatus(ctx context.Context, userID uuid.UUID, targetActorID, status string) error
+	RemoveFollowing(ctx context.Context, userID uuid.UUID, targetActorID string) error
+	RemoveFollowingByDomain(ctx context.Context, domain string) error
+	GetFollowingByTarget(ctx context.Context, userID uuid.UUID, targetActorID string) (*Following, error)
+	GetFollowingByActivityID(ctx context.Context, activityID string) (*Following, error)
+	ListFollowing(ctx context.Context, userID uuid.UUID) ([]Following, error)
+	GetFollowing(ctx context.Context, userID uuid.UUID, cursor uuid.UUID, limit int) ([]Following, error)
+	CountFollowing(ctx context.Context, userID uuid.UUID) (int, error)
+	GetLocalFollowersOfTarget(ctx context.Context, targetActorID string) ([]uuid.UUID, error)
+}
+
+// followingColumns columns selected for a Following, in scanFollowing order
+const followingColumns = `id, user_id, target_actor_id, target_inbox, status, COALESCE(activity_id, ''), created_at, updated_at`
+
+// scanFollowing scan a row selected with followingColumns
+func scanFollowing(row pgx.Row) (*Following, error) {
+	following := &Following{}
+
+	err := row.Scan(
+		&following.ID, &following.UserID, &following.TargetActorID, &following.TargetInbox,
+		&following.Status, &following.ActivityID, &following.CreatedAt, &following.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return following, nil
+}
+
+// FollowingRepositoryImplement
+type FollowingRepositoryImplement struct {
+	pool *pgxpool.Pool
+}
+
+// NewFollowingRepository
+func NewFollowingRepository(pool *pgxpool.Pool) FollowingRepository {
+	return &FollowingRepositoryImplement{pool: pool}
+}
+
+// AddFollowing add a pending follow, it's accepted when remote server replies Accept
+// following the same actor again resets it to pending with the new Follow activity
+func (fr *FollowingRepositoryImplement) AddFollowing(ctx context.Context, userID uuid.UUID, targetActorID, targetInbox, activityID string) (*Following, error) {
+	query := `
+		INSERT INTO following(user_id, target_actor_id, target_inbox, status, activity_id)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (user_id, target_actor_id) DO UPDATE
+		SET target_inbox = excluded.target_inbox, status = excluded.status,
+			activity_id = excluded.activity_id, updated_at = now()
+		RETURNING ` + followingColumns
+
+	following, err := scanFollowing(fr.pool.QueryRow(ctx, query, userID, targetActorID, targetInbox, FollowingStatusPending, activityID))
+	if err != nil {
+		return nil, fmt.Errorf("fail to add following: %w", err)
+	}
+
+	return following, nil
+}
+
+// UpdateFollowingStatus
+func (fr *FollowingRepositoryImplement) UpdateFollowingStatus(ctx context.Context, userID uuid.UUID, targetActorID, status string) error {
+	query := `
+		UPDATE following
+		SET status = $1, updated_at = now()
+		WHERE user_id = $2 AND target_actor_id = $3
+	`
+
+	_, err := fr.pool.Exec(ctx, query, status, userID, targetActorID)
+	if err != nil {
+		return fmt.Errorf("fail to update following status: %w", err)
+	}
+
+	return nil
+}
+
+// RemoveFollowing
+func (fr *FollowingRepositoryImplement) RemoveFollowing(ctx context.Context, userID uuid.UUID, targetActorID string) error {
+	query := `
+		DELETE FROM following
+		WHERE user_id = $1 AND target_actor_id = $2
+	`
+
+	_, err := fr.pool.Exec(ctx, query, userID, targetActorID)
+	if err != nil {
+		return fmt.Errorf("fail to remove following: %w", err)
+	}
+
+	return nil
+}
+
+// RemoveFollowingByDomain remove follows of all users whose target is on domain or its subdomains
+func (fr *FollowingRepositoryImplement) RemoveFollowingByDomain(ctx context.Context, domain string) error {
+	query := `DELETE FROM following WHERE target_actor_id ~ $1`
+
+	_, err := fr.pool.Exec(ctx, query, domainURLPattern(domain))
+	if err != nil {
+		return fmt.Errorf("fail to remove following of domain: %w", err)
+	}
+
+	return nil
+}
+
+// GetFollowingByTarget
+func (fr *FollowingRepositoryImplement) GetFollowingByTarget(ctx context.Context, userID uuid.UUID, targetActorID string) (*Following, error) {
+	query := `SELECT ` + followingColumns + ` FROM following WHERE user_id = $1 AND target_actor_id = $2`
+
+	following, err := scanFollowing(fr.pool.QueryRow(ctx, query, userID, targetActorID))
+	if err != nil {
+		return nil, fmt.Errorf("fail to get following by target: %w", err)
+	}
+
+	return following, nil
+}
+
+// GetFollowingByActivityID get follow by id of the Follow activity we sent
+func (fr *FollowingRepositoryImplement) GetFollowingByActivityID(ctx context.Context, activityID string) (*Following, error) {
+	query := `SELECT ` + followingColumns + ` FROM following WHERE activity_id = $1`
+
+	following, err := scanFollowing(fr.pool.QueryRow(ctx, query, activityID))
+	if err != nil {
+		return nil, fmt.Errorf("fail to get following by activity id: %w", err)
+	}
+
+	return following, nil
+}
+
+// ListFollowing list user's follows in every status, newest first
+func (fr *FollowingRepositoryImplement) ListFollowing(ctx context.Context, userID uuid.UUID) ([]Following, error) {
+	query := `SELECT ` + followingColumns + ` FROM following WHERE user_id = $1 ORDER BY created_at DESC`
+
+	rows, err := fr.pool.Query(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("fail to list following: %w", err)
+	}
+	defer rows.Close()
+
+	var followings []Following
+
+	for rows.Next() {
+		following, err := scanFollowing(rows)
+		if err != nil {
+			return nil, fmt.Errorf("fail to scan following: %w", err)
+		}
+
+		followings = append(followings, *following)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("error on iterating following rows: %w", err)
+	}
+
+	return followings, nil
+}
+
+// GetFollowing get a batch of accepted follows ordered by id, starting after cursor
+// pass uuid.Nil as cursor to get the first batch, and id of the last follow to get the next one
+func (fr *FollowingRepositoryImplement) GetFollowing(ctx context.Context, userID uuid.UUID, cursor uuid.UUID, limit int) ([]Following, error) {
+	query := `
+		SELECT ` + followingColumns + `
+		FROM following
+		WHERE user_id = $1 AND status = $2 AND id > $3
+		ORDER BY id
+		LIMIT $4
+	`
+
+	if limit <= 0 {
+		limit = 10
+	}
+
+	rows, err := fr.pool.Query(ctx, query, userID, FollowingStatusAccepted, cursor, limit)
+	if err != nil {
+		return nil, fmt.Errorf("fail to get following: %w", err)
+	}
+	defer rows.Close()
+
+	var followings []Following
+
+	for rows.Next() {
+		following, err := scanFollowing(rows)
+		if err != nil {
+			return nil, fmt.Errorf("fail to scan following: %w", err)
+		}
+
+		followings = append(followings, *following)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("error on iterating following rows: %w", err)
+	}
+
+	return followings, nil
+}
+
+// CountFollowing count accepted follows
+func (fr *FollowingRepositoryImplement) CountFollowing(ctx context.Context, userID uuid.UUID) (int, error) {
+	query := `SELECT count(*) FROM following WHERE user_id = $1 AND status = $2`
+
+	var count int
+	err := fr.pool.QueryRow(ctx, query, userID, FollowingStatusAccepted).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("fail to count following: %w", err)
+	}
+
+	return count, nil
+}
+
+// GetLocalFollowersOfTarget get IDs of local users whose follow of remote actor is accepted
+func (fr *FollowingRepositoryImplement) GetLocalFollowersOfTarget(ctx context.Context, targetActorID string) ([]uuid.UUID, error) {
+	query := `SELECT user_id FROM following WHERE target_actor_id = $1 AND status = $2`
+
+	rows, err := fr.pool.Query(ctx, query, targetActorID, FollowingStatusAccepted)
+	if err != nil {
+		return nil, fmt.Errorf("fail to get local followers of target: %w", err)
+	}
+	defer rows.Close()
+
+	var userIDs []uuid.UUID
+
+	for rows.Next() {
+		var userID uuid.UUID
+		err := rows.Scan(&userID)
+		if err != nil {
+			return nil, fmt.Errorf("fail to scan user id: %w", err)
+		}
+
+		userIDs = append(userIDs, userID)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("error on iterating following rows: %w", err)
+	}
+
+	return userIDs, nil
+}
@@ -0,0 +1,239 @@
+package activitypub
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// a claimed activity not processed within the lease is claimed again, e.g. after a crash
+const activityLease = 5 * time.Minute
+
+// InboxActivity inbound activity stored until it's processed
+type InboxActivity struct {
+	ID         uuid.UUID
+	ActivityID string
+	// RecipientID owner of the inbox activity was delivered to, uuid.Nil for the shared inbox
+	RecipientID uuid.UUID
+	RawContent  []byte
+	Attempts    int
+	CreatedAt   time.Time
+}
+
+// InboxConfig
+type InboxConfig struct {
+	// Workers number of activities processed at the same time
+	Workers int
+	// PollInterval how often inbox is checked for unprocessed activities
+	PollInterval time.Duration
+	// BaseBackoff wait time before the first retry, doubled on each attempt
+	BaseBackoff time.Duration
+	// MaxBackoff upper bound of wait time between attempts
+	MaxBackoff time.Duration
+	// MaxAttempts give up activity after this many failed attempts
+	MaxAttempts int
+}
+
+// InboxWorker process stored inbound activities with a pool of workers
+type InboxWorker struct {
+	activityPubRepo ActivityPubRepository
+	apServerService *ActivityPubServerService
+	config          InboxConfig
+	logger          *zap.Logger
+
+	// stop claiming new activities
+	stopClaiming context.CancelFunc
+	// cancel activities in flight
+	cancelProcessing context.CancelFunc
+	wg               sync.WaitGroup
+}
+
+// NewInboxWorker
+func NewInboxWorker(activityPubRepo ActivityPubRepository, apServerService *ActivityPubServerService, config InboxConfig, logger *zap.Logger) *InboxWorker {
+	if config.Workers <= 0 {
+		config.Workers = 1
+	}
+	if config.PollInterval <= 0 {
+		config.PollInterval = time.Second
+	}
+	if config.BaseBackoff <= 0 {
+		config.BaseBackoff = 10 * time.Second
+	}
+	if config.MaxBackoff < config.BaseBackoff {
+		config.MaxBackoff = config.BaseBackoff
+	}
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = 1
+	}
+
+	return &InboxWorker{
+		activityPubRepo: activityPubRepo,
+		apServerService: apServerService,
+		config:          config,
+		logger:          logger,
+	}
+}
+
+// Start claim unprocessed activities in background and process them with the worker pool
+func (iw *InboxWorker) Start() {
+	claimCtx, stopClaiming := context.WithCancel(context.Background())
+	processCtx, cancelProcessing := context.WithCancel(context.Background())
+	iw.stopClaiming = stopClaiming
+	iw.cancelProcessing = cancelProcessing
+
+	jobs := make(chan InboxActivity)
+
+	// workers
+	for i := 0; i < iw.config.Workers; i++ {
+		iw.wg.Add(1)
+		go func() {
+			defer iw.wg.Done()
+			for activity := range jobs {
+				iw.process(processCtx, activity)
+			}
+		}()
+	}
+
+	// poller, it closes jobs when claiming stops so workers exit after their current activity
+	iw.wg.Add(1)
+	go func() {
+		defer iw.wg.Done()
+		defer close(jobs)
+
+		ticker := time.NewTicker(iw.config.PollInterval)
+		defer ticker.Stop()
+
+		for {
+			activities, err := iw.activityPubRepo.GetUnprocessedActivities(claimCtx, iw.config.Workers)
+			if err != nil && claimCtx.Err() == nil {
+				iw.logger.Error("fail to claim activities", zap.Error(err))
+			}
+
+			for i, activity := range activities {
+				select {
+				case jobs <- activity:
+				case <-claimCtx.Done():
+					// give back activities no worker took
+					for _, unprocessed := range activities[i:] {
+						iw.release(unprocessed)
+					}
+					return
+				}
+			}
+
+			// a full batch means more activities may be waiting
+			if len(activities) == iw.config.Workers {
+				continue
+			}
+
+			select {
+			case <-ticker.C:
+			case <-claimCtx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// Shutdown stop claiming activities and wait for activities in flight
+// when ctx is done first, activities in flight are canceled and processed again after restart
+func (iw *InboxWorker) Shutdown(ctx context.Context) error {
+	if iw.stopClaiming == nil {
+		return nil
+	}
+	iw.stopClaiming()
+
+	done := make(chan struct{})
+	go func() {
+		iw.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		iw.cancelProcessing()
+		return nil
+	case <-ctx.Done():
+		iw.cancelProcessing()
+		<-done
+		return ctx.Err()
+	}
+}
+
+// process handle one activity and record result
+func (iw *InboxWorker) process(ctx context.Context, activity InboxActivity) {
+	logger := iw.logger.With(
+		zap.String("activity_id", activity.ActivityID),
+		zap.Int("attempts", activity.Attempts),
+	)
+
+	err := iw.apServerService.ProcessActivity(ctx, &activity)
+
+	// use a fresh context, process context may be canceled by shutdown
+	resultCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	switch {
+	case err == nil:
+		err = iw.activityPubRepo.MarkActivityAsProcessed(resultCtx, activity.ID)
+
+	case ctx.Err() != nil:
+		// canceled by shutdown, it's processed again after restart
+		err = iw.activityPubRepo.ReleaseActivity(resultCtx, activity.ID)
+
+	case isPermanentInboxError(err):
+		logger.Warn("activity can't be processed", zap.Error(err))
+		err = iw.activityPubRepo.MarkActivityFailed(resultCtx, activity.ID, err.Error())
+
+	case activity.Attempts >= iw.config.MaxAttempts:
+		logger.Warn("activity processing attempts exceeded", zap.Error(err))
+		err = iw.activityPubRepo.MarkActivityFailed(resultCtx, activity.ID, fmt.Sprintf("attempts exceeded: %v", err))
+
+	default:
+		nextAttemptAt := time.Now().Add(iw.backoff(activity.Attempts))
+
+		logger.Info("activity processing failed, retry later", zap.Time("next_attempt_at", nextAttemptAt), zap.Error(err))
+		err = iw.activityPubRepo.MarkActivityRetry(resultCtx, activity.ID, nextAttemptAt, err.Error())
+	}
+
+	if err != nil {
+		logger.Error("fail to record activity processing result", zap.Error(err))
+	}
+}
+
+// release give back a claimed activity which wasn't sent to a worker
+func (iw *InboxWorker) release(activity InboxActivity) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	err := iw.activityPubRepo.ReleaseActivity(ctx, activity.ID)
+	if err != nil {
+		iw.logger.Error("fail to release activity", zap.String("activity_id", activity.ActivityID), zap.Error(err))
+	}
+}
+
+// backoff exponential wait time before next attempt with jitter
+// it's between half and full of BaseBackoff * 2^(attempts-1), capped by MaxBackoff
+func (iw *InboxWorker) backoff(attempts int) time.Duration {
+	backoff := iw.config.BaseBackoff
+	for i := 1; i < attempts && backoff < iw.config.MaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > iw.config.MaxBackoff {
+		backoff = iw.config.MaxBackoff
+	}
+
+	half := backoff / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// isPermanentInboxError check if processing activity again can't succeed
+func isPermanentInboxError(err error) bool {
+	return errors.Is(err, ErrInvalidActivity) || errors.Is(err, ErrDomainBlocked)
+}
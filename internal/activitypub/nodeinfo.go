@@ -0,0 +1,63 @@
+package activitypub
+
+// NodeInfo const
+const (
+	NodeInfoSchema21      = "http://nodeinfo.diaspora.software/ns/schema/2.1"
+	ContentTypeNodeInfo21 = `application/json; profile="http://nodeinfo.diaspora.software/ns/schema/2.1#"`
+
+	// software name should match ^[a-z0-9-]+$
+	SoftwareName       = "je-suis-ici"
+	SoftwareVersion    = "0.1.0"
+	SoftwareRepository = "https://github.com/AdrieneTZ/je-suis-ici-activitypub"
+
+	ProtocolActivityPub = "activitypub"
+)
+
+// NodeInfoDiscovery: https://github.com/jhass/nodeinfo/blob/main/PROTOCOL.md#discovery
+type NodeInfoDiscovery struct {
+	Links []NodeInfoLink `json:"links"`
+}
+
+// NodeInfoLink
+type NodeInfoLink struct {
+	Rel  string `json:"rel"`
+	Href string `json:"href"`
+}
+
+// NodeInfo: https://github.com/jhass/nodeinfo/blob/main/schemas/2.1/schema.json
+type NodeInfo struct {
+	Version           string                 `json:"version"`
+	Software          NodeInfoSoftware       `json:"software"`
+	Protocols         []string               `json:"protocols"`
+	Services          NodeInfoServices       `json:"services"`
+	OpenRegistrations bool                   `json:"openRegistrations"`
+	Usage             NodeInfoUsage          `json:"usage"`
+	Metadata          map[string]interface{} `json:"metadata"`
+}
+
+// NodeInfoSoftware
+type NodeInfoSoftware struct {
+	Name       string `json:"name"`
+	Version    string `json:"version"`
+	Repository string `json:"repository,omitempty"`
+	Homepage   string `json:"homepage,omitempty"`
+}
+
+// NodeInfoServices third party sites this server can connect to
+type NodeInfoServices struct {
+	Inbound  []string `json:"inbound"`
+	Outbound []string `json:"outbound"`
+}
+
+// NodeInfoUsage
+type NodeInfoUsage struct {
+	Users      NodeInfoUsers `json:"users"`
+	LocalPosts int           `json:"localPosts"`
+}
+
+// NodeInfoUsers
+type NodeInfoUsers struct {
+	Total          int `json:"total"`
+	ActiveMonth    int `json:"activeMonth"`
+	ActiveHalfyear int `json:"activeHalfyear"`
+}
@@ -0,0 +1,125 @@
+package activitypub
+
+import (
+	"fmt"
+	"je-suis-ici-activitypub/internal/db/models"
+	"mime"
+	"path"
+	"strings"
+
+	"github.com/google/uuid"
+	"golang.org/x/net/html"
+)
+
+// CheckinObjectID URL of the Note object of a local checkin
+func CheckinObjectID(baseURL string, checkinID uuid.UUID) string {
+	return fmt.Sprintf("%s/checkins/%s", baseURL, checkinID)
+}
+
+// CheckinToNote convert a local checkin to a public Note object with Place location
+// mentioned actors are addressed in cc, mentions and hashtags are linked in content and listed in tag
+func CheckinToNote(checkin *models.Checkin, actorID, baseURL string) *Object {
+	note := &Object{
+		ID:           CheckinObjectID(baseURL, checkin.ID),
+		Type:         ObjectTypeNote,
+		AttributedTo: IRIProperty(actorID),
+		Content:      checkinContentHTML(checkin, baseURL),
+		URL:          IRIProperty(CheckinObjectID(baseURL, checkin.ID)),
+		Published:    checkin.CreatedAt.UTC(),
+		To:           []string{PublicAddress},
+		Cc:           []string{fmt.Sprintf("%s/followers", actorID)},
+		Shares:       fmt.Sprintf("%s/shares", CheckinObjectID(baseURL, checkin.ID)),
+		Tag:          noteTags(checkin.Mentions, checkin.Tags, baseURL),
+	}
+
+	for _, mention := range checkin.Mentions {
+		note.Cc = append(note.Cc, mention.ActorID)
+	}
+
+	// edited checkin
+	if checkin.UpdatedAt.After(checkin.CreatedAt) {
+		updated := checkin.UpdatedAt.UTC()
+		note.Updated = &updated
+	}
+
+	if checkin.LocationName != "" || checkin.Latitude != 0 || checkin.Longitude != 0 {
+		note.Location = ObjectProperty(&Place{
+			Type:      ObjectTypePlace,
+			Name:      checkin.LocationName,
+			Latitude:  checkin.Latitude,
+			Longitude: checkin.Longitude,
+		})
+	}
+
+	// media files as attachments, URL is generated by service
+	for _, media := range checkin.Media {
+		if media.URL == "" {
+			continue
+		}
+
+		note.Attachment = append(note.Attachment, ObjectProperty(&Object{
+			Type:      ObjectTypeImage,
+			URL:       IRIProperty(media.URL),
+			MediaType: mime.TypeByExtension(path.Ext(media.FilePath)),
+		})...)
+	}
+
+	return note
+}
+
+// CheckinToCreateActivity wrap Note of a local checkin in a Create activity
+func CheckinToCreateActivity(checkin *models.Checkin, actorID, baseURL string) *Activity {
+	note := CheckinToNote(checkin, actorID, baseURL)
+
+	return &Activity{
+		ID:        checkin.ActivityID,
+		Type:      ActivityTypeCreate,
+		Actor:     IRIProperty(actorID),
+		Object:    ObjectProperty(note),
+		To:        note.To,
+		Cc:        note.Cc,
+		Published: note.Published,
+	}
+}
+
+// checkinContentHTML escape plain text checkin content, link its mentions and hashtags and wrap it in a paragraph
+func checkinContentHTML(checkin *models.Checkin, baseURL string) string {
+	if checkin.Content == "" {
+		return ""
+	}
+
+	escaped := linkEntities(checkin.Content, checkin.Mentions, baseURL)
+	escaped = strings.ReplaceAll(escaped, "\n", "<br>")
+
+	return fmt.Sprintf("<p>%s</p>", escaped)
+}
+
+// HTMLToText convert HTML content of remote Note to plain text like local checkin content
+// paragraphs and line breaks become new lines, other tags are dropped
+func HTMLToText(content string) string {
+	var builder strings.Builder
+
+	tokenizer := html.NewTokenizer(strings.NewReader(content))
+	for {
+		tokenType := tokenizer.Next()
+		switch tokenType {
+		case html.ErrorToken:
+			return strings.TrimSpace(builder.String())
+
+		case html.TextToken:
+			builder.Write(tokenizer.Text())
+
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, _ := tokenizer.TagName()
+			if string(name) == "br" {
+				builder.WriteString("\n")
+			}
+
+		case html.EndTagToken:
+			name, _ := tokenizer.TagName()
+			if string(name) == "p" {
+				builder.WriteString("\n\n")
+			}
+		}
+	}
+}
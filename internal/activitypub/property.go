@@ -0,0 +1,358 @@
+package activitypub
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// PropertyValue one value of a property, an IRI or an embedded object or link
+type PropertyValue struct {
+	// IRI plain IRI value, id of an embedded object or href of a link
+	IRI string
+	// Type type of an embedded object or link, empty for a plain IRI
+	Type string
+	// Raw embedded object or link as received, nil for a plain IRI
+	Raw json.RawMessage
+
+	// err error of encoding an object set by ObjectProperty, it's returned when property is encoded
+	err error
+}
+
+// Property value of a property which can be an IRI, an embedded object or link, or an array of them
+// https://www.w3.org/TR/activitystreams-core/#model
+// decoding never fails on the shape of the value, values which aren't an IRI or an object are dropped
+// a property with one value is encoded as that value, with more values as an array
+type Property []PropertyValue
+
+// IRIProperty property with IRI values, empty IRIs are skipped
+func IRIProperty(iris ...string) Property {
+	var property Property
+	for _, iri := range iris {
+		if iri != "" {
+			property = append(property, PropertyValue{IRI: iri})
+		}
+	}
+
+	return property
+}
+
+// ObjectProperty property with embedded object values, e.g. a Note or an activity
+func ObjectProperty(objects ...interface{}) Property {
+	var property Property
+	for _, object := range objects {
+		raw, err := json.Marshal(object)
+		if err != nil {
+			property = append(property, PropertyValue{err: err})
+			continue
+		}
+
+		value, ok := decodePropertyValue(raw)
+		if ok {
+			property = append(property, value)
+		}
+	}
+
+	return property
+}
+
+// IRI IRI of the first value
+func (p Property) IRI() string {
+	if len(p) == 0 {
+		return ""
+	}
+
+	return p[0].IRI
+}
+
+// Type type of the first value, empty when it's a plain IRI
+func (p Property) Type() string {
+	if len(p) == 0 {
+		return ""
+	}
+
+	return p[0].Type
+}
+
+// IRIs IRIs of all values, values without IRI are skipped
+func (p Property) IRIs() []string {
+	var iris []string
+	for _, value := range p {
+		if value.IRI != "" {
+			iris = append(iris, value.IRI)
+		}
+	}
+
+	return iris
+}
+
+// Decode decode the first value into dest, it must be an embedded object
+func (p Property) Decode(dest interface{}) error {
+	if len(p) == 0 {
+		return fmt.Errorf("property is empty")
+	}
+
+	return p[0].Decode(dest)
+}
+
+// Decode decode embedded object or link into dest
+func (pv PropertyValue) Decode(dest interface{}) error {
+	if pv.err != nil {
+		return pv.err
+	}
+	if pv.Raw == nil {
+		return fmt.Errorf("%s isn't an embedded object", pv.IRI)
+	}
+
+	return json.Unmarshal(pv.Raw, dest)
+}
+
+// MarshalJSON
+func (pv PropertyValue) MarshalJSON() ([]byte, error) {
+	if pv.err != nil {
+		return nil, pv.err
+	}
+	if pv.Raw != nil {
+		return pv.Raw, nil
+	}
+
+	return json.Marshal(pv.IRI)
+}
+
+// MarshalJSON
+func (p Property) MarshalJSON() ([]byte, error) {
+	switch len(p) {
+	case 0:
+		return []byte("null"), nil
+	case 1:
+		return p[0].MarshalJSON()
+	}
+
+	return json.Marshal([]PropertyValue(p))
+}
+
+// UnmarshalJSON
+func (p *Property) UnmarshalJSON(data []byte) error {
+	*p = nil
+
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 || data[0] != '[' {
+		value, ok := decodePropertyValue(data)
+		if ok {
+			*p = Property{value}
+		}
+		return nil
+	}
+
+	var items []json.RawMessage
+	err := json.Unmarshal(data, &items)
+	if err != nil {
+		return nil
+	}
+
+	for _, item := range items {
+		value, ok := decodePropertyValue(item)
+		if ok {
+			*p = append(*p, value)
+		}
+	}
+
+	return nil
+}
+
+// decodePropertyValue decode a single IRI, object or link, ok is false for other values
+func decodePropertyValue(data []byte) (PropertyValue, bool) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return PropertyValue{}, false
+	}
+
+	switch data[0] {
+	case '"':
+		var iri string
+		err := json.Unmarshal(data, &iri)
+		if err != nil || iri == "" {
+			return PropertyValue{}, false
+		}
+		return PropertyValue{IRI: iri}, true
+
+	case '{':
+		var object struct {
+			ID   json.RawMessage `json:"id"`
+			Href json.RawMessage `json:"href"`
+			Type json.RawMessage `json:"type"`
+		}
+		err := json.Unmarshal(data, &object)
+		if err != nil {
+			return PropertyValue{}, false
+		}
+
+		value := PropertyValue{
+			IRI:  firstString(object.ID),
+			Type: firstString(object.Type),
+			Raw:  append(json.RawMessage(nil), data...),
+		}
+		if value.IRI == "" {
+			value.IRI = firstString(object.Href)
+		}
+		return value, true
+	}
+
+	return PropertyValue{}, false
+}
+
+// firstString decode a string, or the first string of an array
+// e.g. "type" is sometimes ["Note", "extension:Type"]
+func firstString(data json.RawMessage) string {
+	var value string
+	if json.Unmarshal(data, &value) == nil {
+		return value
+	}
+
+	var values []json.RawMessage
+	if json.Unmarshal(data, &values) == nil {
+		for _, item := range values {
+			if json.Unmarshal(item, &value) == nil {
+				return value
+			}
+		}
+	}
+
+	return ""
+}
+
+// IRIs addressing property like to and cc, a single IRI or an array
+// embedded objects and links are reduced to their id or href
+type IRIs []string
+
+// UnmarshalJSON
+func (i *IRIs) UnmarshalJSON(data []byte) error {
+	var property Property
+	err := property.UnmarshalJSON(data)
+	if err != nil {
+		return err
+	}
+
+	*i = property.IRIs()
+	return nil
+}
+
+// UnmarshalJSON accept a single context value as well as an array
+func (c *Context) UnmarshalJSON(data []byte) error {
+	var values []interface{}
+	if json.Unmarshal(data, &values) == nil {
+		*c = values
+		return nil
+	}
+
+	var value interface{}
+	err := json.Unmarshal(data, &value)
+	if err != nil {
+		return err
+	}
+
+	*c = nil
+	if value != nil {
+		*c = Context{value}
+	}
+	return nil
+}
+
+// knownProperties cache of JSON names of struct fields by type
+var knownProperties sync.Map
+
+// jsonNames get JSON names of exported fields of struct type t
+func jsonNames(t reflect.Type) map[string]bool {
+	if names, ok := knownProperties.Load(t); ok {
+		return names.(map[string]bool)
+	}
+
+	names := make(map[string]bool)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		switch name {
+		case "-":
+			continue
+		case "":
+			name = field.Name
+		}
+		names[name] = true
+	}
+
+	knownProperties.Store(t, names)
+	return names
+}
+
+// decodeWithExtra decode data into v, a pointer to a struct type without UnmarshalJSON,
+// and return properties v doesn't have a field for
+func decodeWithExtra(data []byte, v interface{}) (map[string]json.RawMessage, error) {
+	err := json.Unmarshal(data, v)
+	if err != nil {
+		return nil, err
+	}
+
+	var properties map[string]json.RawMessage
+	err = json.Unmarshal(data, &properties)
+	if err != nil {
+		return nil, err
+	}
+
+	known := jsonNames(reflect.TypeOf(v).Elem())
+	for name := range properties {
+		if known[name] {
+			delete(properties, name)
+		}
+	}
+
+	if len(properties) == 0 {
+		return nil, nil
+	}
+	return properties, nil
+}
+
+// encodeWithExtra encode v, a struct type without MarshalJSON, and append extra properties
+// properties set on v win over extra ones with the same name
+func encodeWithExtra(v interface{}, extra map[string]json.RawMessage) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil || len(extra) == 0 {
+		return data, err
+	}
+
+	known := jsonNames(reflect.Indirect(reflect.ValueOf(v)).Type())
+
+	names := make([]string, 0, len(extra))
+	for name := range extra {
+		if !known[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	buf.Write(data[:len(data)-1])
+	for _, name := range names {
+		if buf.Len() > 1 {
+			buf.WriteByte(',')
+		}
+
+		key, err := json.Marshal(name)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(extra[name])
+	}
+	buf.WriteByte('}')
+
+	return buf.Bytes(), nil
+}
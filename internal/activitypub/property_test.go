@@ -0,0 +1,549 @@
+package activitypub
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// readFixture read JSON document of testdata directory
+func readFixture(t *testing.T, name string) []byte {
+	t.Helper()
+
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatalf("fail to read fixture %s: %v", name, err)
+	}
+
+	return data
+}
+
+func TestPropertyUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name  string
+		data  string
+		iris  []string
+		types []string
+	}{
+		{
+			name:  "IRI",
+			data:  `"https://example.com/users/alice"`,
+			iris:  []string{"https://example.com/users/alice"},
+			types: []string{""},
+		},
+		{
+			name:  "object",
+			data:  `{"id": "https://example.com/users/alice", "type": "Person"}`,
+			iris:  []string{"https://example.com/users/alice"},
+			types: []string{"Person"},
+		},
+		{
+			name:  "link",
+			data:  `{"type": "Link", "href": "https://example.com/@alice"}`,
+			iris:  []string{"https://example.com/@alice"},
+			types: []string{"Link"},
+		},
+		{
+			name:  "type array",
+			data:  `{"id": "https://example.com/notes/1", "type": ["Note", "ext:Post"]}`,
+			iris:  []string{"https://example.com/notes/1"},
+			types: []string{"Note"},
+		},
+		{
+			name:  "array of IRIs and objects",
+			data:  `["https://example.com/a", {"id": "https://example.com/b", "type": "Note"}]`,
+			iris:  []string{"https://example.com/a", "https://example.com/b"},
+			types: []string{"", "Note"},
+		},
+		{
+			name:  "invalid values are dropped",
+			data:  `["https://example.com/a", 1, null, "", true, ["https://example.com/b"]]`,
+			iris:  []string{"https://example.com/a"},
+			types: []string{""},
+		},
+		{
+			name: "null",
+			data: `null`,
+		},
+		{
+			name: "number",
+			data: `42`,
+		},
+		{
+			name: "empty array",
+			data: `[]`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var property Property
+			err := json.Unmarshal([]byte(tt.data), &property)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if len(property) != len(tt.iris) {
+				t.Fatalf("got %d values, want %d", len(property), len(tt.iris))
+			}
+			for i, value := range property {
+				if value.IRI != tt.iris[i] {
+					t.Errorf("value %d: got IRI %q, want %q", i, value.IRI, tt.iris[i])
+				}
+				if value.Type != tt.types[i] {
+					t.Errorf("value %d: got type %q, want %q", i, value.Type, tt.types[i])
+				}
+			}
+		})
+	}
+}
+
+func TestPropertyMarshalJSON(t *testing.T) {
+	tests := []struct {
+		name     string
+		property Property
+		want     string
+	}{
+		{
+			name:     "empty",
+			property: nil,
+			want:     `null`,
+		},
+		{
+			name:     "one IRI",
+			property: IRIProperty("https://example.com/a"),
+			want:     `"https://example.com/a"`,
+		},
+		{
+			name:     "more IRIs",
+			property: IRIProperty("https://example.com/a", "", "https://example.com/b"),
+			want:     `["https://example.com/a","https://example.com/b"]`,
+		},
+		{
+			name:     "object",
+			property: ObjectProperty(&Link{Type: "Link", Href: "https://example.com/a"}),
+			want:     `{"type":"Link","href":"https://example.com/a"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := json.Marshal(tt.property)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if string(data) != tt.want {
+				t.Errorf("got %s, want %s", data, tt.want)
+			}
+		})
+	}
+}
+
+func TestIRIsUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want IRIs
+	}{
+		{
+			name: "IRI",
+			data: `"https://www.w3.org/ns/activitystreams#Public"`,
+			want: IRIs{PublicAddress},
+		},
+		{
+			name: "array",
+			data: `["https://example.com/a", "https://example.com/b"]`,
+			want: IRIs{"https://example.com/a", "https://example.com/b"},
+		},
+		{
+			name: "objects and links",
+			data: `[{"id": "https://example.com/a"}, {"type": "Link", "href": "https://example.com/b"}, {"type": "Note"}]`,
+			want: IRIs{"https://example.com/a", "https://example.com/b"},
+		},
+		{
+			name: "null",
+			data: `null`,
+			want: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var iris IRIs
+			err := json.Unmarshal([]byte(tt.data), &iris)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(iris, tt.want) {
+				t.Errorf("got %v, want %v", iris, tt.want)
+			}
+		})
+	}
+}
+
+func TestActivityFixtures(t *testing.T) {
+	tests := []struct {
+		fixture    string
+		actor      string
+		objectID   string
+		objectType string
+		target     string
+		to         []string
+		cc         []string
+		images     []string
+		tags       []string
+		mentions   []string
+		place      string
+	}{
+		{
+			fixture:    "mastodon_create.json",
+			actor:      "https://mastodon.social/users/alice",
+			objectID:   "https://mastodon.social/users/alice/statuses/1",
+			objectType: "Note",
+			to:         []string{PublicAddress},
+			cc:         []string{"https://mastodon.social/users/alice/followers", "https://ici.example/users/bob"},
+			images:     []string{"https://files.mastodon.social/a.jpg"},
+			tags:       []string{"paris"},
+			mentions:   []string{"https://ici.example/users/bob"},
+		},
+		{
+			fixture:    "pleroma_create.json",
+			actor:      "https://pleroma.example/users/carol",
+			objectID:   "https://pleroma.example/objects/1",
+			objectType: "Note",
+			to:         []string{PublicAddress},
+			cc:         []string{"https://pleroma.example/users/carol/followers"},
+		},
+		{
+			fixture:    "misskey_create.json",
+			actor:      "https://misskey.example/users/9x",
+			objectID:   "https://misskey.example/notes/9abc",
+			objectType: "Note",
+			to:         []string{PublicAddress},
+			cc:         []string{"https://misskey.example/users/9x/followers"},
+			images:     []string{"https://misskey.example/files/a.webp"},
+		},
+		{
+			fixture:    "pixelfed_create.json",
+			actor:      "https://pixelfed.example/users/dave",
+			objectID:   "https://pixelfed.example/p/dave/1",
+			objectType: "Note",
+			to:         []string{PublicAddress},
+			cc:         []string{"https://pixelfed.example/users/dave/followers"},
+			images:     []string{"https://pixelfed.example/storage/m/1.jpg"},
+			place:      "Paris",
+		},
+		{
+			fixture:    "gotosocial_create.json",
+			actor:      "https://gts.example/users/erin",
+			objectID:   "https://gts.example/users/erin/statuses/01H",
+			objectType: "Note",
+			to:         []string{PublicAddress},
+			cc:         []string{"https://gts.example/users/erin/followers"},
+			images:     []string{"https://gts.example/fileserver/a.png"},
+			mentions:   []string{"https://ici.example/users/bob"},
+		},
+		{
+			fixture:  "mastodon_add.json",
+			actor:    "https://mastodon.social/users/alice",
+			objectID: "https://mastodon.social/users/alice/statuses/1",
+			target:   "https://mastodon.social/users/alice/collections/featured",
+		},
+		{
+			fixture:    "mastodon_undo_like.json",
+			actor:      "https://mastodon.social/users/alice",
+			objectID:   "https://mastodon.social/users/alice#likes/5",
+			objectType: "Like",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			var activity Activity
+			err := json.Unmarshal(readFixture(t, tt.fixture), &activity)
+			if err != nil {
+				t.Fatalf("fail to decode activity: %v", err)
+			}
+
+			if got := activity.Actor.IRI(); got != tt.actor {
+				t.Errorf("actor: got %q, want %q", got, tt.actor)
+			}
+			if got := activity.Object.IRI(); got != tt.objectID {
+				t.Errorf("object: got %q, want %q", got, tt.objectID)
+			}
+			if got := activity.Object.Type(); got != tt.objectType {
+				t.Errorf("object type: got %q, want %q", got, tt.objectType)
+			}
+			if got := activity.Target.IRI(); got != tt.target {
+				t.Errorf("target: got %q, want %q", got, tt.target)
+			}
+			if !reflect.DeepEqual([]string(activity.To), tt.to) {
+				t.Errorf("to: got %v, want %v", activity.To, tt.to)
+			}
+			if !reflect.DeepEqual([]string(activity.Cc), tt.cc) {
+				t.Errorf("cc: got %v, want %v", activity.Cc, tt.cc)
+			}
+
+			if tt.objectType != "Note" {
+				return
+			}
+
+			note, err := decodeObject(activity.Object)
+			if err != nil {
+				t.Fatalf("fail to decode note: %v", err)
+			}
+
+			var images []string
+			for _, media := range noteImages(note) {
+				images = append(images, media.RemoteURL)
+			}
+			if !reflect.DeepEqual(images, tt.images) {
+				t.Errorf("attachment: got %v, want %v", images, tt.images)
+			}
+
+			tags, mentions := NoteEntities(note)
+			var mentioned []string
+			for _, mention := range mentions {
+				mentioned = append(mentioned, mention.ActorID)
+			}
+			if !reflect.DeepEqual(tags, tt.tags) {
+				t.Errorf("hashtags: got %v, want %v", tags, tt.tags)
+			}
+			if !reflect.DeepEqual(mentioned, tt.mentions) {
+				t.Errorf("mentions: got %v, want %v", mentioned, tt.mentions)
+			}
+
+			var placeName string
+			if place := notePlace(note); place != nil {
+				placeName = place.Name
+			}
+			if placeName != tt.place {
+				t.Errorf("location: got %q, want %q", placeName, tt.place)
+			}
+		})
+	}
+}
+
+func TestPersonFixtures(t *testing.T) {
+	tests := []struct {
+		fixture     string
+		id          string
+		icon        string
+		url         string
+		attachments []string
+	}{
+		{
+			fixture:     "mastodon_person.json",
+			id:          "https://mastodon.social/users/alice",
+			icon:        "https://files.mastodon.social/accounts/avatars/alice.png",
+			url:         "https://mastodon.social/@alice",
+			attachments: []string{"PropertyValue"},
+		},
+		{
+			fixture: "misskey_person.json",
+			id:      "https://misskey.example/users/9x",
+			icon:    "https://misskey.example/files/avatar.webp",
+			url:     "https://misskey.example/@mika",
+		},
+		{
+			fixture:     "gotosocial_person.json",
+			id:          "https://gts.example/users/erin",
+			icon:        "https://gts.example/fileserver/01H/attachment/original/avatar.png",
+			url:         "https://gts.example/@erin",
+			attachments: []string{"PropertyValue", "PropertyValue"},
+		},
+		{
+			// icon url is a Link, attachment isn't an array
+			fixture:     "pleroma_person.json",
+			id:          "https://pleroma.example/users/carol",
+			icon:        "https://pleroma.example/media/avatar.jpg",
+			url:         "https://pleroma.example/users/carol",
+			attachments: []string{"PropertyValue"},
+		},
+		{
+			// icon is an array, url is a Link
+			fixture: "pixelfed_person.json",
+			id:      "https://pixelfed.example/users/dave",
+			icon:    "https://pixelfed.example/storage/avatars/dave.jpg",
+			url:     "https://pixelfed.example/dave",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			var person Person
+			err := json.Unmarshal(readFixture(t, tt.fixture), &person)
+			if err != nil {
+				t.Fatalf("fail to decode person: %v", err)
+			}
+
+			if person.ID != tt.id {
+				t.Errorf("id: got %q, want %q", person.ID, tt.id)
+			}
+			if got := person.IconURL(); got != tt.icon {
+				t.Errorf("icon: got %q, want %q", got, tt.icon)
+			}
+			if got := person.URL.IRI(); got != tt.url {
+				t.Errorf("url: got %q, want %q", got, tt.url)
+			}
+
+			var attachments []string
+			for _, attachment := range person.Attachment {
+				attachments = append(attachments, attachment.Type)
+			}
+			if !reflect.DeepEqual(attachments, tt.attachments) {
+				t.Errorf("attachment: got %v, want %v", attachments, tt.attachments)
+			}
+		})
+	}
+}
+
+func TestExtraPropertiesRoundTrip(t *testing.T) {
+	tests := []struct {
+		fixture string
+		decode  func(data []byte) (interface{}, error)
+	}{
+		{fixture: "mastodon_create.json", decode: decodeActivityFixture},
+		{fixture: "misskey_create.json", decode: decodeActivityFixture},
+		{fixture: "pixelfed_create.json", decode: decodeActivityFixture},
+		{fixture: "gotosocial_create.json", decode: decodeActivityFixture},
+		{fixture: "pleroma_create.json", decode: decodeActivityFixture},
+		{fixture: "mastodon_add.json", decode: decodeActivityFixture},
+		{fixture: "mastodon_person.json", decode: decodePersonFixture},
+		{fixture: "misskey_person.json", decode: decodePersonFixture},
+		{fixture: "gotosocial_person.json", decode: decodePersonFixture},
+		{fixture: "pleroma_person.json", decode: decodePersonFixture},
+		{fixture: "pixelfed_person.json", decode: decodePersonFixture},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			data := readFixture(t, tt.fixture)
+
+			v, err := tt.decode(data)
+			if err != nil {
+				t.Fatalf("fail to decode fixture: %v", err)
+			}
+
+			encoded, err := json.Marshal(v)
+			if err != nil {
+				t.Fatalf("fail to encode fixture: %v", err)
+			}
+
+			var original, got map[string]interface{}
+			if err := json.Unmarshal(data, &original); err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal(encoded, &got); err != nil {
+				t.Fatal(err)
+			}
+
+			assertExtraKept(t, "", original, got, v)
+			if object, ok := original["object"].(map[string]interface{}); ok {
+				activity := v.(*Activity)
+				note, err := decodeObject(activity.Object)
+				if err != nil {
+					t.Fatalf("fail to decode object: %v", err)
+				}
+				assertExtraKept(t, "object.", object, got["object"].(map[string]interface{}), note)
+			}
+		})
+	}
+}
+
+func TestDecodeWithExtra(t *testing.T) {
+	type document struct {
+		ID   string `json:"id"`
+		Name string `json:"name,omitempty"`
+		Skip string `json:"-"`
+	}
+
+	data := []byte(`{"id": "https://example.com/a", "name": "a", "Skip": "kept", "misskey:isCat": true, "focalPoint": [0.5, -0.25]}`)
+
+	var doc document
+	extra, err := decodeWithExtra(data, &doc)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if doc.ID != "https://example.com/a" || doc.Name != "a" {
+		t.Errorf("known properties not decoded: %+v", doc)
+	}
+
+	wantExtra := []string{"Skip", "focalPoint", "misskey:isCat"}
+	var gotExtra []string
+	for name := range extra {
+		gotExtra = append(gotExtra, name)
+	}
+	if len(gotExtra) != len(wantExtra) {
+		t.Fatalf("got extra %v, want %v", gotExtra, wantExtra)
+	}
+	for _, name := range wantExtra {
+		if _, ok := extra[name]; !ok {
+			t.Errorf("extra property %q is missing", name)
+		}
+	}
+
+	// a field set on the struct wins over an extra property with the same name
+	extra["name"] = json.RawMessage(`"stale"`)
+	doc.Name = "b"
+
+	encoded, err := encodeWithExtra(doc, extra)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var got, want map[string]interface{}
+	if err := json.Unmarshal(encoded, &got); err != nil {
+		t.Fatalf("invalid JSON %s: %v", encoded, err)
+	}
+	json.Unmarshal([]byte(`{"id": "https://example.com/a", "name": "b", "Skip": "kept", "misskey:isCat": true, "focalPoint": [0.5, -0.25]}`), &want)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %s, want %v", encoded, want)
+	}
+
+	// without extra properties the struct is encoded as is
+	encoded, err = encodeWithExtra(document{ID: "https://example.com/a"}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(encoded) != `{"id":"https://example.com/a"}` {
+		t.Errorf("got %s", encoded)
+	}
+}
+
+// decodeActivityFixture
+func decodeActivityFixture(data []byte) (interface{}, error) {
+	var activity Activity
+	err := json.Unmarshal(data, &activity)
+	return &activity, err
+}
+
+// decodePersonFixture
+func decodePersonFixture(data []byte) (interface{}, error) {
+	var person Person
+	err := json.Unmarshal(data, &person)
+	return &person, err
+}
+
+// assertExtraKept check every property of original which decoded type v has no field for is encoded again unchanged
+func assertExtraKept(t *testing.T, prefix string, original, encoded map[string]interface{}, v interface{}) {
+	t.Helper()
+
+	known := jsonNames(reflect.Indirect(reflect.ValueOf(v)).Type())
+	for name, value := range original {
+		if known[name] {
+			continue
+		}
+
+		got, ok := encoded[name]
+		if !ok {
+			t.Errorf("%s%s is lost", prefix, name)
+			continue
+		}
+		if !reflect.DeepEqual(got, value) {
+			t.Errorf("%s%s: got %v, want %v", prefix, name, got, value)
+		}
+	}
+}
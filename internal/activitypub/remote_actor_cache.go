@@ -0,0 +1,264 @@
+package activitypub
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"je-suis-ici-activitypub/internal/db/models"
+	"net/url"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// minRefetchInterval forced fetches of the same actor are at least this far apart
+const minRefetchInterval = time.Minute
+
+// RemoteActorCacheConfig
+type RemoteActorCacheConfig struct {
+	// TTL cached actor documents older than this are fetched again
+	TTL time.Duration
+	// RefreshInterval how often stale actors are fetched again in background
+	RefreshInterval time.Duration
+	// RefreshBatchSize max number of actors fetched at each refresh
+	RefreshBatchSize int
+}
+
+// RemoteActorCache read remote actors from remote_actors table, they're fetched when missing or older than TTL
+type RemoteActorCache interface {
+	GetActor(ctx context.Context, actorID string) (*Person, error)
+	RefreshActor(ctx context.Context, actorID string) (*Person, error)
+	GetRemoteActor(ctx context.Context, actorID string) (*models.RemoteActor, error)
+	GetCachedActor(ctx context.Context, actorID string) (*models.RemoteActor, error)
+	StoreActor(ctx context.Context, person *Person) (*models.RemoteActor, error)
+}
+
+// RemoteActorCacheImplement
+type RemoteActorCacheImplement struct {
+	remoteActorRepo models.RemoteActorRepository
+	domainBlockRepo models.DomainBlockRepository
+	clientService   ActivityPubClientService
+	config          RemoteActorCacheConfig
+	logger          *zap.Logger
+	now             func() time.Time
+
+	stopRefreshing context.CancelFunc
+	wg             sync.WaitGroup
+}
+
+// NewRemoteActorCache
+func NewRemoteActorCache(remoteActorRepo models.RemoteActorRepository, domainBlockRepo models.DomainBlockRepository, clientService ActivityPubClientService, config RemoteActorCacheConfig, logger *zap.Logger) *RemoteActorCacheImplement {
+	if config.TTL <= 0 {
+		config.TTL = 24 * time.Hour
+	}
+	if config.RefreshInterval <= 0 {
+		config.RefreshInterval = time.Minute
+	}
+	if config.RefreshBatchSize <= 0 {
+		config.RefreshBatchSize = 50
+	}
+
+	return &RemoteActorCacheImplement{
+		remoteActorRepo: remoteActorRepo,
+		domainBlockRepo: domainBlockRepo,
+		clientService:   clientService,
+		config:          config,
+		logger:          logger,
+		now:             time.Now,
+	}
+}
+
+// GetActor get cached actor document, it's fetched when it's missing or expired
+// an expired document is still returned when actor's server can't be reached
+func (rac *RemoteActorCacheImplement) GetActor(ctx context.Context, actorID string) (*Person, error) {
+	cached, err := rac.getCached(ctx, actorID)
+	if err == nil && len(cached.Document) > 0 && rac.now().Sub(cached.FetchedAt) < rac.config.TTL {
+		return decodeRemoteActorDocument(cached)
+	}
+
+	person, _, fetchErr := rac.fetch(ctx, actorID)
+	if fetchErr != nil {
+		if err == nil && len(cached.Document) > 0 {
+			return decodeRemoteActorDocument(cached)
+		}
+		return nil, fetchErr
+	}
+
+	return person, nil
+}
+
+// RefreshActor fetch actor document even when cached one isn't expired, used when remote keys may have rotated
+// actor fetched less than a minute ago isn't fetched again
+func (rac *RemoteActorCacheImplement) RefreshActor(ctx context.Context, actorID string) (*Person, error) {
+	cached, err := rac.getCached(ctx, actorID)
+	if err == nil && len(cached.Document) > 0 && rac.now().Sub(cached.FetchAttemptedAt) < minRefetchInterval {
+		return decodeRemoteActorDocument(cached)
+	}
+
+	person, _, err := rac.fetch(ctx, actorID)
+	if err != nil {
+		return nil, err
+	}
+
+	return person, nil
+}
+
+// GetRemoteActor get cached remote actor, it's fetched when it's missing or expired
+func (rac *RemoteActorCacheImplement) GetRemoteActor(ctx context.Context, actorID string) (*models.RemoteActor, error) {
+	cached, err := rac.getCached(ctx, actorID)
+	if err == nil && rac.now().Sub(cached.FetchedAt) < rac.config.TTL {
+		return cached, nil
+	}
+
+	_, remoteActor, fetchErr := rac.fetch(ctx, actorID)
+	if fetchErr != nil {
+		if err == nil {
+			return cached, nil
+		}
+		return nil, fetchErr
+	}
+
+	return remoteActor, nil
+}
+
+// GetCachedActor get cached remote actor without fetching it, even when it's expired
+func (rac *RemoteActorCacheImplement) GetCachedActor(ctx context.Context, actorID string) (*models.RemoteActor, error) {
+	return rac.getCached(ctx, actorID)
+}
+
+// getCached get cached remote actor by actor ID, or by key ID when actor is fetched at its key ID
+// key IDs of some servers aren't the actor ID with a fragment, e.g. GoToSocial's ".../main-key"
+func (rac *RemoteActorCacheImplement) getCached(ctx context.Context, actorID string) (*models.RemoteActor, error) {
+	cached, err := rac.remoteActorRepo.GetByActorID(ctx, actorID)
+	if err == nil {
+		return cached, nil
+	}
+
+	keyURL, keyErr := url.Parse(actorID)
+	if keyErr != nil || keyURL.Host == "" {
+		return nil, err
+	}
+
+	cached, keyErr = rac.remoteActorRepo.GetByPublicKeyID(ctx, actorID, keyURL.Host)
+	if keyErr != nil {
+		return nil, err
+	}
+
+	return cached, nil
+}
+
+// StoreActor cache an actor document received in an activity
+func (rac *RemoteActorCacheImplement) StoreActor(ctx context.Context, person *Person) (*models.RemoteActor, error) {
+	remoteActor := PersonToRemoteActor(person)
+
+	err := rac.remoteActorRepo.UpsertRemoteActor(ctx, remoteActor)
+	if err != nil {
+		return nil, err
+	}
+
+	return remoteActor, nil
+}
+
+// fetch get actor document from its server and cache it
+// document must come from the server of actorID, it may be fetched at a key ID which isn't the actor ID
+// actors on suspended domains aren't fetched
+func (rac *RemoteActorCacheImplement) fetch(ctx context.Context, actorID string) (*Person, *models.RemoteActor, error) {
+	err := checkDomainBlocked(ctx, rac.domainBlockRepo, actorID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	person, err := rac.clientService.FetchActorPublicInformation(ctx, actorID)
+	if err == nil && (person.ID == "" || !isSameHost(person.ID, actorID)) {
+		err = fmt.Errorf("actor document fetched at %s has id %s", actorID, person.ID)
+	}
+	if err != nil {
+		_ = rac.remoteActorRepo.MarkFetchAttempted(ctx, actorID)
+		return nil, nil, fmt.Errorf("fail to get remote actor: %w", err)
+	}
+
+	remoteActor, err := rac.StoreActor(ctx, person)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return person, remoteActor, nil
+}
+
+// decodeRemoteActorDocument decode cached actor document
+func decodeRemoteActorDocument(remoteActor *models.RemoteActor) (*Person, error) {
+	var person Person
+	err := json.Unmarshal(remoteActor.Document, &person)
+	if err != nil {
+		return nil, fmt.Errorf("fail to decode cached actor document: %w", err)
+	}
+
+	return &person, nil
+}
+
+// Start fetch stale actors again in background
+func (rac *RemoteActorCacheImplement) Start() {
+	ctx, stopRefreshing := context.WithCancel(context.Background())
+	rac.stopRefreshing = stopRefreshing
+
+	rac.wg.Add(1)
+	go func() {
+		defer rac.wg.Done()
+
+		ticker := time.NewTicker(rac.config.RefreshInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				rac.refreshStaleActors(ctx)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// Shutdown stop refreshing and wait for the refresh in progress
+func (rac *RemoteActorCacheImplement) Shutdown(ctx context.Context) error {
+	if rac.stopRefreshing == nil {
+		return nil
+	}
+	rac.stopRefreshing()
+
+	done := make(chan struct{})
+	go func() {
+		rac.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// refreshStaleActors fetch a batch of actors whose documents are expired
+func (rac *RemoteActorCacheImplement) refreshStaleActors(ctx context.Context) {
+	actors, err := rac.remoteActorRepo.GetStaleRemoteActors(ctx, rac.now().Add(-rac.config.TTL), rac.config.RefreshBatchSize)
+	if err != nil {
+		if ctx.Err() == nil {
+			rac.logger.Error("fail to get stale remote actors", zap.Error(err))
+		}
+		return
+	}
+
+	for _, actor := range actors {
+		if ctx.Err() != nil {
+			return
+		}
+
+		_, _, err := rac.fetch(ctx, actor.ActorID)
+		if err != nil {
+			rac.logger.Info("fail to refresh remote actor", zap.String("actor_id", actor.ActorID), zap.Error(err))
+		}
+	}
+}
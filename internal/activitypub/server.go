@@ -3,18 +3,25 @@ package activitypub
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"je-suis-ici-activitypub/internal/db/models"
+	"net/url"
+	"slices"
+	"strings"
 	"time"
 )
 
 type ActivityPubRepository interface {
-	SaveActivity(ctx context.Context, activityID, actor, activityType, objectID, objectType, target string, rawContent []byte) error
+	SaveActivity(ctx context.Context, activityID, actor, activityType, objectID, objectType, target string, rawContent []byte, recipientID uuid.UUID) error
 	GetUserInboxActivities(ctx context.Context, userID uuid.UUID) ([]Activity, error)
-	GetUnprocessedActivities(ctx context.Context, limit int) ([]Activity, error)
-	MarkActivityAsProcessed(ctx context.Context, activityID string) error
+	GetUnprocessedActivities(ctx context.Context, limit int) ([]InboxActivity, error)
+	MarkActivityAsProcessed(ctx context.Context, id uuid.UUID) error
+	MarkActivityRetry(ctx context.Context, id uuid.UUID, nextAttemptAt time.Time, lastError string) error
+	MarkActivityFailed(ctx context.Context, id uuid.UUID, lastError string) error
+	ReleaseActivity(ctx context.Context, id uuid.UUID) error
 }
 
 type ActivityPubRepositoryImplement struct {
@@ -25,16 +32,18 @@ func NewActivityPubRepository(pool *pgxpool.Pool) ActivityPubRepository {
 	return &ActivityPubRepositoryImplement{pool: pool}
 }
 
-// SaveActivity
-func (apr *ActivityPubRepositoryImplement) SaveActivity(ctx context.Context, activityID, actor, activityType, objectID, objectType, target string, rawContent []byte) error {
+// SaveActivity store inbound activity until it's processed
+// an activity already stored for the same recipient is a redelivery, it's ignored
+func (apr *ActivityPubRepositoryImplement) SaveActivity(ctx context.Context, activityID, actor, activityType, objectID, objectType, target string, rawContent []byte, recipientID uuid.UUID) error {
 	// default processed is false
 	query := `
 		INSERT INTO activities(
-			activity_id, actor, type, object_id, object_type, target, raw_content, processed
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, false)
+			activity_id, actor, type, object_id, object_type, target, raw_content, processed, recipient_id
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, false, NULLIF($8, '00000000-0000-0000-0000-000000000000'::UUID))
+		ON CONFLICT DO NOTHING
 	`
 
-	_, err := apr.pool.Exec(ctx, query, activityID, actor, activityType, objectID, objectType, target, rawContent)
+	_, err := apr.pool.Exec(ctx, query, activityID, actor, activityType, objectID, objectType, target, rawContent, recipientID)
 
 	if err != nil {
 		return fmt.Errorf("fail to save activity: %w", err)
@@ -78,40 +87,42 @@ func (apr *ActivityPubRepositoryImplement) GetUserInboxActivities(ctx context.Co
 	return activities, nil
 }
 
-// GetUnprocessedActivities
-func (apr *ActivityPubRepositoryImplement) GetUnprocessedActivities(ctx context.Context, limit int) ([]Activity, error) {
+// GetUnprocessedActivities claim due unprocessed activities for this worker
+// rows locked by another worker are skipped, activities whose lease expired are claimed again
+func (apr *ActivityPubRepositoryImplement) GetUnprocessedActivities(ctx context.Context, limit int) ([]InboxActivity, error) {
 	query := `
-		SELECT activity_id, actor, type, object_id, object_type, target, raw_content
-		FROM activities
-		WHERE processed = false
-		ORDER BY created_at ASC 
-		LIMIT $1
+		UPDATE activities
+		SET attempts = attempts + 1, locked_until = now() + $1 * INTERVAL '1 second'
+		WHERE id IN (
+			SELECT id FROM activities
+			WHERE processed = false AND next_attempt_at <= now()
+				AND (locked_until IS NULL OR locked_until < now())
+			ORDER BY next_attempt_at
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, activity_id, COALESCE(recipient_id, '00000000-0000-0000-0000-000000000000'::UUID),
+			raw_content, attempts, created_at
 	`
 
-	rows, err := apr.pool.Query(ctx, query, limit)
+	rows, err := apr.pool.Query(ctx, query, int(activityLease.Seconds()), limit)
 	if err != nil {
 		return nil, fmt.Errorf("fail to get unprocessed activities: %w", err)
 	}
 	defer rows.Close()
 
-	var activities []Activity
+	var activities []InboxActivity
 
 	for rows.Next() {
-		var activity Activity
-		var activityID, actor, activityType, objectID, objectType, target string
-		var rawContent []byte
-
-		err := rows.Scan(&activityID, &actor, &activityType, &objectID, &objectType, &target, &rawContent)
+		var activity InboxActivity
+		err := rows.Scan(
+			&activity.ID, &activity.ActivityID, &activity.RecipientID,
+			&activity.RawContent, &activity.Attempts, &activity.CreatedAt,
+		)
 		if err != nil {
 			return nil, fmt.Errorf("fail to scan activity: %w", err)
 		}
 
-		// parse raw JSON format content
-		err = json.Unmarshal(rawContent, &activity)
-		if err != nil {
-			continue
-		}
-
 		activities = append(activities, activity)
 	}
 
@@ -124,14 +135,14 @@ func (apr *ActivityPubRepositoryImplement) GetUnprocessedActivities(ctx context.
 }
 
 // MarkActivityAsProcessed
-func (apr *ActivityPubRepositoryImplement) MarkActivityAsProcessed(ctx context.Context, activityID string) error {
+func (apr *ActivityPubRepositoryImplement) MarkActivityAsProcessed(ctx context.Context, id uuid.UUID) error {
 	query := `
 		UPDATE activities
-		SET processed = true
-		WHERE activity_id = $1
+		SET processed = true, processed_at = now(), locked_until = NULL, last_error = NULL
+		WHERE id = $1
 	`
 
-	_, err := apr.pool.Exec(ctx, query, activityID)
+	_, err := apr.pool.Exec(ctx, query, id)
 	if err != nil {
 		return fmt.Errorf("fail to mark activity as processed: %w", err)
 	}
@@ -139,11 +150,73 @@ func (apr *ActivityPubRepositoryImplement) MarkActivityAsProcessed(ctx context.C
 	return nil
 }
 
+// MarkActivityRetry record processing error, activity is claimed again after nextAttemptAt
+func (apr *ActivityPubRepositoryImplement) MarkActivityRetry(ctx context.Context, id uuid.UUID, nextAttemptAt time.Time, lastError string) error {
+	query := `
+		UPDATE activities
+		SET next_attempt_at = $1, last_error = $2, locked_until = NULL
+		WHERE id = $3
+	`
+
+	_, err := apr.pool.Exec(ctx, query, nextAttemptAt, lastError, id)
+	if err != nil {
+		return fmt.Errorf("fail to mark activity retry: %w", err)
+	}
+
+	return nil
+}
+
+// MarkActivityFailed stop processing activity and record why, it's kept as processed with last_error
+func (apr *ActivityPubRepositoryImplement) MarkActivityFailed(ctx context.Context, id uuid.UUID, lastError string) error {
+	query := `
+		UPDATE activities
+		SET processed = true, processed_at = now(), last_error = $1, locked_until = NULL
+		WHERE id = $2
+	`
+
+	_, err := apr.pool.Exec(ctx, query, lastError, id)
+	if err != nil {
+		return fmt.Errorf("fail to mark activity failed: %w", err)
+	}
+
+	return nil
+}
+
+// ReleaseActivity give back a claimed activity which wasn't processed, e.g. on shutdown
+func (apr *ActivityPubRepositoryImplement) ReleaseActivity(ctx context.Context, id uuid.UUID) error {
+	query := `
+		UPDATE activities
+		SET attempts = attempts - 1, locked_until = NULL
+		WHERE id = $1 AND processed = false
+	`
+
+	_, err := apr.pool.Exec(ctx, query, id)
+	if err != nil {
+		return fmt.Errorf("fail to release activity: %w", err)
+	}
+
+	return nil
+}
+
+// Follower remote actor following a local user
+type Follower struct {
+	ID      uuid.UUID `json:"id"`
+	UserID  uuid.UUID `json:"user_id"`
+	ActorID string    `json:"actor_id"`
+	Inbox   string    `json:"inbox"`
+	// SharedInbox empty when follower's server doesn't have one
+	SharedInbox string    `json:"shared_inbox,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
 // FollowerRepository manage actor's followers
 type FollowerRepository interface {
-	AddFollower(ctx context.Context, userID uuid.UUID, followerActorID, followerInbox string) error
+	AddFollower(ctx context.Context, userID uuid.UUID, followerActorID, followerInbox, followerSharedInbox string) error
 	RemoveFollower(ctx context.Context, userID uuid.UUID, followerActorID string) error
-	GetFollowers(ctx context.Context, userID uuid.UUID) ([]string, error)
+	GetFollowers(ctx context.Context, userID uuid.UUID, cursor uuid.UUID, limit int) ([]Follower, error)
+	CountFollowers(ctx context.Context, userID uuid.UUID) (int, error)
+	IsFollower(ctx context.Context, userID uuid.UUID, followerActorID string) (bool, error)
+	RemoveFollowersByDomain(ctx context.Context, domain string) error
 }
 
 type FollowerRepositoryImplement struct {
@@ -155,14 +228,15 @@ func NewFollowerRepository(pool *pgxpool.Pool) FollowerRepository {
 }
 
 // AddFollower
-func (fr *FollowerRepositoryImplement) AddFollower(ctx context.Context, userID uuid.UUID, followerActorID, followerInbox string) error {
+func (fr *FollowerRepositoryImplement) AddFollower(ctx context.Context, userID uuid.UUID, followerActorID, followerInbox, followerSharedInbox string) error {
 	query := `
-		INSERT INTO followers(user_id, follower_actor_id, follower_inbox)
-		VALUES ($1, $2, $3)
-		ON CONFLICT (user_id, follower_actor_id) DO NOTHING
+		INSERT INTO followers(user_id, follower_actor_id, follower_inbox, follower_shared_inbox)
+		VALUES ($1, $2, $3, NULLIF($4, ''))
+		ON CONFLICT (user_id, follower_actor_id) DO UPDATE
+		SET follower_inbox = excluded.follower_inbox, follower_shared_inbox = excluded.follower_shared_inbox
 	`
 
-	_, err := fr.pool.Exec(ctx, query, userID, followerActorID, followerInbox)
+	_, err := fr.pool.Exec(ctx, query, userID, followerActorID, followerInbox, followerSharedInbox)
 	if err != nil {
 		return fmt.Errorf("fail to add follower: %w", err)
 	}
@@ -185,32 +259,40 @@ WHERE user_id = $1 AND follower_actor_id = $2
 	return nil
 }
 
-// GetFollowers
-// TODO: return []Follower
-// TODO: 分批取資料
-func (fr *FollowerRepositoryImplement) GetFollowers(ctx context.Context, userID uuid.UUID) ([]string, error) {
+// GetFollowers get a batch of followers ordered by id, starting after cursor
+// pass uuid.Nil as cursor to get the first batch, and id of the last follower to get the next one
+// inboxes of cached remote actors are preferred, they're refreshed when actors move their inboxes
+func (fr *FollowerRepositoryImplement) GetFollowers(ctx context.Context, userID uuid.UUID, cursor uuid.UUID, limit int) ([]Follower, error) {
 	query := `
-		SELECT follower_actor_id, follower_inbox
-		FROM followers
-		WHERE user_id = $1
+		SELECT f.id, f.user_id, f.follower_actor_id, COALESCE(ra.inbox, f.follower_inbox),
+			COALESCE(ra.shared_inbox, f.follower_shared_inbox, ''), f.created_at
+		FROM followers f
+		LEFT JOIN remote_actors ra ON ra.actor_id = f.follower_actor_id
+		WHERE f.user_id = $1 AND f.id > $2
+		ORDER BY f.id
+		LIMIT $3
 	`
 
-	rows, err := fr.pool.Query(ctx, query, userID)
+	if limit <= 0 {
+		limit = 10
+	}
+
+	rows, err := fr.pool.Query(ctx, query, userID, cursor, limit)
 	if err != nil {
 		return nil, fmt.Errorf("fail to get followers: %w", err)
 	}
 	defer rows.Close()
 
-	var followers []string
+	var followers []Follower
 
 	for rows.Next() {
-		var actorID, inbox string
-		err := rows.Scan(&actorID, &inbox)
+		var follower Follower
+		err := rows.Scan(&follower.ID, &follower.UserID, &follower.ActorID, &follower.Inbox, &follower.SharedInbox, &follower.CreatedAt)
 		if err != nil {
 			return nil, fmt.Errorf("fail to scan follower: %w", err)
 		}
 
-		followers = append(followers, inbox)
+		followers = append(followers, follower)
 	}
 
 	err = rows.Err()
@@ -221,106 +303,404 @@ func (fr *FollowerRepositoryImplement) GetFollowers(ctx context.Context, userID
 	return followers, nil
 }
 
+// CountFollowers
+func (fr *FollowerRepositoryImplement) CountFollowers(ctx context.Context, userID uuid.UUID) (int, error) {
+	query := `SELECT count(*) FROM followers WHERE user_id = $1`
+
+	var count int
+	err := fr.pool.QueryRow(ctx, query, userID).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("fail to count followers: %w", err)
+	}
+
+	return count, nil
+}
+
+// IsFollower check whether actor already follows user
+func (fr *FollowerRepositoryImplement) IsFollower(ctx context.Context, userID uuid.UUID, followerActorID string) (bool, error) {
+	query := `SELECT EXISTS (SELECT 1 FROM followers WHERE user_id = $1 AND follower_actor_id = $2)`
+
+	var exists bool
+	err := fr.pool.QueryRow(ctx, query, userID, followerActorID).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("fail to check follower: %w", err)
+	}
+
+	return exists, nil
+}
+
+// RemoveFollowersByDomain remove followers of all users whose actor is on domain or its subdomains
+func (fr *FollowerRepositoryImplement) RemoveFollowersByDomain(ctx context.Context, domain string) error {
+	query := `DELETE FROM followers WHERE follower_actor_id ~ $1`
+
+	_, err := fr.pool.Exec(ctx, query, domainURLPattern(domain))
+	if err != nil {
+		return fmt.Errorf("fail to remove followers of domain: %w", err)
+	}
+
+	return nil
+}
+
 // ActivityPubServerService
 type ActivityPubServerService struct {
-	activityPubRepo ActivityPubRepository
-	followerRepo    FollowerRepository
-	userRepo        models.UserRepository
-	checkinRepo     models.CheckinRepository
-	actorService    ActorService
-	clientService   ActivityPubClientService
-	serverHost      string
+	activityPubRepo   ActivityPubRepository
+	followerRepo      FollowerRepository
+	followingRepo     FollowingRepository
+	followRequestRepo FollowRequestRepository
+	deliveryRepo      DeliveryRepository
+	userRepo          models.UserRepository
+	checkinRepo       models.CheckinRepository
+	remoteActorRepo   models.RemoteActorRepository
+	actorCache        RemoteActorCache
+	likeRepo          models.LikeRepository
+	shareRepo         models.ShareRepository
+	domainBlockRepo   models.DomainBlockRepository
+	blockRepo         models.BlockRepository
+	notificationRepo  models.NotificationRepository
+	actorService      ActorService
+	clientService     ActivityPubClientService
+	serverHost        string
+	baseURL           string
 }
 
 func NewActivityPubServerService(
 	activityPubRepo ActivityPubRepository,
 	followerRepo FollowerRepository,
+	followingRepo FollowingRepository,
+	followRequestRepo FollowRequestRepository,
+	deliveryRepo DeliveryRepository,
 	userRepo models.UserRepository,
 	checkinRepo models.CheckinRepository,
+	remoteActorRepo models.RemoteActorRepository,
+	actorCache RemoteActorCache,
+	likeRepo models.LikeRepository,
+	shareRepo models.ShareRepository,
+	domainBlockRepo models.DomainBlockRepository,
+	blockRepo models.BlockRepository,
+	notificationRepo models.NotificationRepository,
 	actorService ActorService,
 	clientService ActivityPubClientService,
 	serverHost string,
+	baseURL string,
 ) *ActivityPubServerService {
 	return &ActivityPubServerService{
-		activityPubRepo: activityPubRepo,
-		followerRepo:    followerRepo,
-		userRepo:        userRepo,
-		checkinRepo:     checkinRepo,
-		actorService:    actorService,
-		clientService:   clientService,
-		serverHost:      serverHost,
+		activityPubRepo:   activityPubRepo,
+		followerRepo:      followerRepo,
+		followingRepo:     followingRepo,
+		followRequestRepo: followRequestRepo,
+		deliveryRepo:      deliveryRepo,
+		userRepo:          userRepo,
+		checkinRepo:       checkinRepo,
+		remoteActorRepo:   remoteActorRepo,
+		actorCache:        actorCache,
+		likeRepo:          likeRepo,
+		shareRepo:         shareRepo,
+		domainBlockRepo:   domainBlockRepo,
+		blockRepo:         blockRepo,
+		notificationRepo:  notificationRepo,
+		actorService:      actorService,
+		clientService:     clientService,
+		serverHost:        serverHost,
+		baseURL:           baseURL,
 	}
 }
 
-// HandleInbox handle user inbox request
-func (aps *ActivityPubServerService) HandleInbox(ctx context.Context, userID uuid.UUID, body []byte) error {
-	// parse activity
-	var activity Activity
-	err := json.Unmarshal(body, &activity)
+// NewActivityID generate ID of an activity published by this server
+func NewActivityID(baseURL string) string {
+	return fmt.Sprintf("%s/activities/%s", baseURL, uuid.New())
+}
+
+// PurgeDomain remove follow relations between local users and actors on a suspended domain
+func (aps *ActivityPubServerService) PurgeDomain(ctx context.Context, domain string) error {
+	err := aps.followerRepo.RemoveFollowersByDomain(ctx, domain)
 	if err != nil {
-		return fmt.Errorf("fail to parse activity: %w", err)
+		return err
 	}
 
-	// get activity information
-	activityID := activity.ID
-	actor := activity.Actor
-	activityType := activity.Type
+	err = aps.followRequestRepo.RemoveFollowRequestsByDomain(ctx, domain)
+	if err != nil {
+		return err
+	}
 
-	// parse object information
-	var objectID string
-	var objectType string
-	var target string
+	return aps.followingRepo.RemoveFollowingByDomain(ctx, domain)
+}
 
-	//object := activity.Object
-	switch object := activity.Object.(type) {
-	case string:
-		objectID = object
-	case map[string]interface{}:
-		id, ok := object["id"].(string)
-		if ok {
-			objectID = id
-		}
-		typ, ok := object["type"].(string)
-		if ok {
-			objectType = typ
-		}
+// ErrInvalidActivity activity in request body can't be parsed or misses required fields
+var ErrInvalidActivity = errors.New("invalid activity")
+
+// HandleInbox store activity delivered to a user's inbox, it's processed later by InboxWorker
+func (aps *ActivityPubServerService) HandleInbox(ctx context.Context, userID uuid.UUID, body []byte) error {
+	return aps.saveInboxActivity(ctx, body, userID)
+}
+
+// HandleSharedInbox store activity delivered to the shared inbox, it's processed later by InboxWorker
+func (aps *ActivityPubServerService) HandleSharedInbox(ctx context.Context, body []byte) error {
+	return aps.saveInboxActivity(ctx, body, uuid.Nil)
+}
+
+// saveInboxActivity parse inbox request body and save the activity for processing
+// activity already received is accepted without being stored again
+func (aps *ActivityPubServerService) saveInboxActivity(ctx context.Context, body []byte, recipientID uuid.UUID) error {
+	activity, err := parseInboxActivity(body)
+	if err != nil {
+		return err
 	}
 
-	if activity.Target != "" {
-		target = activity.Target
+	// activities of suspended domains aren't stored
+	err = checkDomainBlocked(ctx, aps.domainBlockRepo, activity.Actor.IRI())
+	if err != nil {
+		return err
 	}
 
+	// parse object information
+	objectID, objectType := activity.Object.IRI(), activity.Object.Type()
+
 	// save activity
-	err = aps.activityPubRepo.SaveActivity(ctx, activityID, actor, activityType, objectID, objectType, target, body)
+	err = aps.activityPubRepo.SaveActivity(ctx, activity.ID, activity.Actor.IRI(), activity.Type, objectID, objectType, activity.Target.IRI(), body, recipientID)
 	if err != nil {
 		return fmt.Errorf("fail to save activity: %w", err)
 	}
 
-	// handle activity by type
-	switch activityType {
+	return nil
+}
+
+// parseInboxActivity parse activity and check required fields
+func parseInboxActivity(body []byte) (*Activity, error) {
+	var activity Activity
+	err := json.Unmarshal(body, &activity)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidActivity, err)
+	}
+
+	if activity.ID == "" || activity.Type == "" || activity.Actor.IRI() == "" {
+		return nil, fmt.Errorf("%w: missing id, type or actor", ErrInvalidActivity)
+	}
+
+	return &activity, nil
+}
+
+// ProcessActivity handle stored inbound activity for every local user it's for
+// it's for the owner of the inbox it was delivered to, or local users addressed in to/cc/bto/bcc/audience for the shared inbox
+func (aps *ActivityPubServerService) ProcessActivity(ctx context.Context, inboxActivity *InboxActivity) error {
+	activity, err := parseInboxActivity(inboxActivity.RawContent)
+	if err != nil {
+		return err
+	}
+
+	objectType := activity.Object.Type()
+
+	// activity delivered to a user's inbox is for that user
+	if inboxActivity.RecipientID != uuid.Nil {
+		return aps.handleActivity(ctx, inboxActivity.RecipientID, activity, objectType)
+	}
+
+	// Delete and Update change stored copies and Move changes follows of all users, they are handled once no matter who received them
+	if activity.Type == ActivityTypeDelete || activity.Type == ActivityTypeUpdate || activity.Type == ActivityTypeMove {
+		return aps.handleActivity(ctx, uuid.Nil, activity, objectType)
+	}
+
+	// get local recipients
+	recipients := aps.getLocalRecipients(ctx, activity)
+
+	var errs []error
+	for _, userID := range recipients {
+		err := aps.handleActivity(ctx, userID, activity, objectType)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// handleActivity handle activity by type for a local recipient
+// activities of actors recipient blocked are dropped, except Follow which is rejected and Undo which only removes things
+func (aps *ActivityPubServerService) handleActivity(ctx context.Context, userID uuid.UUID, activity *Activity, objectType string) error {
+	if userID != uuid.Nil && activity.Type != ActivityTypeFollow && activity.Type != ActivityTypeUndo {
+		blocked, err := aps.blockRepo.IsBlocked(ctx, userID, activity.Actor.IRI())
+		if err != nil {
+			return err
+		}
+		if blocked {
+			return nil
+		}
+	}
+
+	switch activity.Type {
 	case ActivityTypeFollow:
-		return aps.handleFollowActivity(ctx, userID, actor)
+		return aps.handleFollowActivity(ctx, userID, activity)
+
+	case ActivityTypeCreate:
+		if objectType == ObjectTypeNote {
+			return aps.handleCreateNoteActivity(ctx, userID, activity)
+		}
+
+	case ActivityTypeLike:
+		return aps.handleLikeActivity(ctx, userID, activity)
+
+	case ActivityTypeAnnounce:
+		return aps.handleAnnounceActivity(ctx, userID, activity)
+
+	case ActivityTypeDelete:
+		return aps.handleDeleteActivity(ctx, activity)
+
+	case ActivityTypeUpdate:
+		if objectType == ObjectTypeNote {
+			return aps.handleUpdateNoteActivity(ctx, activity)
+		}
+		if isActorType(objectType) {
+			return aps.handleUpdateActorActivity(ctx, activity)
+		}
+
+	case ActivityTypeMove:
+		return aps.handleMoveActivity(ctx, activity)
+
+	case ActivityTypeBlock:
+		return aps.handleBlockActivity(ctx, userID, activity)
+
+	case ActivityTypeAccept:
+		if objectType == "" || objectType == ActivityTypeFollow {
+			return aps.handleFollowResponseActivity(ctx, userID, activity, FollowingStatusAccepted)
+		}
+
+	case ActivityTypeReject:
+		if objectType == "" || objectType == ActivityTypeFollow {
+			return aps.handleFollowResponseActivity(ctx, userID, activity, FollowingStatusRejected)
+		}
 
 	case ActivityTypeUndo:
 		if objectType == ActivityTypeFollow {
-			return aps.handleUndoFollowActivity(ctx, userID, actor)
+			return aps.handleUndoFollowActivity(ctx, userID, activity)
+		}
+		if objectType == ActivityTypeLike {
+			return aps.handleUndoLikeActivity(ctx, activity)
+		}
+		if objectType == ActivityTypeAnnounce {
+			return aps.handleUndoAnnounceActivity(ctx, activity)
 		}
 	}
 
 	return nil
 }
 
-func (aps *ActivityPubServerService) handleFollowActivity(ctx context.Context, userID uuid.UUID, followerActorID string) error {
+// getLocalRecipients find local users addressed by activity
+func (aps *ActivityPubServerService) getLocalRecipients(ctx context.Context, activity *Activity) []uuid.UUID {
+	addresses := [][]string{activity.To, activity.Cc, activity.Bto, activity.Bcc, activity.Audience}
+
+	// Follow and Block are addressed to the followed or blocked actor through their object
+	if activity.Type == ActivityTypeFollow || activity.Type == ActivityTypeBlock {
+		objectID := activity.Object.IRI()
+		addresses = append(addresses, []string{objectID})
+	}
+
+	// Accept and Reject of a Follow are addressed to the follower, who is actor of the embedded Follow
+	if activity.Type == ActivityTypeAccept || activity.Type == ActivityTypeReject {
+		var follow Activity
+		if activity.Object.Decode(&follow) == nil {
+			addresses = append(addresses, []string{follow.Actor.IRI()})
+		}
+	}
+
+	// Note may mention local users only in its tag
+	if activity.Type == ActivityTypeCreate && activity.Object.Type() == ObjectTypeNote {
+		note, err := decodeObject(activity.Object)
+		if err == nil {
+			_, mentions := NoteEntities(note)
+			for _, mention := range mentions {
+				addresses = append(addresses, []string{mention.ActorID})
+			}
+		}
+	}
+
+	// Like is often not addressed, Like and Announce of a checkin are for its author
+	if activity.Type == ActivityTypeLike || activity.Type == ActivityTypeAnnounce {
+		objectID := activity.Object.IRI()
+		checkin, err := aps.getLocalCheckin(ctx, objectID)
+		if err == nil {
+			addresses = append(addresses, []string{checkin.User.ActorID})
+		}
+	}
+
+	// Undo of a Follow is for the followed actor, Undo of a Like or Announce is for the author of the checkin
+	// in the embedded activity
+	if activity.Type == ActivityTypeUndo {
+		var undone Activity
+		if activity.Object.Decode(&undone) == nil {
+			switch undone.Type {
+			case ActivityTypeFollow:
+				addresses = append(addresses, []string{undone.Object.IRI()})
+			case ActivityTypeLike, ActivityTypeAnnounce:
+				checkin, err := aps.getLocalCheckin(ctx, undone.Object.IRI())
+				if err == nil {
+					addresses = append(addresses, []string{checkin.User.ActorID})
+				}
+			}
+		}
+	}
+
+	seen := make(map[string]bool)
+	seenUsers := make(map[uuid.UUID]bool)
+	var recipients []uuid.UUID
+
+	for _, iris := range addresses {
+		for _, iri := range iris {
+			if iri == "" || seen[iri] || !aps.isLocalIRI(iri) {
+				continue
+			}
+			seen[iri] = true
+
+			user, err := aps.userRepo.GetByActorID(ctx, iri)
+			if err != nil {
+				continue
+			}
+
+			if !seenUsers[user.ID] {
+				seenUsers[user.ID] = true
+				recipients = append(recipients, user.ID)
+			}
+		}
+	}
+
+	// posts and boosts addressed to public or actor's followers reach local users following the actor
+	if activity.Type == ActivityTypeCreate || activity.Type == ActivityTypeAnnounce {
+		followerIDs, err := aps.followingRepo.GetLocalFollowersOfTarget(ctx, activity.Actor.IRI())
+		if err == nil {
+			for _, userID := range followerIDs {
+				if !seenUsers[userID] {
+					seenUsers[userID] = true
+					recipients = append(recipients, userID)
+				}
+			}
+		}
+	}
+
+	return recipients
+}
+
+// isLocalIRI check if IRI belongs to this server
+func (aps *ActivityPubServerService) isLocalIRI(iri string) bool {
+	iriURL, err := url.Parse(iri)
+	if err != nil {
+		return false
+	}
+
+	return strings.EqualFold(iriURL.Host, aps.serverHost)
+}
+
+// handleFollowActivity add remote actor as user's follower and send Accept
+// Follow of a locked user is kept as follow request until user approves or rejects it
+func (aps *ActivityPubServerService) handleFollowActivity(ctx context.Context, userID uuid.UUID, follow *Activity) error {
+	followerActorID := follow.Actor.IRI()
+
 	// get follower information
-	follower, err := aps.clientService.FetchActorPublicInformation(ctx, followerActorID)
+	follower, err := aps.actorCache.GetActor(ctx, followerActorID)
 	if err != nil {
 		return fmt.Errorf("fail to get follower actor: %w", err)
 	}
 
-	// add as follower
-	err = aps.followerRepo.AddFollower(ctx, userID, follower.ID, follower.Inbox)
-	if err != nil {
-		return fmt.Errorf("fail to add follower: %w", err)
+	var sharedInbox string
+	if follower.Endpoints != nil {
+		sharedInbox = follower.Endpoints.SharedInbox
 	}
 
 	// get user information
@@ -329,34 +709,1115 @@ func (aps *ActivityPubServerService) handleFollowActivity(ctx context.Context, u
 		return fmt.Errorf("fail to get user: %w", err)
 	}
 
-	// create Accept activity
-	accept := &Activity{
+	request := &FollowRequest{
+		UserID:      userID,
+		ActorID:     follower.ID,
+		Inbox:       follower.Inbox,
+		SharedInbox: sharedInbox,
+		ActivityID:  follow.ID,
+	}
+
+	// actors user blocked can't follow
+	blocked, err := aps.blockRepo.IsBlocked(ctx, userID, follower.ID)
+	if err != nil {
+		return err
+	}
+	if blocked {
+		return aps.answerFollow(ctx, user, request, ActivityTypeReject)
+	}
+
+	// Follow of an actor which already follows user is accepted again
+	if user.Locked {
+		isFollower, err := aps.followerRepo.IsFollower(ctx, userID, follower.ID)
+		if err != nil {
+			return err
+		}
+		if !isFollower {
+			return aps.followRequestRepo.AddFollowRequest(ctx, request)
+		}
+	}
+
+	return aps.acceptFollow(ctx, user, request)
+}
+
+// acceptFollow add follower and send Accept of its Follow
+func (aps *ActivityPubServerService) acceptFollow(ctx context.Context, user *models.User, request *FollowRequest) error {
+	// add as follower
+	err := aps.followerRepo.AddFollower(ctx, user.ID, request.ActorID, request.Inbox, request.SharedInbox)
+	if err != nil {
+		return fmt.Errorf("fail to add follower: %w", err)
+	}
+
+	return aps.answerFollow(ctx, user, request, ActivityTypeAccept)
+}
+
+// answerFollow queue Accept or Reject, its object is the received Follow
+func (aps *ActivityPubServerService) answerFollow(ctx context.Context, user *models.User, request *FollowRequest, activityType string) error {
+	answer := &Activity{
 		Context: DefaultContext(),
-		ID:      fmt.Sprintf("https//%s/activities/%s", aps.serverHost, uuid.New().String()),
-		Type:    ActivityTypeAccept,
-		Actor:   user.ActorID,
-		Object: map[string]interface{}{
-			"id":     followerActorID,
+		ID:      NewActivityID(aps.baseURL),
+		Type:    activityType,
+		Actor:   IRIProperty(user.ActorID),
+		Object: ObjectProperty(map[string]interface{}{
+			"id":     request.ActivityID,
 			"type":   ActivityTypeFollow,
-			"actor":  followerActorID,
+			"actor":  request.ActorID,
 			"object": user.ActorID,
-		},
-		To:        []string{followerActorID},
-		Published: time.Now(),
+		}),
+		To:        []string{request.ActorID},
+		Published: time.Now().UTC(),
+	}
+
+	// queue activity
+	return EnqueueActivity(ctx, aps.deliveryRepo, answer, user, request.Inbox)
+}
+
+// GetFollowRequests get follow requests waiting for user's approval
+func (aps *ActivityPubServerService) GetFollowRequests(ctx context.Context, userID uuid.UUID, cursor uuid.UUID, limit int) ([]FollowRequest, error) {
+	return aps.followRequestRepo.GetFollowRequests(ctx, userID, cursor, limit)
+}
+
+// ApproveFollowRequest add requesting actor as user's follower and send Accept
+func (aps *ActivityPubServerService) ApproveFollowRequest(ctx context.Context, userID, requestID uuid.UUID) error {
+	return aps.answerFollowRequest(ctx, userID, requestID, true)
+}
+
+// RejectFollowRequest drop follow request and send Reject
+func (aps *ActivityPubServerService) RejectFollowRequest(ctx context.Context, userID, requestID uuid.UUID) error {
+	return aps.answerFollowRequest(ctx, userID, requestID, false)
+}
+
+// answerFollowRequest approve or reject user's follow request
+func (aps *ActivityPubServerService) answerFollowRequest(ctx context.Context, userID, requestID uuid.UUID, approve bool) error {
+	user, err := aps.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("fail to get user: %w", err)
+	}
+
+	request, err := aps.followRequestRepo.GetFollowRequest(ctx, userID, requestID)
+	if err != nil {
+		return err
+	}
+
+	if approve {
+		err = aps.acceptFollow(ctx, user, request)
+	} else {
+		err = aps.answerFollow(ctx, user, request, ActivityTypeReject)
+	}
+	if err != nil {
+		return err
+	}
+
+	return aps.followRequestRepo.RemoveFollowRequest(ctx, userID, request.ActorID)
+}
+
+// handleFollowResponseActivity update status of user's follow when remote actor replies Accept or Reject
+// object of the reply is our Follow activity, embedded or as IRI
+func (aps *ActivityPubServerService) handleFollowResponseActivity(ctx context.Context, userID uuid.UUID, response *Activity, status string) error {
+	followID := response.Object.IRI()
+
+	// find follow by id of the Follow activity, some servers don't keep it, so fall back on the replying actor
+	following, err := aps.followingRepo.GetFollowingByActivityID(ctx, followID)
+	if err != nil || following.UserID != userID {
+		following, err = aps.followingRepo.GetFollowingByTarget(ctx, userID, response.Actor.IRI())
+		if err != nil {
+			// not a follow we know about
+			return nil
+		}
+	}
+
+	// only the followed actor can answer
+	if following.TargetActorID != response.Actor.IRI() {
+		return fmt.Errorf("%w: %s can't answer follow of %s", ErrInvalidActivity, response.Actor.IRI(), following.TargetActorID)
 	}
 
-	// send activity
-	return aps.clientService.SendActivityToTargetInbox(ctx, accept, user, follower.Inbox)
+	return aps.followingRepo.UpdateFollowingStatus(ctx, userID, following.TargetActorID, status)
 }
 
-func (aps *ActivityPubServerService) handleUndoFollowActivity(ctx context.Context, userID uuid.UUID, followerActorID string) error {
+// handleUndoFollowActivity remove follower, or its follow request when it isn't approved yet
+// only the actor of the embedded Follow can undo it
+func (aps *ActivityPubServerService) handleUndoFollowActivity(ctx context.Context, userID uuid.UUID, undo *Activity) error {
+	var follow Activity
+	err := undo.Object.Decode(&follow)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidActivity, err)
+	}
+
+	followerActorID := undo.Actor.IRI()
+	if follow.Actor.IRI() != followerActorID {
+		return fmt.Errorf("%w: %s can't undo follow of %s", ErrInvalidActivity, followerActorID, follow.Actor.IRI())
+	}
+
+	err = aps.followRequestRepo.RemoveFollowRequest(ctx, userID, followerActorID)
+	if err != nil {
+		return err
+	}
+
 	return aps.followerRepo.RemoveFollower(ctx, userID, followerActorID)
 }
 
-// SendActivityToInbox sends an activity to a user's inbox
-func (aps *ActivityPubServerService) SendActivityToInbox(ctx context.Context, activity *Activity, sender *models.User, targetInbox string) error {
-	// Use the client service to send the activity
-	return aps.clientService.SendActivityToTargetInbox(ctx, activity, sender, targetInbox)
+// handleCreateNoteActivity store public Note of a followed remote actor as a remote checkin
+// local users mentioned in the Note are notified whether they follow its author or not
+func (aps *ActivityPubServerService) handleCreateNoteActivity(ctx context.Context, userID uuid.UUID, create *Activity) error {
+	note, err := decodeObject(create.Object)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidActivity, err)
+	}
+
+	if note.ID == "" {
+		return fmt.Errorf("%w: note doesn't have an id", ErrInvalidActivity)
+	}
+	if len(note.AttributedTo) == 0 {
+		note.AttributedTo = create.Actor
+	}
+	if note.AttributedTo.IRI() != create.Actor.IRI() {
+		return fmt.Errorf("%w: note is attributed to %s, not activity actor", ErrInvalidActivity, note.AttributedTo.IRI())
+	}
+
+	// only keep content of actors user follows
+	var checkin *models.Checkin
+	following, err := aps.followingRepo.GetFollowingByTarget(ctx, userID, create.Actor.IRI())
+	if err == nil && following.Status == FollowingStatusAccepted {
+		checkin, err = aps.storeRemoteNote(ctx, note, create.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Note may be stored for another user who follows its author
+	checkinID := uuid.Nil
+	if checkin == nil {
+		checkin, _ = aps.checkinRepo.GetCheckinByObjectID(ctx, note.ID)
+	}
+	if checkin != nil {
+		checkinID = checkin.ID
+	}
+
+	_, mentions := NoteEntities(note)
+	return aps.notifyMentions(ctx, create.Actor.IRI(), note.ID, checkinID, mentions)
+}
+
+// storeRemoteNote store public Note of a remote actor as a remote checkin and return it
+// replies and non public notes aren't checkins, they are skipped and nil is returned
+func (aps *ActivityPubServerService) storeRemoteNote(ctx context.Context, note *Object, activityID string) (*models.Checkin, error) {
+	if len(note.InReplyTo) > 0 || !isPublic(note.To, note.Cc) {
+		return nil, nil
+	}
+
+	remoteActor, err := aps.actorCache.GetRemoteActor(ctx, note.AttributedTo.IRI())
+	if err != nil {
+		return nil, err
+	}
+
+	checkin := &models.Checkin{
+		Content:       HTMLToText(note.Content),
+		ActivityID:    activityID,
+		IsRemote:      true,
+		RemoteActorID: remoteActor.ID,
+		ObjectID:      note.ID,
+		URL:           note.URL.IRI(),
+		CreatedAt:     note.Published,
+	}
+	if checkin.CreatedAt.IsZero() {
+		checkin.CreatedAt = time.Now().UTC()
+	}
+	checkin.UpdatedAt = checkin.CreatedAt
+	if note.Updated != nil {
+		checkin.UpdatedAt = note.Updated.UTC()
+	}
+
+	if location := notePlace(note); location != nil {
+		checkin.LocationName = location.Name
+		checkin.Latitude = location.Latitude
+		checkin.Longitude = location.Longitude
+	}
+
+	checkin.Media = noteImages(note)
+	checkin.Tags, checkin.Mentions = NoteEntities(note)
+
+	err = aps.checkinRepo.CreateRemoteCheckin(ctx, checkin)
+	if err != nil {
+		return nil, err
+	}
+
+	// Note is already stored
+	if checkin.ID == uuid.Nil {
+		return aps.checkinRepo.GetCheckinByObjectID(ctx, note.ID)
+	}
+
+	return checkin, nil
+}
+
+// noteImages get image attachments of remote Note as media, images stay on the remote server
+func noteImages(note *Object) []models.Media {
+	var media []models.Media
+
+	for _, value := range note.Attachment {
+		var attachment Object
+		err := value.Decode(&attachment)
+		if err != nil || len(attachment.URL) == 0 {
+			continue
+		}
+		if attachment.MediaType != "" && !strings.HasPrefix(attachment.MediaType, "image/") {
+			continue
+		}
+
+		media = append(media, models.Media{
+			FileType:  "image",
+			RemoteURL: attachment.URL.IRI(),
+		})
+	}
+
+	return media
+}
+
+// notePlace get location of remote Note, nil when it doesn't have a Place
+func notePlace(note *Object) *Place {
+	var place Place
+	err := note.Location.Decode(&place)
+	if err != nil {
+		return nil
+	}
+
+	return &place
+}
+
+// handleAnnounceActivity record boost of user's checkin, or store checkin boosted by an actor user follows
+func (aps *ActivityPubServerService) handleAnnounceActivity(ctx context.Context, userID uuid.UUID, announce *Activity) error {
+	objectID := announce.Object.IRI()
+	if objectID == "" {
+		return fmt.Errorf("%w: announce doesn't have an object", ErrInvalidActivity)
+	}
+
+	share := &models.Share{
+		ActorID:    announce.Actor.IRI(),
+		ObjectID:   objectID,
+		ActivityID: announce.ID,
+	}
+
+	// boost of a local checkin is recorded for its author
+	if aps.isLocalIRI(objectID) {
+		checkin, err := aps.getLocalCheckin(ctx, objectID)
+		if err != nil || checkin.UserID != userID {
+			return nil
+		}
+
+		share.CheckinID = checkin.ID
+		return aps.shareRepo.AddShare(ctx, share)
+	}
+
+	// only keep boosts of actors user follows
+	following, err := aps.followingRepo.GetFollowingByTarget(ctx, userID, announce.Actor.IRI())
+	if err != nil || following.Status != FollowingStatusAccepted {
+		return nil
+	}
+
+	checkin, err := aps.checkinRepo.GetCheckinByObjectID(ctx, objectID)
+	if err != nil {
+		// embedded Note can't be trusted, get it from its origin server
+		note, err := aps.clientService.FetchObject(ctx, objectID)
+		if err != nil {
+			return fmt.Errorf("fail to get boosted note: %w", err)
+		}
+
+		if note.ID != objectID || note.Type != ObjectTypeNote {
+			return nil
+		}
+		if !isSameHost(note.AttributedTo.IRI(), note.ID) {
+			return fmt.Errorf("%w: boosted note isn't attributed to an actor of its server", ErrInvalidActivity)
+		}
+
+		checkin, err = aps.storeRemoteNote(ctx, note, note.ID)
+		if err != nil || checkin == nil {
+			return err
+		}
+	}
+
+	share.CheckinID = checkin.ID
+	return aps.shareRepo.AddShare(ctx, share)
+}
+
+// handleUndoAnnounceActivity remove share, embedded Announce must be sent by the same actor
+func (aps *ActivityPubServerService) handleUndoAnnounceActivity(ctx context.Context, undo *Activity) error {
+	announceID := undo.Object.IRI()
+	if announceID == "" {
+		return nil
+	}
+
+	return aps.shareRepo.RemoveShareByActivityID(ctx, announceID, undo.Actor.IRI())
+}
+
+// isSameHost check if both IRIs are on the same server
+func isSameHost(iri, otherIRI string) bool {
+	iriURL, err := url.Parse(iri)
+	if err != nil || iriURL.Host == "" {
+		return false
+	}
+
+	otherURL, err := url.Parse(otherIRI)
+	if err != nil {
+		return false
+	}
+
+	return strings.EqualFold(iriURL.Host, otherURL.Host)
+}
+
+// handleDeleteActivity purge stored copy of a deleted remote Note
+// object is the Note IRI or a Tombstone, only the Note's author can delete it
+func (aps *ActivityPubServerService) handleDeleteActivity(ctx context.Context, del *Activity) error {
+	objectID := del.Object.IRI()
+	if objectID == "" || aps.isLocalIRI(objectID) {
+		return nil
+	}
+
+	return aps.checkinRepo.DeleteRemoteCheckin(ctx, objectID, del.Actor.IRI())
+}
+
+// handleUpdateNoteActivity apply edit of a stored remote Note, only its author can edit it
+// an update older than the stored version is ignored
+func (aps *ActivityPubServerService) handleUpdateNoteActivity(ctx context.Context, update *Activity) error {
+	note, err := decodeObject(update.Object)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidActivity, err)
+	}
+
+	if note.ID == "" || aps.isLocalIRI(note.ID) {
+		return nil
+	}
+
+	checkin, err := aps.checkinRepo.GetCheckinByObjectID(ctx, note.ID)
+	if err != nil || !checkin.IsRemote {
+		return nil
+	}
+
+	if checkin.User.ActorID != update.Actor.IRI() || (len(note.AttributedTo) > 0 && note.AttributedTo.IRI() != update.Actor.IRI()) {
+		return fmt.Errorf("%w: note can only be updated by its author", ErrInvalidActivity)
+	}
+
+	updatedAt := time.Now().UTC()
+	if note.Updated != nil {
+		updatedAt = note.Updated.UTC()
+	}
+	if !updatedAt.After(checkin.UpdatedAt) {
+		return nil
+	}
+
+	checkin.Content = HTMLToText(note.Content)
+	checkin.LocationName = ""
+	checkin.Latitude = 0
+	checkin.Longitude = 0
+	if location := notePlace(note); location != nil {
+		checkin.LocationName = location.Name
+		checkin.Latitude = location.Latitude
+		checkin.Longitude = location.Longitude
+	}
+	checkin.Tags, checkin.Mentions = NoteEntities(note)
+	checkin.UpdatedAt = updatedAt
+
+	_, err = aps.checkinRepo.UpdateCheckin(ctx, checkin, nil)
+	if err != nil {
+		return err
+	}
+
+	err = aps.checkinRepo.ReplaceRemoteMedia(ctx, checkin.ID, noteImages(note))
+	if err != nil {
+		return err
+	}
+
+	// users mentioned by the edit
+	return aps.notifyMentions(ctx, update.Actor.IRI(), note.ID, checkin.ID, checkin.Mentions)
+}
+
+// handleUpdateActorActivity refresh cached copy of a remote actor, an actor can only update itself
+// actors we never cached are ignored
+func (aps *ActivityPubServerService) handleUpdateActorActivity(ctx context.Context, update *Activity) error {
+	var person Person
+	err := update.Object.Decode(&person)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidActivity, err)
+	}
+
+	if person.ID != update.Actor.IRI() {
+		return fmt.Errorf("%w: actor can only be updated by itself", ErrInvalidActivity)
+	}
+	if aps.isLocalIRI(person.ID) || person.Inbox == "" {
+		return nil
+	}
+
+	_, err = aps.remoteActorRepo.GetByActorID(ctx, person.ID)
+	if err != nil {
+		return nil
+	}
+
+	_, err = aps.actorCache.StoreActor(ctx, &person)
+	return err
+}
+
+// handleMoveActivity move local follows of a remote actor to the account it moved to
+// target account must list the moved actor in its alsoKnownAs
+func (aps *ActivityPubServerService) handleMoveActivity(ctx context.Context, move *Activity) error {
+	objectID := move.Object.IRI()
+	if objectID != move.Actor.IRI() || len(move.Target) == 0 {
+		return fmt.Errorf("%w: actor can only move itself", ErrInvalidActivity)
+	}
+	if aps.isLocalIRI(move.Actor.IRI()) {
+		return nil
+	}
+
+	userIDs, err := aps.followingRepo.GetLocalFollowersOfTarget(ctx, move.Actor.IRI())
+	if err != nil || len(userIDs) == 0 {
+		return err
+	}
+
+	// fetch target instead of trusting the activity, it has just added the moved actor as alias
+	target, err := aps.actorCache.RefreshActor(ctx, move.Target.IRI())
+	if err != nil {
+		return fmt.Errorf("fail to get move target: %w", err)
+	}
+	if !slices.Contains(target.AlsoKnownAs, move.Actor.IRI()) {
+		return fmt.Errorf("%w: %s doesn't list %s as alias", ErrInvalidActivity, target.ID, move.Actor.IRI())
+	}
+	if target.Inbox == "" {
+		return fmt.Errorf("%w: move target doesn't have an inbox", ErrInvalidActivity)
+	}
+
+	var errs []error
+	for _, userID := range userIDs {
+		err := aps.moveFollowing(ctx, userID, move.Actor.IRI(), target)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// moveFollowing unfollow moved actor and follow target on behalf of user
+func (aps *ActivityPubServerService) moveFollowing(ctx context.Context, userID uuid.UUID, movedActorID string, target *Person) error {
+	user, err := aps.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("fail to get user: %w", err)
+	}
+
+	following, err := aps.followingRepo.GetFollowingByTarget(ctx, userID, movedActorID)
+	if err != nil {
+		return err
+	}
+
+	err = aps.unfollow(ctx, user, following)
+	if err != nil {
+		return err
+	}
+
+	// already following target
+	existing, err := aps.followingRepo.GetFollowingByTarget(ctx, userID, target.ID)
+	if err == nil && existing.Status == FollowingStatusAccepted {
+		return nil
+	}
+
+	follow := &Activity{
+		Context:   DefaultContext(),
+		ID:        NewActivityID(aps.baseURL),
+		Type:      ActivityTypeFollow,
+		Actor:     IRIProperty(user.ActorID),
+		Object:    IRIProperty(target.ID),
+		To:        []string{target.ID},
+		Published: time.Now().UTC(),
+	}
+
+	// record pending follow before queueing, Accept may arrive right after delivery
+	_, err = aps.followingRepo.AddFollowing(ctx, userID, target.ID, target.Inbox, follow.ID)
+	if err != nil {
+		return err
+	}
+
+	err = EnqueueActivity(ctx, aps.deliveryRepo, follow, user, target.Inbox)
+	if err != nil {
+		return fmt.Errorf("fail to send follow: %w", err)
+	}
+
+	return nil
+}
+
+// handleLikeActivity record remote actor's like on user's checkin
+func (aps *ActivityPubServerService) handleLikeActivity(ctx context.Context, userID uuid.UUID, like *Activity) error {
+	objectID := like.Object.IRI()
+
+	// only likes on user's own checkins are recorded
+	checkin, err := aps.getLocalCheckin(ctx, objectID)
+	if err != nil || checkin.UserID != userID {
+		return nil
+	}
+
+	return aps.likeRepo.AddLike(ctx, &models.Like{
+		CheckinID:  checkin.ID,
+		ActorID:    like.Actor.IRI(),
+		ObjectID:   objectID,
+		ActivityID: like.ID,
+	})
+}
+
+// handleUndoLikeActivity remove like, embedded Like must be sent by the same actor
+func (aps *ActivityPubServerService) handleUndoLikeActivity(ctx context.Context, undo *Activity) error {
+	likeID := undo.Object.IRI()
+	if likeID != "" {
+		return aps.likeRepo.RemoveLikeByActivityID(ctx, likeID, undo.Actor.IRI())
+	}
+
+	// some servers don't give the Like an id, remove like of the actor on liked checkin
+	var like Activity
+	err := undo.Object.Decode(&like)
+	if err != nil {
+		return nil
+	}
+
+	checkin, err := aps.getLocalCheckin(ctx, like.Object.IRI())
+	if err != nil {
+		return nil
+	}
+
+	return aps.likeRepo.RemoveLike(ctx, checkin.ID, undo.Actor.IRI())
+}
+
+// getLocalCheckin get local checkin by its Note ID
+func (aps *ActivityPubServerService) getLocalCheckin(ctx context.Context, objectID string) (*models.Checkin, error) {
+	if !aps.isLocalIRI(objectID) {
+		return nil, fmt.Errorf("%s is not a local object", objectID)
+	}
+
+	objectURL, err := url.Parse(objectID)
+	if err != nil {
+		return nil, err
+	}
+
+	idStr, ok := strings.CutPrefix(objectURL.Path, "/checkins/")
+	if !ok {
+		return nil, fmt.Errorf("%s is not a checkin", objectID)
+	}
+
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		return nil, fmt.Errorf("fail to parse checkin id: %w", err)
+	}
+
+	checkin, err := aps.checkinRepo.GetCheckinByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if checkin.IsRemote {
+		return nil, fmt.Errorf("%s is not a local checkin", objectID)
+	}
+
+	return checkin, nil
+}
+
+// unfollow send Undo{Follow} of user's follow and remove it
+func (aps *ActivityPubServerService) unfollow(ctx context.Context, user *models.User, following *Following) error {
+	// Undo refers to the Follow we sent
+	undo := &Activity{
+		Context: DefaultContext(),
+		ID:      NewActivityID(aps.baseURL),
+		Type:    ActivityTypeUndo,
+		Actor:   IRIProperty(user.ActorID),
+		Object: ObjectProperty(map[string]interface{}{
+			"id":     following.ActivityID,
+			"type":   ActivityTypeFollow,
+			"actor":  user.ActorID,
+			"object": following.TargetActorID,
+		}),
+		To:        []string{following.TargetActorID},
+		Published: time.Now().UTC(),
+	}
+
+	err := EnqueueActivity(ctx, aps.deliveryRepo, undo, user, following.TargetInbox)
+	if err != nil {
+		return fmt.Errorf("fail to send undo follow: %w", err)
+	}
+
+	return aps.followingRepo.RemoveFollowing(ctx, user.ID, following.TargetActorID)
+}
+
+// BlockActor remove follows between user and blocked actor in both directions and send Block to the actor
+// nothing is sent when targetInbox is empty
+func (aps *ActivityPubServerService) BlockActor(ctx context.Context, user *models.User, block *models.Block, targetInbox string) error {
+	err := aps.followerRepo.RemoveFollower(ctx, user.ID, block.TargetActorID)
+	if err != nil {
+		return err
+	}
+
+	err = aps.followRequestRepo.RemoveFollowRequest(ctx, user.ID, block.TargetActorID)
+	if err != nil {
+		return err
+	}
+
+	following, err := aps.followingRepo.GetFollowingByTarget(ctx, user.ID, block.TargetActorID)
+	if err == nil {
+		err = aps.unfollow(ctx, user, following)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Block can't be sent when actor's inbox isn't known
+	if targetInbox == "" {
+		return nil
+	}
+
+	activity := &Activity{
+		Context:   DefaultContext(),
+		ID:        block.ActivityID,
+		Type:      ActivityTypeBlock,
+		Actor:     IRIProperty(user.ActorID),
+		Object:    IRIProperty(block.TargetActorID),
+		To:        []string{block.TargetActorID},
+		Published: block.CreatedAt,
+	}
+
+	return EnqueueActivity(ctx, aps.deliveryRepo, activity, user, targetInbox)
+}
+
+// UnblockActor send Undo{Block} to the actor, follows removed by the block aren't restored
+// nothing is sent when targetInbox is empty
+func (aps *ActivityPubServerService) UnblockActor(ctx context.Context, user *models.User, block *models.Block, targetInbox string) error {
+	if targetInbox == "" {
+		return nil
+	}
+
+	// Undo refers to the Block we sent
+	undo := &Activity{
+		Context: DefaultContext(),
+		ID:      NewActivityID(aps.baseURL),
+		Type:    ActivityTypeUndo,
+		Actor:   IRIProperty(user.ActorID),
+		Object: ObjectProperty(map[string]interface{}{
+			"id":     block.ActivityID,
+			"type":   ActivityTypeBlock,
+			"actor":  user.ActorID,
+			"object": block.TargetActorID,
+		}),
+		To:        []string{block.TargetActorID},
+		Published: time.Now().UTC(),
+	}
+
+	return EnqueueActivity(ctx, aps.deliveryRepo, undo, user, targetInbox)
+}
+
+// handleBlockActivity remove follows between user and the actor which blocked user
+// Undo{Block} needs nothing, follows aren't restored
+func (aps *ActivityPubServerService) handleBlockActivity(ctx context.Context, userID uuid.UUID, block *Activity) error {
+	if userID == uuid.Nil {
+		return nil
+	}
+
+	user, err := aps.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("fail to get user: %w", err)
+	}
+
+	objectID := block.Object.IRI()
+	if objectID != user.ActorID {
+		return nil
+	}
+
+	err = aps.followerRepo.RemoveFollower(ctx, userID, block.Actor.IRI())
+	if err != nil {
+		return err
+	}
+
+	err = aps.followRequestRepo.RemoveFollowRequest(ctx, userID, block.Actor.IRI())
+	if err != nil {
+		return err
+	}
+
+	return aps.followingRepo.RemoveFollowing(ctx, userID, block.Actor.IRI())
+}
+
+// PersonToRemoteActor convert fetched actor document to remote actor
+func PersonToRemoteActor(person *Person) *models.RemoteActor {
+	remoteActor := &models.RemoteActor{
+		ActorID:     person.ID,
+		Username:    person.PreferredUsername,
+		DisplayName: person.Name,
+		Inbox:       person.Inbox,
+		URL:         person.URL.IRI(),
+	}
+
+	actorURL, err := url.Parse(person.ID)
+	if err == nil {
+		remoteActor.Domain = actorURL.Host
+	}
+	remoteActor.AvatarURL = person.IconURL()
+	if person.Endpoints != nil {
+		remoteActor.SharedInbox = person.Endpoints.SharedInbox
+	}
+	// a key ID of another server would let actor take over lookups of that server's key
+	if person.PublicKey.PublicKeyPem != "" && isSameHost(person.PublicKey.ID, person.ID) {
+		remoteActor.PublicKeyID = person.PublicKey.ID
+		remoteActor.PublicKeyPem = person.PublicKey.PublicKeyPem
+	}
+
+	document, err := json.Marshal(person)
+	if err == nil {
+		remoteActor.Document = document
+	}
+
+	return remoteActor
+}
+
+// decodeObject decode embedded object of activity
+func decodeObject(object Property) (*Object, error) {
+	var decoded Object
+	err := object.Decode(&decoded)
+	if err != nil {
+		return nil, err
+	}
+
+	return &decoded, nil
+}
+
+// isActorType check if object type is one of the actor types
+func isActorType(objectType string) bool {
+	switch objectType {
+	case ActorTypePerson, ActorTypeService, ActorTypeApplication, ActorTypeGroup, ActorTypeOrganization:
+		return true
+	}
+
+	return false
+}
+
+// isPublic check if addressing contains the public collection
+// https://www.w3.org/TR/activitypub/#public-addressing
+func isPublic(addresses ...[]string) bool {
+	for _, iris := range addresses {
+		for _, iri := range iris {
+			if iri == PublicAddress || iri == "as:Public" || iri == "Public" {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// followerBatchSize number of followers read at once when delivering to all followers
+const followerBatchSize = 500
+
+// PublishCheckin send Create{Note} of a new checkin to all followers of its author and to mentioned actors
+// mentioned local users are notified
+func (aps *ActivityPubServerService) PublishCheckin(ctx context.Context, checkin *models.Checkin) error {
+	author, err := aps.userRepo.GetByID(ctx, checkin.UserID)
+	if err != nil {
+		return fmt.Errorf("fail to get checkin author: %w", err)
+	}
+
+	create := CheckinToCreateActivity(checkin, author.ActorID, aps.baseURL)
+	create.Context = DefaultContext()
+
+	err = aps.SendToFollowers(ctx, create, author, aps.getMentionInboxes(ctx, checkin)...)
+	if err != nil {
+		return err
+	}
+
+	return aps.notifyMentions(ctx, author.ActorID, CheckinObjectID(aps.baseURL, checkin.ID), checkin.ID, checkin.Mentions)
+}
+
+// PublishCheckinUpdate send Update{Note} of an edited checkin to its audience, local users mentioned by the edit are notified
+func (aps *ActivityPubServerService) PublishCheckinUpdate(ctx context.Context, checkin *models.Checkin) error {
+	author, err := aps.userRepo.GetByID(ctx, checkin.UserID)
+	if err != nil {
+		return fmt.Errorf("fail to get checkin author: %w", err)
+	}
+
+	note := CheckinToNote(checkin, author.ActorID, aps.baseURL)
+
+	update := &Activity{
+		Context:   DefaultContext(),
+		ID:        NewActivityID(aps.baseURL),
+		Type:      ActivityTypeUpdate,
+		Actor:     IRIProperty(author.ActorID),
+		Object:    ObjectProperty(note),
+		To:        note.To,
+		Cc:        note.Cc,
+		Published: checkin.UpdatedAt.UTC(),
+	}
+
+	err = aps.sendToCheckinAudience(ctx, update, author, checkin)
+	if err != nil {
+		return err
+	}
+
+	return aps.notifyMentions(ctx, author.ActorID, note.ID, checkin.ID, checkin.Mentions)
+}
+
+// PublishCheckinDelete send Delete of a deleted checkin to its audience
+func (aps *ActivityPubServerService) PublishCheckinDelete(ctx context.Context, checkin *models.Checkin) error {
+	author, err := aps.userRepo.GetByID(ctx, checkin.UserID)
+	if err != nil {
+		return fmt.Errorf("fail to get checkin author: %w", err)
+	}
+
+	now := time.Now().UTC()
+	objectID := CheckinObjectID(aps.baseURL, checkin.ID)
+
+	del := &Activity{
+		Context: DefaultContext(),
+		ID:      NewActivityID(aps.baseURL),
+		Type:    ActivityTypeDelete,
+		Actor:   IRIProperty(author.ActorID),
+		Object: ObjectProperty(&Object{
+			ID:         objectID,
+			Type:       ObjectTypeTombstone,
+			FormerType: ObjectTypeNote,
+			Deleted:    &now,
+		}),
+		To:        []string{PublicAddress},
+		Cc:        []string{fmt.Sprintf("%s/followers", author.ActorID)},
+		Published: now,
+	}
+
+	return aps.sendToCheckinAudience(ctx, del, author, checkin)
+}
+
+// PublishActorUpdate send Update{Person} with user's current actor document to all followers
+func (aps *ActivityPubServerService) PublishActorUpdate(ctx context.Context, user *models.User) error {
+	person, err := aps.actorService.GetActor(ctx, user, aps.baseURL)
+	if err != nil {
+		return fmt.Errorf("fail to get actor: %w", err)
+	}
+
+	// context is set on the activity
+	person.Context = nil
+
+	update := &Activity{
+		Context:   DefaultContext(),
+		ID:        NewActivityID(aps.baseURL),
+		Type:      ActivityTypeUpdate,
+		Actor:     IRIProperty(person.ID),
+		Object:    ObjectProperty(person),
+		To:        []string{PublicAddress},
+		Cc:        []string{person.Followers},
+		Published: user.UpdatedAt.UTC(),
+	}
+
+	return aps.SendToFollowers(ctx, update, user)
+}
+
+// sendToCheckinAudience queue activity about a checkin for every inbox its Create was queued for, for followers
+// and for actors mentioned in checkin
+func (aps *ActivityPubServerService) sendToCheckinAudience(ctx context.Context, activity *Activity, author *models.User, checkin *models.Checkin) error {
+	inboxes, err := aps.deliveryRepo.GetInboxesByActivityID(ctx, checkin.ActivityID)
+	if err != nil {
+		return err
+	}
+
+	inboxes = append(inboxes, aps.getMentionInboxes(ctx, checkin)...)
+
+	return aps.SendToFollowers(ctx, activity, author, inboxes...)
+}
+
+// getMentionInboxes get inboxes of remote actors mentioned in checkin, shared inbox is preferred
+// actors which can't be fetched are skipped
+func (aps *ActivityPubServerService) getMentionInboxes(ctx context.Context, checkin *models.Checkin) []string {
+	var inboxes []string
+
+	for _, mention := range checkin.Mentions {
+		if aps.isLocalIRI(mention.ActorID) {
+			continue
+		}
+
+		actor, err := aps.actorCache.GetRemoteActor(ctx, mention.ActorID)
+		if err != nil {
+			continue
+		}
+
+		inbox := actor.SharedInbox
+		if inbox == "" {
+			inbox = actor.Inbox
+		}
+		inboxes = append(inboxes, inbox)
+	}
+
+	return inboxes
+}
+
+// ResolveMentions resolve accounts mentioned in plain text checkin content
+// "@user" and "@user@this-host" are local users, other accounts are resolved with WebFinger
+// accounts which can't be resolved are skipped, they stay plain text in content
+func (aps *ActivityPubServerService) ResolveMentions(ctx context.Context, content string) []models.Mention {
+	var mentions []models.Mention
+	seen := make(map[string]bool)
+
+	for _, account := range ParseMentions(content) {
+		username, host, found := strings.Cut(account, "@")
+
+		var mention models.Mention
+		if !found || strings.EqualFold(host, aps.serverHost) {
+			user, err := aps.userRepo.GetByUsername(ctx, username)
+			if err != nil {
+				continue
+			}
+
+			mention = models.Mention{
+				ActorID: user.ActorID,
+				Acct:    fmt.Sprintf("%s@%s", user.Username, aps.serverHost),
+				URL:     ProfileURL(aps.baseURL, user.Username),
+			}
+		} else {
+			if checkDomainBlocked(ctx, aps.domainBlockRepo, "https://"+host) != nil {
+				continue
+			}
+
+			resolved, err := aps.clientService.ResolveAccount(ctx, account)
+			if err != nil {
+				continue
+			}
+
+			// cache actor through the cache's own fetch, its inbox is needed to deliver the checkin
+			person, err := aps.actorCache.GetActor(ctx, resolved.ID)
+			if err != nil {
+				continue
+			}
+
+			// keep username case of the actor
+			if person.PreferredUsername != "" {
+				account = fmt.Sprintf("%s@%s", person.PreferredUsername, host)
+			}
+
+			mention = models.Mention{
+				ActorID: person.ID,
+				Acct:    account,
+				URL:     person.URL.IRI(),
+			}
+		}
+
+		if !seen[mention.ActorID] {
+			seen[mention.ActorID] = true
+			mentions = append(mentions, mention)
+		}
+	}
+
+	return mentions
+}
+
+// notifyMentions notify local users mentioned in a Note of author, checkinID is uuid.Nil when Note isn't stored
+// author isn't notified of mentioning themselves, and users who blocked author aren't notified
+func (aps *ActivityPubServerService) notifyMentions(ctx context.Context, authorActorID, objectID string, checkinID uuid.UUID, mentions []models.Mention) error {
+	var errs []error
+
+	for _, mention := range mentions {
+		if mention.ActorID == authorActorID || !aps.isLocalIRI(mention.ActorID) {
+			continue
+		}
+
+		user, err := aps.userRepo.GetByActorID(ctx, mention.ActorID)
+		if err != nil {
+			continue
+		}
+
+		blocked, err := aps.blockRepo.IsBlocked(ctx, user.ID, authorActorID)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if blocked {
+			continue
+		}
+
+		err = aps.notificationRepo.AddNotification(ctx, &models.Notification{
+			UserID:    user.ID,
+			Type:      models.NotificationTypeMention,
+			ActorID:   authorActorID,
+			ObjectID:  objectID,
+			CheckinID: checkinID,
+		})
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// SendToFollowers queue activity for all followers of sender and other inboxes
+// followers on the same server share one delivery when the server has a shared inbox
+func (aps *ActivityPubServerService) SendToFollowers(ctx context.Context, activity *Activity, sender *models.User, inboxes ...string) error {
+	followerInboxes, err := aps.getFollowerInboxes(ctx, sender.ID)
+	if err != nil {
+		return err
+	}
+
+	return EnqueueActivity(ctx, aps.deliveryRepo, activity, sender, append(followerInboxes, inboxes...)...)
+}
+
+// getFollowerInboxes get deduplicated inboxes of all user's followers, shared inbox is preferred
+func (aps *ActivityPubServerService) getFollowerInboxes(ctx context.Context, userID uuid.UUID) ([]string, error) {
+	seen := make(map[string]bool)
+	var inboxes []string
+
+	cursor := uuid.Nil
+	for {
+		followers, err := aps.followerRepo.GetFollowers(ctx, userID, cursor, followerBatchSize)
+		if err != nil {
+			return nil, fmt.Errorf("fail to get followers: %w", err)
+		}
+
+		for _, follower := range followers {
+			inbox := follower.SharedInbox
+			if inbox == "" {
+				inbox = follower.Inbox
+			}
+
+			if !seen[inbox] {
+				seen[inbox] = true
+				inboxes = append(inboxes, inbox)
+			}
+		}
+
+		if len(followers) < followerBatchSize {
+			return inboxes, nil
+		}
+		cursor = followers[len(followers)-1].ID
+	}
+}
+
+// SendActivityToInbox queue an activity for a user's inbox, it's posted by DeliveryWorker
+func (aps *ActivityPubServerService) SendActivityToInbox(ctx context.Context, activity *Activity, sender *models.User, targetInbox string) error {
+	return EnqueueActivity(ctx, aps.deliveryRepo, activity, sender, targetInbox)
+}
+
+// GetFollowers get a batch of user's followers after cursor
+func (aps *ActivityPubServerService) GetFollowers(ctx context.Context, userID uuid.UUID, cursor uuid.UUID, limit int) ([]Follower, error) {
+	return aps.followerRepo.GetFollowers(ctx, userID, cursor, limit)
+}
+
+// CountFollowers
+func (aps *ActivityPubServerService) CountFollowers(ctx context.Context, userID uuid.UUID) (int, error) {
+	return aps.followerRepo.CountFollowers(ctx, userID)
+}
+
+// GetFollowing get a batch of accepted remote actors user follows after cursor
+func (aps *ActivityPubServerService) GetFollowing(ctx context.Context, userID uuid.UUID, cursor uuid.UUID, limit int) ([]Following, error) {
+	return aps.followingRepo.GetFollowing(ctx, userID, cursor, limit)
+}
+
+// CountFollowing
+func (aps *ActivityPubServerService) CountFollowing(ctx context.Context, userID uuid.UUID) (int, error) {
+	return aps.followingRepo.CountFollowing(ctx, userID)
+}
+
+// GetLiked get likes of a local user
+func (aps *ActivityPubServerService) GetLiked(ctx context.Context, userID uuid.UUID, cursor uuid.UUID, limit int) ([]models.Like, error) {
+	return aps.likeRepo.GetLikedByUserID(ctx, userID, cursor, limit)
+}
+
+// CountLiked count likes of a local user
+func (aps *ActivityPubServerService) CountLiked(ctx context.Context, userID uuid.UUID) (int, error) {
+	return aps.likeRepo.CountLikedByUserID(ctx, userID)
+}
+
+// GetShares get shares of a checkin
+func (aps *ActivityPubServerService) GetShares(ctx context.Context, checkinID uuid.UUID, cursor uuid.UUID, limit int) ([]models.Share, error) {
+	return aps.shareRepo.GetSharesByCheckinID(ctx, checkinID, cursor, limit)
 }
 
 // GetUserInboxActivities
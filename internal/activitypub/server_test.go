@@ -0,0 +1,49 @@
+package activitypub
+
+import (
+	"testing"
+)
+
+func TestPersonToRemoteActorPublicKey(t *testing.T) {
+	tests := []struct {
+		name      string
+		actorID   string
+		keyID     string
+		wantKeyID string
+	}{
+		{
+			name:      "key ID is actor ID with fragment",
+			actorID:   testActorID,
+			keyID:     testKeyID,
+			wantKeyID: testKeyID,
+		},
+		{
+			name:      "key ID is another path of actor's server",
+			actorID:   "https://gts.example/users/erin",
+			keyID:     "https://gts.example/users/erin/main-key",
+			wantKeyID: "https://gts.example/users/erin/main-key",
+		},
+		{
+			name:    "key ID of another server",
+			actorID: "https://remote.example/users/mallory",
+			keyID:   "https://other.example/users/victim#main-key",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			person := testPerson("-----BEGIN PUBLIC KEY-----\n-----END PUBLIC KEY-----\n")
+			person.ID = tt.actorID
+			person.PublicKey.ID = tt.keyID
+			person.PublicKey.Owner = tt.actorID
+
+			remoteActor := PersonToRemoteActor(person)
+			if remoteActor.PublicKeyID != tt.wantKeyID {
+				t.Errorf("got key ID %q, want %q", remoteActor.PublicKeyID, tt.wantKeyID)
+			}
+			if tt.wantKeyID == "" && remoteActor.PublicKeyPem != "" {
+				t.Errorf("got key %q of dropped key ID", remoteActor.PublicKeyPem)
+			}
+		})
+	}
+}
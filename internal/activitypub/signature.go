@@ -0,0 +1,614 @@
+package activitypub
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"hash"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HTTP Signature const
+const (
+	// max age of a signed request, based on Date header or (created) parameter
+	maxSignatureAge = 12 * time.Hour
+	// max difference allowed when remote clock is ahead of ours
+	maxClockSkew = time.Hour
+)
+
+// ErrInvalidSignature request signature is missing, malformed or doesn't match
+var ErrInvalidSignature = errors.New("invalid http signature")
+
+// SignatureVerifier verify HTTP Signatures of inbound requests
+// https://datatracker.ietf.org/doc/html/draft-cavage-http-signatures-12
+type SignatureVerifier struct {
+	actorCache RemoteActorCache
+	now        func() time.Time
+}
+
+// signingKey public key found in key owner's actor document
+type signingKey struct {
+	owner string
+	key   crypto.PublicKey
+}
+
+// signatureParams parsed Signature header
+type signatureParams struct {
+	keyID     string
+	algorithm string
+	headers   []string
+	signature []byte
+	created   string
+	expires   string
+}
+
+// NewSignatureVerifier
+// keys are read from remote actor cache, actor is fetched again once when signature doesn't match cached key
+func NewSignatureVerifier(actorCache RemoteActorCache) *SignatureVerifier {
+	return &SignatureVerifier{
+		actorCache: actorCache,
+		now:        time.Now,
+	}
+}
+
+// VerifyRequest verify request signature and body digest
+// both draft-cavage signatures and RFC 9421 HTTP Message Signatures are supported
+// return actor ID owning the signing key
+func (sv *SignatureVerifier) VerifyRequest(ctx context.Context, r *http.Request, body []byte) (string, error) {
+	if r.Header.Get("Signature-Input") != "" {
+		return sv.verifyRFC9421Request(ctx, r, body)
+	}
+
+	return sv.verifyCavageRequest(ctx, r, body)
+}
+
+// verifyCavageRequest verify draft-cavage Signature header
+func (sv *SignatureVerifier) verifyCavageRequest(ctx context.Context, r *http.Request, body []byte) (string, error) {
+	// parse Signature header
+	params, err := parseSignatureHeader(r)
+	if err != nil {
+		return "", err
+	}
+
+	// check required headers are signed
+	err = sv.checkSignedHeaders(r, params, len(body) > 0)
+	if err != nil {
+		return "", err
+	}
+
+	// check body digest
+	if len(body) > 0 {
+		err = verifyDigest(r.Header.Get("Digest"), body)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	// build the string which was signed
+	signingString, err := buildSigningString(r, params)
+	if err != nil {
+		return "", err
+	}
+
+	// verify signature with cached key, then with a fetched one in case remote key was rotated
+	var errs []error
+	for _, refresh := range []bool{false, true} {
+		key, err := sv.getPublicKey(ctx, params.keyID, refresh)
+		if err == nil {
+			err = verifySignature(key.key, params.algorithm, []byte(signingString), params.signature)
+		}
+		if err == nil {
+			return key.owner, nil
+		}
+
+		errs = append(errs, err)
+	}
+
+	return "", errors.Join(errs...)
+}
+
+// verifyRFC9421Request verify RFC 9421 Signature-Input and Signature headers
+// https://www.rfc-editor.org/rfc/rfc9421#section-3.2
+func (sv *SignatureVerifier) verifyRFC9421Request(ctx context.Context, r *http.Request, body []byte) (string, error) {
+	input, signature, err := parseRFC9421Headers(r)
+	if err != nil {
+		return "", err
+	}
+
+	// check required components are covered
+	covered := make(map[string]bool)
+	for _, component := range input.components {
+		covered[component] = true
+	}
+
+	if !covered["@method"] || !(covered["@target-uri"] || (covered["@authority"] && covered["@path"])) {
+		return "", fmt.Errorf("%w: @method and @target-uri must be signed", ErrInvalidSignature)
+	}
+
+	if len(body) > 0 {
+		if !covered["content-digest"] {
+			return "", fmt.Errorf("%w: content-digest must be signed", ErrInvalidSignature)
+		}
+
+		err = verifyContentDigest(r.Header.Get("Content-Digest"), body)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	// check signature creation and expiration time
+	now := sv.now()
+
+	created, err := strconv.ParseInt(input.created, 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("%w: invalid created parameter", ErrInvalidSignature)
+	}
+
+	signedAt := time.Unix(created, 0)
+	if signedAt.Before(now.Add(-maxSignatureAge)) || signedAt.After(now.Add(maxClockSkew)) {
+		return "", fmt.Errorf("%w: signature date is out of accepted window", ErrInvalidSignature)
+	}
+
+	if input.expires != "" {
+		expires, err := strconv.ParseInt(input.expires, 10, 64)
+		if err != nil || now.After(time.Unix(expires, 0)) {
+			return "", fmt.Errorf("%w: signature is expired", ErrInvalidSignature)
+		}
+	}
+
+	// scheme isn't part of the request behind a TLS terminating proxy, so both are tried
+	var targetURIs []string
+	switch {
+	case r.Header.Get("X-Forwarded-Proto") != "":
+		targetURIs = []string{fmt.Sprintf("%s://%s%s", r.Header.Get("X-Forwarded-Proto"), r.Host, r.URL.RequestURI())}
+	case r.TLS != nil:
+		targetURIs = []string{fmt.Sprintf("https://%s%s", r.Host, r.URL.RequestURI())}
+	default:
+		targetURIs = []string{
+			fmt.Sprintf("https://%s%s", r.Host, r.URL.RequestURI()),
+			fmt.Sprintf("http://%s%s", r.Host, r.URL.RequestURI()),
+		}
+	}
+
+	var signatureBases []string
+	for _, targetURI := range targetURIs {
+		signatureBase, err := buildRFC9421SignatureBase(r, targetURI, input)
+		if err != nil {
+			return "", err
+		}
+
+		signatureBases = append(signatureBases, signatureBase)
+	}
+
+	// verify signature with cached key, then with a fetched one in case remote key was rotated
+	for _, refresh := range []bool{false, true} {
+		key, err := sv.getPublicKey(ctx, input.keyID, refresh)
+		if err != nil {
+			if refresh {
+				return "", err
+			}
+			continue
+		}
+
+		for _, signatureBase := range signatureBases {
+			err = verifySignature(key.key, input.algorithm, []byte(signatureBase), signature)
+			if err == nil {
+				return key.owner, nil
+			}
+		}
+	}
+
+	return "", fmt.Errorf("%w: signature doesn't match", ErrInvalidSignature)
+}
+
+// checkSignedHeaders check signature covers request target, host, date and digest, and isn't expired
+func (sv *SignatureVerifier) checkSignedHeaders(r *http.Request, params *signatureParams, hasBody bool) error {
+	signed := make(map[string]bool)
+	for _, header := range params.headers {
+		signed[header] = true
+	}
+
+	if !signed["(request-target)"] || !signed["host"] {
+		return fmt.Errorf("%w: (request-target) and host must be signed", ErrInvalidSignature)
+	}
+
+	if hasBody && !signed["digest"] {
+		return fmt.Errorf("%w: digest must be signed", ErrInvalidSignature)
+	}
+
+	now := sv.now()
+
+	// get signature creation time
+	var signedAt time.Time
+	switch {
+	case signed["(created)"]:
+		created, err := strconv.ParseInt(params.created, 10, 64)
+		if err != nil {
+			return fmt.Errorf("%w: invalid created parameter", ErrInvalidSignature)
+		}
+		signedAt = time.Unix(created, 0)
+
+	case signed["date"]:
+		date, err := http.ParseTime(r.Header.Get("Date"))
+		if err != nil {
+			return fmt.Errorf("%w: invalid date header", ErrInvalidSignature)
+		}
+		signedAt = date
+
+	default:
+		return fmt.Errorf("%w: date or (created) must be signed", ErrInvalidSignature)
+	}
+
+	if signedAt.Before(now.Add(-maxSignatureAge)) || signedAt.After(now.Add(maxClockSkew)) {
+		return fmt.Errorf("%w: signature date is out of accepted window", ErrInvalidSignature)
+	}
+
+	if params.expires != "" {
+		expires, err := strconv.ParseInt(params.expires, 10, 64)
+		if err != nil {
+			return fmt.Errorf("%w: invalid expires parameter", ErrInvalidSignature)
+		}
+
+		if now.After(time.Unix(expires, 0)) {
+			return fmt.Errorf("%w: signature is expired", ErrInvalidSignature)
+		}
+	}
+
+	return nil
+}
+
+// getPublicKey get signing key from key owner's cached actor document
+// with refresh, actor document is fetched again even when cached one isn't expired
+func (sv *SignatureVerifier) getPublicKey(ctx context.Context, keyID string, refresh bool) (*signingKey, error) {
+	// key ID is usually actor ID with "#main-key" fragment
+	actorURL, _, _ := strings.Cut(keyID, "#")
+
+	var actor *Person
+	var err error
+	if refresh {
+		actor, err = sv.actorCache.RefreshActor(ctx, actorURL)
+	} else {
+		actor, err = sv.actorCache.GetActor(ctx, actorURL)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("fail to fetch signing key owner: %w", err)
+	}
+
+	if actor.PublicKey.ID != keyID || actor.PublicKey.PublicKeyPem == "" {
+		return nil, fmt.Errorf("%w: key %s not found in actor document", ErrInvalidSignature, keyID)
+	}
+
+	// key belongs to the actor whose document lists it, a document can't claim keys for another actor
+	if actor.PublicKey.Owner != "" && actor.PublicKey.Owner != actor.ID {
+		return nil, fmt.Errorf("%w: key %s is owned by %s, not by actor %s", ErrInvalidSignature, keyID, actor.PublicKey.Owner, actor.ID)
+	}
+	owner := actor.ID
+
+	key, err := ParsePublicKeyPEM(actor.PublicKey.PublicKeyPem)
+	if err != nil {
+		return nil, err
+	}
+
+	return &signingKey{
+		owner: owner,
+		key:   key,
+	}, nil
+}
+
+// parseSignatureHeader parse Signature header, or Authorization header with "Signature" scheme
+func parseSignatureHeader(r *http.Request) (*signatureParams, error) {
+	header := r.Header.Get("Signature")
+	if header == "" {
+		authorization := r.Header.Get("Authorization")
+		scheme, value, found := strings.Cut(authorization, " ")
+		if found && strings.EqualFold(scheme, "Signature") {
+			header = value
+		}
+	}
+
+	if header == "" {
+		return nil, fmt.Errorf("%w: missing signature header", ErrInvalidSignature)
+	}
+
+	values := parseParamList(header)
+
+	params := &signatureParams{
+		keyID:     values["keyid"],
+		algorithm: strings.ToLower(values["algorithm"]),
+		created:   values["created"],
+		expires:   values["expires"],
+	}
+
+	if params.keyID == "" || values["signature"] == "" {
+		return nil, fmt.Errorf("%w: missing keyId or signature", ErrInvalidSignature)
+	}
+
+	// default signed header is date
+	headers := values["headers"]
+	if headers == "" {
+		headers = "date"
+	}
+	params.headers = strings.Fields(strings.ToLower(headers))
+
+	signature, err := base64.StdEncoding.DecodeString(values["signature"])
+	if err != nil {
+		return nil, fmt.Errorf("%w: signature isn't base64 encoded", ErrInvalidSignature)
+	}
+	params.signature = signature
+
+	return params, nil
+}
+
+// parseRFC9421Headers parse the first signature in Signature-Input header and its value in Signature header
+// https://www.rfc-editor.org/rfc/rfc9421#section-4
+func parseRFC9421Headers(r *http.Request) (*rfc9421SignatureInput, []byte, error) {
+	members := splitTopLevel(r.Header.Get("Signature-Input"), ',')
+	if len(members) == 0 {
+		return nil, nil, fmt.Errorf("%w: missing signature-input header", ErrInvalidSignature)
+	}
+
+	label, raw, found := strings.Cut(strings.TrimSpace(members[0]), "=")
+	if !found || !strings.HasPrefix(raw, "(") {
+		return nil, nil, fmt.Errorf("%w: malformed signature-input header", ErrInvalidSignature)
+	}
+
+	input := &rfc9421SignatureInput{
+		label: label,
+		raw:   raw,
+	}
+
+	// covered components inner list
+	end := strings.Index(raw, ")")
+	if end < 0 {
+		return nil, nil, fmt.Errorf("%w: malformed signature-input header", ErrInvalidSignature)
+	}
+
+	for _, component := range strings.Fields(raw[1:end]) {
+		unquoted, err := strconv.Unquote(component)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: malformed component %s", ErrInvalidSignature, component)
+		}
+		input.components = append(input.components, strings.ToLower(unquoted))
+	}
+
+	// signature parameters
+	for _, param := range splitTopLevel(raw[end+1:], ';') {
+		key, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+		if unquoted, err := strconv.Unquote(value); err == nil {
+			value = unquoted
+		}
+
+		switch key {
+		case "created":
+			input.created = value
+		case "expires":
+			input.expires = value
+		case "keyid":
+			input.keyID = value
+		case "alg":
+			input.algorithm = value
+		}
+	}
+
+	if input.keyID == "" {
+		return nil, nil, fmt.Errorf("%w: missing keyid", ErrInvalidSignature)
+	}
+
+	// find signature with the same label
+	for _, member := range splitTopLevel(r.Header.Get("Signature"), ',') {
+		signatureLabel, value, found := strings.Cut(strings.TrimSpace(member), "=")
+		if !found || signatureLabel != label {
+			continue
+		}
+
+		signature, err := base64.StdEncoding.DecodeString(strings.Trim(value, ":"))
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: signature isn't base64 encoded", ErrInvalidSignature)
+		}
+
+		return input, signature, nil
+	}
+
+	return nil, nil, fmt.Errorf("%w: missing signature %s", ErrInvalidSignature, label)
+}
+
+// splitTopLevel split s by sep, ignoring separators inside quotes or parentheses
+func splitTopLevel(s string, sep byte) []string {
+	var parts []string
+	var quoted bool
+	var depth int
+
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '"':
+			quoted = !quoted
+		case '(':
+			if !quoted {
+				depth++
+			}
+		case ')':
+			if !quoted {
+				depth--
+			}
+		case sep:
+			if !quoted && depth == 0 {
+				parts = append(parts, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+
+	if start < len(s) {
+		parts = append(parts, s[start:])
+	}
+
+	return parts
+}
+
+// parseParamList parse comma separated key="value" list, keys are lowercased
+func parseParamList(header string) map[string]string {
+	values := make(map[string]string)
+
+	rest := header
+	for rest != "" {
+		var key string
+		key, rest, _ = strings.Cut(rest, "=")
+		key = strings.ToLower(strings.TrimSpace(strings.TrimLeft(key, ", ")))
+
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			// quoted value ends at the next quote
+			end := strings.Index(rest[1:], `"`)
+			if end < 0 {
+				value, rest = rest[1:], ""
+			} else {
+				value, rest = rest[1:end+1], rest[end+2:]
+			}
+		} else {
+			value, rest, _ = strings.Cut(rest, ",")
+		}
+
+		if key != "" {
+			values[key] = strings.TrimSpace(value)
+		}
+	}
+
+	return values
+}
+
+// buildSigningString build signing string from signed headers
+// https://datatracker.ietf.org/doc/html/draft-cavage-http-signatures-12#section-2.3
+func buildSigningString(r *http.Request, params *signatureParams) (string, error) {
+	lines := make([]string, 0, len(params.headers))
+
+	for _, header := range params.headers {
+		var value string
+
+		switch header {
+		case "(request-target)":
+			value = fmt.Sprintf("%s %s", strings.ToLower(r.Method), r.URL.RequestURI())
+		case "(created)":
+			value = params.created
+		case "(expires)":
+			value = params.expires
+		case "host":
+			value = r.Host
+		default:
+			headerValues := r.Header.Values(header)
+			if len(headerValues) == 0 {
+				return "", fmt.Errorf("%w: signed header %s is missing", ErrInvalidSignature, header)
+			}
+			value = strings.Join(headerValues, ", ")
+		}
+
+		lines = append(lines, fmt.Sprintf("%s: %s", header, value))
+	}
+
+	return strings.Join(lines, "\n"), nil
+}
+
+// verifyDigest check Digest header matches request body
+// https://datatracker.ietf.org/doc/html/rfc3230
+func verifyDigest(digestHeader string, body []byte) error {
+	if digestHeader == "" {
+		return fmt.Errorf("%w: missing digest header", ErrInvalidSignature)
+	}
+
+	for _, digest := range strings.Split(digestHeader, ",") {
+		algorithm, value, found := strings.Cut(strings.TrimSpace(digest), "=")
+		if !found {
+			continue
+		}
+
+		var h hash.Hash
+		switch strings.ToUpper(algorithm) {
+		case "SHA-256":
+			h = sha256.New()
+		case "SHA-512":
+			h = sha512.New()
+		default:
+			continue
+		}
+
+		h.Write(body)
+		if base64.StdEncoding.EncodeToString(h.Sum(nil)) != value {
+			return fmt.Errorf("%w: digest doesn't match body", ErrInvalidSignature)
+		}
+
+		return nil
+	}
+
+	return fmt.Errorf("%w: no supported digest algorithm", ErrInvalidSignature)
+}
+
+// verifySignature verify signature with RSA or Ed25519 public key
+func verifySignature(key crypto.PublicKey, algorithm string, signingString, signature []byte) error {
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		switch algorithm {
+		case "", "rsa-sha256", "hs2019", "rsa-v1_5-sha256":
+			digest := sha256.Sum256(signingString)
+			err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature)
+			if err != nil {
+				return fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+			}
+
+		case "rsa-pss-sha512":
+			digest := sha512.Sum512(signingString)
+			err := rsa.VerifyPSS(key, crypto.SHA512, digest[:], signature, nil)
+			if err != nil {
+				return fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+			}
+
+		default:
+			return fmt.Errorf("%w: unsupported algorithm %s for RSA key", ErrInvalidSignature, algorithm)
+		}
+
+	case ed25519.PublicKey:
+		if algorithm != "" && algorithm != "ed25519" && algorithm != "hs2019" {
+			return fmt.Errorf("%w: unsupported algorithm %s for Ed25519 key", ErrInvalidSignature, algorithm)
+		}
+
+		if !ed25519.Verify(key, signingString, signature) {
+			return fmt.Errorf("%w: signature doesn't match", ErrInvalidSignature)
+		}
+
+	default:
+		return fmt.Errorf("%w: unsupported key type", ErrInvalidSignature)
+	}
+
+	return nil
+}
+
+// ParsePublicKeyPEM parse PEM encoded PKIX or PKCS#1 public key
+func ParsePublicKeyPEM(publicKeyPEM string) (crypto.PublicKey, error) {
+	block, _ := pem.Decode([]byte(publicKeyPEM))
+	if block == nil {
+		return nil, fmt.Errorf("fail to decode public key")
+	}
+
+	// PKIX is the common format, but "RSA PUBLIC KEY" blocks may hold either format
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err == nil {
+		return key, nil
+	}
+
+	rsaKey, err := x509.ParsePKCS1PublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("fail to parse public key: %w", err)
+	}
+
+	return rsaKey, nil
+}
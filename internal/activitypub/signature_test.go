@@ -0,0 +1,454 @@
+package activitypub
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"je-suis-ici-activitypub/internal/db/models"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	testActorID = "https://remote.example/users/alice"
+	testKeyID   = testActorID + "#main-key"
+	testInbox   = "https://ici.example/users/bob/inbox"
+)
+
+// stubHTTPClient serve JSON documents by URL, other URLs are not found
+type stubHTTPClient struct {
+	documents map[string]interface{}
+}
+
+// Do
+func (c *stubHTTPClient) Do(req *http.Request) (*http.Response, error) {
+	document, ok := c.documents[req.URL.String()]
+	if !ok {
+		return &http.Response{
+			StatusCode: http.StatusNotFound,
+			Body:       io.NopCloser(strings.NewReader("")),
+		}, nil
+	}
+
+	data, err := json.Marshal(document)
+	if err != nil {
+		return nil, err
+	}
+
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"application/activity+json"}},
+		Body:       io.NopCloser(bytes.NewReader(data)),
+	}, nil
+}
+
+// stubActorCache remote actor cache reading actors from cached map, missing actors are fetched with client
+type stubActorCache struct {
+	clientService ActivityPubClientService
+	cached        map[string]*Person
+}
+
+// GetActor
+func (c *stubActorCache) GetActor(ctx context.Context, actorID string) (*Person, error) {
+	if person, ok := c.cached[actorID]; ok {
+		return person, nil
+	}
+
+	return c.RefreshActor(ctx, actorID)
+}
+
+// RefreshActor
+func (c *stubActorCache) RefreshActor(ctx context.Context, actorID string) (*Person, error) {
+	person, err := c.clientService.FetchActorPublicInformation(ctx, actorID)
+	if err != nil {
+		return nil, err
+	}
+
+	c.cached[actorID] = person
+	return person, nil
+}
+
+// GetRemoteActor
+func (c *stubActorCache) GetRemoteActor(ctx context.Context, actorID string) (*models.RemoteActor, error) {
+	return nil, fmt.Errorf("not implemented")
+}
+
+// GetCachedActor
+func (c *stubActorCache) GetCachedActor(ctx context.Context, actorID string) (*models.RemoteActor, error) {
+	return nil, fmt.Errorf("not implemented")
+}
+
+// StoreActor
+func (c *stubActorCache) StoreActor(ctx context.Context, person *Person) (*models.RemoteActor, error) {
+	return nil, fmt.Errorf("not implemented")
+}
+
+// newTestKey generate RSA key and its PEM encoded public key
+func newTestKey(t *testing.T) (*rsa.PrivateKey, string) {
+	t.Helper()
+
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("fail to generate key: %v", err)
+	}
+
+	der, err := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
+	if err != nil {
+		t.Fatalf("fail to encode public key: %v", err)
+	}
+
+	return privateKey, string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+}
+
+// testPerson actor document of testActorID with publicKeyPem
+func testPerson(publicKeyPem string) *Person {
+	return &Person{
+		ID:    testActorID,
+		Type:  "Person",
+		Inbox: testActorID + "/inbox",
+		PublicKey: PublicKey{
+			ID:           testKeyID,
+			Owner:        testActorID,
+			PublicKeyPem: publicKeyPem,
+		},
+	}
+}
+
+// newTestVerifier verifier fetching actor documents from stub HTTP client
+func newTestVerifier(documents map[string]interface{}) (*SignatureVerifier, *stubActorCache) {
+	actorCache := &stubActorCache{
+		clientService: NewActivityPubClientService(&stubHTTPClient{documents: documents}),
+		cached:        make(map[string]*Person),
+	}
+
+	return NewSignatureVerifier(actorCache), actorCache
+}
+
+// newInboxRequest POST request to testInbox
+func newInboxRequest(t *testing.T, body []byte) *http.Request {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodPost, testInbox, bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("fail to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/activity+json")
+
+	return req
+}
+
+// signCavageHeaders sign headers of request with draft-cavage rsa-sha256, for signatures our signer never makes
+func signCavageHeaders(t *testing.T, req *http.Request, privateKey *rsa.PrivateKey, headers []string) {
+	t.Helper()
+
+	signingString, err := buildSigningString(req, &signatureParams{headers: headers})
+	if err != nil {
+		t.Fatalf("fail to build signing string: %v", err)
+	}
+
+	signature, err := signPKCS1v15(privateKey, []byte(signingString))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req.Header.Set("Signature", fmt.Sprintf(`keyId="%s",algorithm="rsa-sha256",headers="%s",signature="%s"`,
+		testKeyID, strings.Join(headers, " "), base64.StdEncoding.EncodeToString(signature)))
+}
+
+func TestVerifyRequest(t *testing.T) {
+	privateKey, publicKeyPem := newTestKey(t)
+	body := []byte(`{"type":"Follow","actor":"https://remote.example/users/alice"}`)
+
+	// signWith sign request and signed body with our signer of scheme
+	signWith := func(scheme SignatureScheme, signed []byte) func(t *testing.T, req *http.Request) {
+		return func(t *testing.T, req *http.Request) {
+			err := NewRequestSigner(scheme).SignRequest(req, signed, testKeyID, privateKey)
+			if err != nil {
+				t.Fatalf("fail to sign request: %v", err)
+			}
+		}
+	}
+
+	tests := []struct {
+		name string
+		sign func(t *testing.T, req *http.Request)
+		// received body, signed one when nil
+		received []byte
+		// offset of receiving server clock, a negative one makes the signature look ahead
+		offset  time.Duration
+		wantErr bool
+	}{
+		{
+			name: "draft-cavage rsa-sha256",
+			sign: signWith(SignatureSchemeCavage, body),
+		},
+		{
+			name: "draft-cavage hs2019",
+			sign: signWith(SignatureSchemeCavageHS2019, body),
+		},
+		{
+			name: "RFC 9421",
+			sign: signWith(SignatureSchemeRFC9421, body),
+		},
+		{
+			name: "draft-cavage in Authorization header",
+			sign: func(t *testing.T, req *http.Request) {
+				signWith(SignatureSchemeCavage, body)(t, req)
+				req.Header.Set("Authorization", "Signature "+req.Header.Get("Signature"))
+				req.Header.Del("Signature")
+			},
+		},
+		{
+			name:    "draft-cavage date too old",
+			sign:    signWith(SignatureSchemeCavage, body),
+			offset:  maxSignatureAge + time.Minute,
+			wantErr: true,
+		},
+		{
+			name:    "draft-cavage date ahead of allowed skew",
+			sign:    signWith(SignatureSchemeCavage, body),
+			offset:  -maxClockSkew - time.Minute,
+			wantErr: true,
+		},
+		{
+			name:   "draft-cavage date within allowed skew",
+			sign:   signWith(SignatureSchemeCavage, body),
+			offset: -maxClockSkew + time.Minute,
+		},
+		{
+			name:    "hs2019 created too old",
+			sign:    signWith(SignatureSchemeCavageHS2019, body),
+			offset:  maxSignatureAge + time.Minute,
+			wantErr: true,
+		},
+		{
+			name:    "RFC 9421 created ahead of allowed skew",
+			sign:    signWith(SignatureSchemeRFC9421, body),
+			offset:  -maxClockSkew - time.Minute,
+			wantErr: true,
+		},
+		{
+			name:     "draft-cavage digest mismatch",
+			sign:     signWith(SignatureSchemeCavage, body),
+			received: []byte(`{"type":"Delete","actor":"https://remote.example/users/alice"}`),
+			wantErr:  true,
+		},
+		{
+			name:     "RFC 9421 content-digest mismatch",
+			sign:     signWith(SignatureSchemeRFC9421, body),
+			received: []byte(`{"type":"Delete","actor":"https://remote.example/users/alice"}`),
+			wantErr:  true,
+		},
+		{
+			name: "draft-cavage signed digest header changed with body",
+			sign: func(t *testing.T, req *http.Request) {
+				signWith(SignatureSchemeCavage, body)(t, req)
+				req.Header.Set("Digest", "SHA-256="+sha256Base64([]byte("{}")))
+			},
+			received: []byte("{}"),
+			wantErr:  true,
+		},
+		{
+			name: "draft-cavage request-target not signed",
+			sign: func(t *testing.T, req *http.Request) {
+				req.Header.Set("Date", time.Now().UTC().Format(http.TimeFormat))
+				req.Header.Set("Digest", "SHA-256="+sha256Base64(body))
+				signCavageHeaders(t, req, privateKey, []string{"host", "date", "digest"})
+			},
+			wantErr: true,
+		},
+		{
+			name: "draft-cavage digest not signed",
+			sign: func(t *testing.T, req *http.Request) {
+				req.Header.Set("Date", time.Now().UTC().Format(http.TimeFormat))
+				req.Header.Set("Digest", "SHA-256="+sha256Base64(body))
+				signCavageHeaders(t, req, privateKey, []string{"(request-target)", "host", "date"})
+			},
+			wantErr: true,
+		},
+		{
+			name: "draft-cavage date not signed",
+			sign: func(t *testing.T, req *http.Request) {
+				req.Header.Set("Digest", "SHA-256="+sha256Base64(body))
+				signCavageHeaders(t, req, privateKey, []string{"(request-target)", "host", "digest"})
+			},
+			wantErr: true,
+		},
+		{
+			name: "draft-cavage signed date header missing",
+			sign: func(t *testing.T, req *http.Request) {
+				signWith(SignatureSchemeCavage, body)(t, req)
+				req.Header.Del("Date")
+			},
+			wantErr: true,
+		},
+		{
+			name: "RFC 9421 content-digest not signed",
+			sign: func(t *testing.T, req *http.Request) {
+				signWith(SignatureSchemeRFC9421, nil)(t, req)
+			},
+			wantErr: true,
+		},
+		{
+			name: "missing signature",
+			sign: func(t *testing.T, req *http.Request) {
+				req.Header.Set("Date", time.Now().UTC().Format(http.TimeFormat))
+				req.Header.Set("Digest", "SHA-256="+sha256Base64(body))
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verifier, _ := newTestVerifier(map[string]interface{}{
+				testActorID: testPerson(publicKeyPem),
+			})
+			if tt.offset != 0 {
+				verifier.now = func() time.Time { return time.Now().Add(tt.offset) }
+			}
+
+			req := newInboxRequest(t, body)
+			tt.sign(t, req)
+
+			received := tt.received
+			if received == nil {
+				received = body
+			}
+
+			actorID, err := verifier.VerifyRequest(context.Background(), req, received)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidSignature) {
+					t.Fatalf("got actor %q and error %v, want %v", actorID, err, ErrInvalidSignature)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if actorID != testActorID {
+				t.Errorf("got actor %q, want %q", actorID, testActorID)
+			}
+		})
+	}
+}
+
+func TestVerifyRequestKeyOwner(t *testing.T) {
+	privateKey, publicKeyPem := newTestKey(t)
+	otherKey, otherPublicKeyPem := newTestKey(t)
+	body := []byte(`{"type":"Create","actor":"https://remote.example/users/alice"}`)
+
+	// document of an attacker's actor claiming its key is owned by victim
+	forged := testPerson(publicKeyPem)
+	forged.ID = "https://remote.example/users/mallory"
+	forged.PublicKey.ID = forged.ID + "#main-key"
+
+	victim := testPerson(otherPublicKeyPem)
+	victim.ID = "https://other.example/users/victim"
+	victim.PublicKey.ID = victim.ID + "#main-key"
+	victim.PublicKey.Owner = victim.ID
+	forged.PublicKey.Owner = victim.ID
+
+	// document served for alice which doesn't list the signing key
+	withoutKey := testPerson(publicKeyPem)
+	withoutKey.PublicKey.ID = testActorID + "#other-key"
+
+	tests := []struct {
+		name       string
+		documents  map[string]interface{}
+		cached     *Person
+		keyID      string
+		privateKey *rsa.PrivateKey
+		wantActor  string
+		wantErr    bool
+	}{
+		{
+			name:       "owner isn't actor of document",
+			documents:  map[string]interface{}{forged.ID: forged, victim.ID: victim},
+			keyID:      forged.PublicKey.ID,
+			privateKey: privateKey,
+			wantErr:    true,
+		},
+		{
+			name:       "key isn't in actor document",
+			documents:  map[string]interface{}{testActorID: withoutKey},
+			keyID:      testKeyID,
+			privateKey: privateKey,
+			wantErr:    true,
+		},
+		{
+			name:       "document served for another host",
+			documents:  map[string]interface{}{testActorID: victim},
+			keyID:      testKeyID,
+			privateKey: otherKey,
+			wantErr:    true,
+		},
+		{
+			name:       "signed with another key than the one of document",
+			documents:  map[string]interface{}{testActorID: testPerson(publicKeyPem)},
+			keyID:      testKeyID,
+			privateKey: otherKey,
+			wantErr:    true,
+		},
+		{
+			name: "owner is omitted",
+			documents: map[string]interface{}{testActorID: func() *Person {
+				person := testPerson(publicKeyPem)
+				person.PublicKey.Owner = ""
+				return person
+			}()},
+			keyID:      testKeyID,
+			privateKey: privateKey,
+			wantActor:  testActorID,
+		},
+		{
+			name:       "cached key was rotated",
+			documents:  map[string]interface{}{testActorID: testPerson(publicKeyPem)},
+			cached:     testPerson(otherPublicKeyPem),
+			keyID:      testKeyID,
+			privateKey: privateKey,
+			wantActor:  testActorID,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verifier, actorCache := newTestVerifier(tt.documents)
+			if tt.cached != nil {
+				actorCache.cached[tt.cached.ID] = tt.cached
+			}
+
+			req := newInboxRequest(t, body)
+			err := NewRequestSigner(SignatureSchemeCavage).SignRequest(req, body, tt.keyID, tt.privateKey)
+			if err != nil {
+				t.Fatalf("fail to sign request: %v", err)
+			}
+
+			actorID, err := verifier.VerifyRequest(context.Background(), req, body)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("got actor %q, want error", actorID)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if actorID != tt.wantActor {
+				t.Errorf("got actor %q, want %q", actorID, tt.wantActor)
+			}
+		})
+	}
+}
@@ -0,0 +1,367 @@
+package activitypub
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"hash"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SignatureScheme HTTP signature format sent to a remote server
+type SignatureScheme string
+
+const (
+	// SignatureSchemeCavage draft-cavage-http-signatures-12 with "rsa-sha256" algorithm, understood by most servers
+	SignatureSchemeCavage SignatureScheme = "draft-cavage"
+	// SignatureSchemeCavageHS2019 draft-cavage-http-signatures-12 with "hs2019" algorithm and (created) parameter
+	SignatureSchemeCavageHS2019 SignatureScheme = "draft-cavage-hs2019"
+	// SignatureSchemeRFC9421 HTTP Message Signatures: https://www.rfc-editor.org/rfc/rfc9421
+	SignatureSchemeRFC9421 SignatureScheme = "rfc9421"
+
+	// label of the signature we add in Signature-Input and Signature headers
+	rfc9421SignatureLabel = "sig1"
+)
+
+// RequestSigner sign an outgoing HTTP request with a given scheme
+type RequestSigner interface {
+	Scheme() SignatureScheme
+	SignRequest(req *http.Request, body []byte, keyID string, privateKey *rsa.PrivateKey) error
+}
+
+// NewRequestSigner get signer of the scheme, unknown scheme falls back to draft-cavage
+func NewRequestSigner(scheme SignatureScheme) RequestSigner {
+	switch scheme {
+	case SignatureSchemeCavageHS2019:
+		return &CavageSigner{hs2019: true}
+	case SignatureSchemeRFC9421:
+		return &RFC9421Signer{}
+	default:
+		return &CavageSigner{}
+	}
+}
+
+// CavageSigner sign request with Signature header
+// https://datatracker.ietf.org/doc/html/draft-cavage-http-signatures-12
+type CavageSigner struct {
+	hs2019 bool
+}
+
+// Scheme
+func (cs *CavageSigner) Scheme() SignatureScheme {
+	if cs.hs2019 {
+		return SignatureSchemeCavageHS2019
+	}
+	return SignatureSchemeCavage
+}
+
+// SignRequest sign (request-target), host, date and, when there is a body, digest
+func (cs *CavageSigner) SignRequest(req *http.Request, body []byte, keyID string, privateKey *rsa.PrivateKey) error {
+	now := time.Now().UTC()
+
+	// create a formatted UTC timestamp and set it as the Date to request header
+	req.Header.Set("Date", now.Format(http.TimeFormat))
+
+	headers := []string{"(request-target)", "host", "date"}
+	params := &signatureParams{}
+
+	if cs.hs2019 {
+		params.created = strconv.FormatInt(now.Unix(), 10)
+		headers = []string{"(request-target)", "(created)", "host", "date"}
+	}
+
+	// sign body digest so the body can't be changed
+	if body != nil {
+		req.Header.Set("Digest", fmt.Sprintf("SHA-256=%s", sha256Base64(body)))
+		headers = append(headers, "digest")
+	}
+	params.headers = headers
+
+	// create the string to be signed
+	signingString, err := buildSigningString(req, params)
+	if err != nil {
+		return err
+	}
+
+	signature, err := signPKCS1v15(privateKey, []byte(signingString))
+	if err != nil {
+		return err
+	}
+
+	algorithm := "rsa-sha256"
+	if cs.hs2019 {
+		algorithm = "hs2019"
+	}
+
+	// format the HTTP Signature header with key ID, algorithm, signed headers, and the signature
+	signatureHeader := fmt.Sprintf(`keyId="%s",algorithm="%s",headers="%s",signature="%s"`,
+		keyID, algorithm, strings.Join(headers, " "), base64.StdEncoding.EncodeToString(signature))
+	if cs.hs2019 {
+		signatureHeader = fmt.Sprintf(`keyId="%s",algorithm="%s",created=%s,headers="%s",signature="%s"`,
+			keyID, algorithm, params.created, strings.Join(headers, " "), base64.StdEncoding.EncodeToString(signature))
+	}
+
+	req.Header.Set("Signature", signatureHeader)
+
+	return nil
+}
+
+// RFC9421Signer sign request with Signature-Input and Signature headers
+// https://www.rfc-editor.org/rfc/rfc9421
+type RFC9421Signer struct{}
+
+// Scheme
+func (rs *RFC9421Signer) Scheme() SignatureScheme {
+	return SignatureSchemeRFC9421
+}
+
+// SignRequest sign @method, @target-uri and, when there is a body, content-digest
+func (rs *RFC9421Signer) SignRequest(req *http.Request, body []byte, keyID string, privateKey *rsa.PrivateKey) error {
+	components := []string{"@method", "@target-uri"}
+
+	// Content-Digest: https://www.rfc-editor.org/rfc/rfc9530
+	// Digest is also sent for servers which only read the legacy header
+	if body != nil {
+		req.Header.Set("Content-Digest", fmt.Sprintf("sha-256=:%s:", sha256Base64(body)))
+		req.Header.Set("Digest", fmt.Sprintf("SHA-256=%s", sha256Base64(body)))
+		components = append(components, "content-digest")
+	}
+
+	input := &rfc9421SignatureInput{
+		label:      rfc9421SignatureLabel,
+		components: components,
+		created:    strconv.FormatInt(time.Now().Unix(), 10),
+		keyID:      keyID,
+		algorithm:  "rsa-v1_5-sha256",
+	}
+
+	signatureBase, err := buildRFC9421SignatureBase(req, req.URL.String(), input)
+	if err != nil {
+		return err
+	}
+
+	signature, err := signPKCS1v15(privateKey, []byte(signatureBase))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Signature-Input", fmt.Sprintf("%s=%s", input.label, input.serializeParams()))
+	req.Header.Set("Signature", fmt.Sprintf("%s=:%s:", input.label, base64.StdEncoding.EncodeToString(signature)))
+
+	return nil
+}
+
+// rfc9421SignatureInput parsed or generated Signature-Input member
+type rfc9421SignatureInput struct {
+	label      string
+	components []string
+	created    string
+	expires    string
+	keyID      string
+	algorithm  string
+	// raw serialized params when parsed from request, signature base must use them as received
+	raw string
+}
+
+// serializeParams serialize inner list of covered components and signature parameters
+// https://www.rfc-editor.org/rfc/rfc9421#section-2.3
+func (si *rfc9421SignatureInput) serializeParams() string {
+	if si.raw != "" {
+		return si.raw
+	}
+
+	quoted := make([]string, 0, len(si.components))
+	for _, component := range si.components {
+		quoted = append(quoted, strconv.Quote(component))
+	}
+
+	params := fmt.Sprintf("(%s)", strings.Join(quoted, " "))
+	if si.created != "" {
+		params += fmt.Sprintf(";created=%s", si.created)
+	}
+	if si.expires != "" {
+		params += fmt.Sprintf(";expires=%s", si.expires)
+	}
+	if si.keyID != "" {
+		params += fmt.Sprintf(";keyid=%s", strconv.Quote(si.keyID))
+	}
+	if si.algorithm != "" {
+		params += fmt.Sprintf(";alg=%s", strconv.Quote(si.algorithm))
+	}
+
+	return params
+}
+
+// buildRFC9421SignatureBase build signature base from covered components
+// https://www.rfc-editor.org/rfc/rfc9421#section-2.5
+func buildRFC9421SignatureBase(req *http.Request, targetURI string, input *rfc9421SignatureInput) (string, error) {
+	lines := make([]string, 0, len(input.components)+1)
+
+	for _, component := range input.components {
+		var value string
+
+		switch component {
+		case "@method":
+			value = req.Method
+		case "@target-uri":
+			value = targetURI
+		case "@authority":
+			value = strings.ToLower(req.Host)
+			if value == "" {
+				value = strings.ToLower(req.URL.Host)
+			}
+		case "@path":
+			value = req.URL.EscapedPath()
+		case "@query":
+			value = "?" + req.URL.RawQuery
+		default:
+			if strings.HasPrefix(component, "@") {
+				return "", fmt.Errorf("%w: unsupported component %s", ErrInvalidSignature, component)
+			}
+
+			headerValues := req.Header.Values(component)
+			if len(headerValues) == 0 {
+				return "", fmt.Errorf("%w: signed header %s is missing", ErrInvalidSignature, component)
+			}
+			value = strings.Join(headerValues, ", ")
+		}
+
+		lines = append(lines, fmt.Sprintf("%q: %s", component, value))
+	}
+
+	lines = append(lines, fmt.Sprintf("%q: %s", "@signature-params", input.serializeParams()))
+
+	return strings.Join(lines, "\n"), nil
+}
+
+// verifyContentDigest check Content-Digest header matches request body
+// https://www.rfc-editor.org/rfc/rfc9530
+func verifyContentDigest(contentDigestHeader string, body []byte) error {
+	if contentDigestHeader == "" {
+		return fmt.Errorf("%w: missing content-digest header", ErrInvalidSignature)
+	}
+
+	for _, digest := range strings.Split(contentDigestHeader, ",") {
+		algorithm, value, found := strings.Cut(strings.TrimSpace(digest), "=")
+		if !found {
+			continue
+		}
+		value = strings.Trim(value, ":")
+
+		var h hash.Hash
+		switch strings.ToLower(algorithm) {
+		case "sha-256":
+			h = sha256.New()
+		case "sha-512":
+			h = sha512.New()
+		default:
+			continue
+		}
+
+		h.Write(body)
+		if base64.StdEncoding.EncodeToString(h.Sum(nil)) != value {
+			return fmt.Errorf("%w: content-digest doesn't match body", ErrInvalidSignature)
+		}
+
+		return nil
+	}
+
+	return fmt.Errorf("%w: no supported content-digest algorithm", ErrInvalidSignature)
+}
+
+// signPKCS1v15 sign SHA-256 hash of data using the RSA private key with PKCS#1 v1.5 padding
+func signPKCS1v15(privateKey *rsa.PrivateKey, data []byte) ([]byte, error) {
+	digest := sha256.Sum256(data)
+
+	signature, err := rsa.SignPKCS1v15(rand.Reader, privateKey, crypto.SHA256, digest[:])
+	if err != nil {
+		return nil, fmt.Errorf("fail to sign: %w", err)
+	}
+
+	return signature, nil
+}
+
+// sha256Base64 base64 encoded SHA-256 hash of data
+func sha256Base64(data []byte) string {
+	digest := sha256.Sum256(data)
+	return base64.StdEncoding.EncodeToString(digest[:])
+}
+
+// ParsePrivateKeyPEM parse PEM encoded PKCS#1 or PKCS#8 RSA private key
+func ParsePrivateKeyPEM(privateKeyPEM string) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(privateKeyPEM))
+	if block == nil {
+		return nil, fmt.Errorf("fail to decode private key")
+	}
+
+	privateKey, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	if err == nil {
+		return privateKey, nil
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("fail to parse private key: %w", err)
+	}
+
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("private key isn't an RSA key")
+	}
+
+	return rsaKey, nil
+}
+
+// HostSignatureSchemes remember which signature scheme each remote host accepts
+type HostSignatureSchemes struct {
+	defaultScheme SignatureScheme
+
+	mu      sync.RWMutex
+	schemes map[string]SignatureScheme
+}
+
+// NewHostSignatureSchemes
+func NewHostSignatureSchemes(defaultScheme SignatureScheme) *HostSignatureSchemes {
+	return &HostSignatureSchemes{
+		defaultScheme: defaultScheme,
+		schemes:       make(map[string]SignatureScheme),
+	}
+}
+
+// Get get scheme accepted by host, or default scheme when it's unknown
+func (hs *HostSignatureSchemes) Get(host string) SignatureScheme {
+	hs.mu.RLock()
+	defer hs.mu.RUnlock()
+
+	scheme, ok := hs.schemes[strings.ToLower(host)]
+	if !ok {
+		return hs.defaultScheme
+	}
+	return scheme
+}
+
+// Set remember scheme accepted by host
+func (hs *HostSignatureSchemes) Set(host string, scheme SignatureScheme) {
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+
+	hs.schemes[strings.ToLower(host)] = scheme
+}
+
+// alternativeSignatureScheme scheme to retry with when host rejects a signature
+func alternativeSignatureScheme(rejected SignatureScheme) SignatureScheme {
+	if rejected == SignatureSchemeRFC9421 {
+		return SignatureSchemeCavage
+	}
+	return SignatureSchemeRFC9421
+}
@@ -0,0 +1,230 @@
+package activitypub
+
+import (
+	"fmt"
+	stdhtml "html"
+	"je-suis-ici-activitypub/internal/db/models"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// mentionPattern "@user" or "@user@host" which isn't part of a word, an email address or a URL
+var mentionPattern = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_@/.])@([A-Za-z0-9_]+(?:[.-][A-Za-z0-9_]+)*)(?:@([A-Za-z0-9-]+(?:\.[A-Za-z0-9-]+)+(?::[0-9]+)?))?`)
+
+// hashtagPattern "#tag" which isn't part of a word, an HTML entity or a URL fragment, a tag can't be only digits
+var hashtagPattern = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_&#/])#([\p{L}\p{N}_]*[\p{L}_][\p{L}\p{N}_]*)`)
+
+// maxTagLength longer hashtags are not stored
+const maxTagLength = 100
+
+// TagURL URL of hashtag timeline API
+func TagURL(baseURL, tag string) string {
+	return fmt.Sprintf("%s/api/tags/%s", baseURL, url.PathEscape(tag))
+}
+
+// ParseMentions get deduplicated accounts mentioned in plain text content
+// "@user" is returned as "user", "@user@host" as "user@host"
+func ParseMentions(content string) []string {
+	seen := make(map[string]bool)
+	var accounts []string
+
+	for _, match := range mentionPattern.FindAllStringSubmatch(content, -1) {
+		account := match[1]
+		if match[2] != "" {
+			account += "@" + match[2]
+		}
+
+		key := strings.ToLower(account)
+		if !seen[key] {
+			seen[key] = true
+			accounts = append(accounts, account)
+		}
+	}
+
+	return accounts
+}
+
+// ParseHashtags get deduplicated hashtags of plain text content, lowercased without "#"
+func ParseHashtags(content string) []string {
+	seen := make(map[string]bool)
+	var tags []string
+
+	for _, match := range hashtagPattern.FindAllStringSubmatch(content, -1) {
+		tag := NormalizeTag(match[1])
+		if tag != "" && !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+
+	return tags
+}
+
+// NormalizeTag lowercase hashtag and drop "#", empty when it's too long
+func NormalizeTag(tag string) string {
+	tag = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(tag), "#"))
+	if len(tag) > maxTagLength {
+		return ""
+	}
+
+	return tag
+}
+
+// noteTags Mention and Hashtag entries of Note tag
+func noteTags(mentions []models.Mention, tags []string, baseURL string) Property {
+	var property Property
+
+	for _, mention := range mentions {
+		property = append(property, ObjectProperty(&Link{
+			Type: LinkTypeMention,
+			Href: mention.ActorID,
+			Name: "@" + mention.Acct,
+		})...)
+	}
+
+	for _, tag := range tags {
+		property = append(property, ObjectProperty(&Link{
+			Type: LinkTypeHashtag,
+			Href: TagURL(baseURL, tag),
+			Name: "#" + tag,
+		})...)
+	}
+
+	return property
+}
+
+// NoteEntities get hashtags and mentions from Mention and Hashtag entries of remote Note tag
+func NoteEntities(note *Object) ([]string, []models.Mention) {
+	var tags []string
+	var mentions []models.Mention
+	seen := make(map[string]bool)
+
+	for _, value := range note.Tag {
+		var link Link
+		if value.Decode(&link) != nil {
+			continue
+		}
+
+		switch value.Type {
+		case LinkTypeHashtag:
+			tag := NormalizeTag(link.Name)
+			if tag == "" || seen["#"+tag] {
+				continue
+			}
+			seen["#"+tag] = true
+			tags = append(tags, tag)
+
+		case LinkTypeMention:
+			if link.Href == "" || seen[link.Href] {
+				continue
+			}
+			seen[link.Href] = true
+
+			// name is "@user@host", some servers only send "@user"
+			acct := strings.TrimPrefix(link.Name, "@")
+			if !strings.Contains(acct, "@") {
+				if hrefURL, err := url.Parse(link.Href); err == nil && hrefURL.Host != "" {
+					acct += "@" + hrefURL.Host
+				}
+			}
+
+			mentions = append(mentions, models.Mention{
+				ActorID: link.Href,
+				Acct:    acct,
+			})
+		}
+	}
+
+	return tags, mentions
+}
+
+// linkEntities escape plain text content and link its resolved mentions and its hashtags
+// mentions which weren't resolved stay plain text
+func linkEntities(content string, mentions []models.Mention, baseURL string) string {
+	type entity struct {
+		start, end int
+		html       string
+	}
+
+	// "@user" mentions a local user
+	var serverHost string
+	if serverURL, err := url.Parse(baseURL); err == nil {
+		serverHost = serverURL.Host
+	}
+
+	var entities []entity
+
+	for _, match := range mentionPattern.FindAllStringSubmatchIndex(content, -1) {
+		username := content[match[2]:match[3]]
+		host := serverHost
+		if match[4] >= 0 {
+			host = content[match[4]:match[5]]
+		}
+
+		mention, ok := findMention(mentions, username+"@"+host)
+		if !ok {
+			continue
+		}
+
+		href := mention.URL
+		if href == "" {
+			href = mention.ActorID
+		}
+
+		// match may start with the character before "@"
+		entities = append(entities, entity{
+			start: match[2] - 1,
+			end:   match[1],
+			html: fmt.Sprintf(`<span class="h-card"><a href="%s" class="u-url mention">@<span>%s</span></a></span>`,
+				stdhtml.EscapeString(href), stdhtml.EscapeString(username)),
+		})
+	}
+
+	for _, match := range hashtagPattern.FindAllStringSubmatchIndex(content, -1) {
+		name := content[match[2]:match[3]]
+		tag := NormalizeTag(name)
+		if tag == "" {
+			continue
+		}
+
+		entities = append(entities, entity{
+			start: match[2] - 1,
+			end:   match[1],
+			html: fmt.Sprintf(`<a href="%s" class="mention hashtag" rel="tag">#<span>%s</span></a>`,
+				stdhtml.EscapeString(TagURL(baseURL, tag)), stdhtml.EscapeString(name)),
+		})
+	}
+
+	sort.Slice(entities, func(i, j int) bool {
+		return entities[i].start < entities[j].start
+	})
+
+	var builder strings.Builder
+	offset := 0
+	for _, e := range entities {
+		// patterns exclude each other, keep the first entity if they ever overlap
+		if e.start < offset {
+			continue
+		}
+
+		builder.WriteString(stdhtml.EscapeString(content[offset:e.start]))
+		builder.WriteString(e.html)
+		offset = e.end
+	}
+	builder.WriteString(stdhtml.EscapeString(content[offset:]))
+
+	return builder.String()
+}
+
+// findMention find mention of "user@host" account, case insensitive
+func findMention(mentions []models.Mention, acct string) (models.Mention, bool) {
+	for _, mention := range mentions {
+		if strings.EqualFold(mention.Acct, acct) {
+			return mention, true
+		}
+	}
+
+	return models.Mention{}, false
+}
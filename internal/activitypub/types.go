@@ -17,6 +17,8 @@ const (
 	ActivityTypeLike     = "Like"
 	ActivityTypeUpdate   = "Update"
 	ActivityTypeUndo     = "Undo"
+	ActivityTypeMove     = "Move"
+	ActivityTypeBlock    = "Block"
 
 	// Object Types: https://www.w3.org/TR/activitystreams-vocabulary/#object-types
 	ObjectTypeNote         = "Note"
@@ -28,6 +30,18 @@ const (
 	ObjectTypeActivity     = "Activity"
 	ObjectTypeTombstone    = "Tombstone"
 
+	// Link Types: https://www.w3.org/TR/activitystreams-vocabulary/#dfn-mention
+	// Hashtag isn't in the vocabulary, it's defined as "as:Hashtag" like Mastodon does
+	LinkTypeMention = "Mention"
+	LinkTypeHashtag = "Hashtag"
+
+	// Collection Types: https://www.w3.org/TR/activitystreams-vocabulary/#dfn-orderedcollection
+	CollectionTypeOrderedCollection     = "OrderedCollection"
+	CollectionTypeOrderedCollectionPage = "OrderedCollectionPage"
+
+	// Public addressing: https://www.w3.org/TR/activitypub/#public-addressing
+	PublicAddress = "https://www.w3.org/ns/activitystreams#Public"
+
 	// Actor Types: https://www.w3.org/TR/activitystreams-vocabulary/#actor-types
 	ActorTypeApplication  = "Application"
 	ActorTypeGroup        = "Group"
@@ -41,32 +55,57 @@ type Context []interface{}
 
 // Object: Core Types, https://www.w3.org/TR/activitystreams-vocabulary/#dfn-object
 type Object struct {
-	Context      Context   `json:"@context,omitempty"`
-	ID           string    `json:"id,omitempty"`
-	Type         string    `json:"type"`
-	AttributedTo string    `json:"attributedTo,omitempty"`
-	Name         string    `json:"name,omitempty"`
-	Summary      string    `json:"summary,omitempty"`
-	Content      string    `json:"content,omitempty"`
-	URL          string    `json:"url,omitempty"`
-	MediaType    string    `json:"mediaType,omitempty"`
-	Published    time.Time `json:"published,omitempty"`
-	Updated      time.Time `json:"updated,omitempty"`
-	Icon         *Image    `json:"icon,omitempty"`
-	Image        *Image    `json:"image,omitempty"`
-	Location     *Place    `json:"location,omitempty"`
-	Tag          []Object  `json:"tag,omitempty"`
-	Attachment   []Object  `json:"attachment,omitempty"`
-	InReplyTo    string    `json:"inReplyTo,omitempty"`
-	To           []string  `json:"to,omitempty"`
-	Cc           []string  `json:"cc,omitempty"`
-	Bto          []string  `json:"bto,omitempty"`
-	Bcc          []string  `json:"bcc,omitempty"`
-	Generator    *Object   `json:"generator,omitempty"`
+	Context      Context    `json:"@context,omitempty"`
+	ID           string     `json:"id,omitempty"`
+	Type         string     `json:"type"`
+	AttributedTo Property   `json:"attributedTo,omitempty"`
+	Name         string     `json:"name,omitempty"`
+	Summary      string     `json:"summary,omitempty"`
+	Content      string     `json:"content,omitempty"`
+	URL          Property   `json:"url,omitempty"`
+	MediaType    string     `json:"mediaType,omitempty"`
+	Published    time.Time  `json:"published,omitempty"`
+	Updated      *time.Time `json:"updated,omitempty"`
+	Icon         Property   `json:"icon,omitempty"`
+	Image        Property   `json:"image,omitempty"`
+	Location     Property   `json:"location,omitempty"`
+	Tag          Property   `json:"tag,omitempty"`
+	Attachment   Property   `json:"attachment,omitempty"`
+	InReplyTo    Property   `json:"inReplyTo,omitempty"`
+	To           IRIs       `json:"to,omitempty"`
+	Cc           IRIs       `json:"cc,omitempty"`
+	Bto          IRIs       `json:"bto,omitempty"`
+	Bcc          IRIs       `json:"bcc,omitempty"`
+	Generator    Property   `json:"generator,omitempty"`
+	Shares       string     `json:"shares,omitempty"`
+	// FormerType and Deleted describe a Tombstone
+	FormerType string     `json:"formerType,omitempty"`
+	Deleted    *time.Time `json:"deleted,omitempty"`
+	// Extra properties Object doesn't have a field for, they are encoded again as received
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// UnmarshalJSON
+func (o *Object) UnmarshalJSON(data []byte) error {
+	type object Object
+	extra, err := decodeWithExtra(data, (*object)(o))
+	if err != nil {
+		return err
+	}
+
+	o.Extra = extra
+	return nil
+}
+
+// MarshalJSON
+func (o Object) MarshalJSON() ([]byte, error) {
+	type object Object
+	return encodeWithExtra(object(o), o.Extra)
 }
 
 // Link: Core Types, https://www.w3.org/TR/activitystreams-vocabulary/#dfn-link
 type Link struct {
+	Type      string `json:"type,omitempty"`
 	Href      string `json:"href,omitempty"`
 	Rel       string `json:"rel,omitempty"` // relation
 	MediaType string `json:"mediaType,omitempty"`
@@ -78,20 +117,41 @@ type Link struct {
 
 // Activity: Core Types, https://www.w3.org/TR/activitystreams-vocabulary/#dfn-activity
 type Activity struct {
-	Context   Context     `json:"@context,omitempty"`
-	ID        string      `json:"id"`
-	Type      string      `json:"type"`
-	Actor     string      `json:"actor"`
-	Object    interface{} `json:"object"`
-	Target    string      `json:"target,omitempty"`
-	Result    interface{} `json:"result,omitempty"`
-	Origin    string      `json:"origin,omitempty"`
-	To        []string    `json:"to,omitempty"`
-	Cc        []string    `json:"cc,omitempty"`  // Carbon Copy
-	Bto       []string    `json:"bto,omitempty"` // Blind To
-	Bcc       []string    `json:"bcc,omitempty"` // Blind Carbon Copy
-	Published time.Time   `json:"published,omitempty"`
-	Updated   time.Time   `json:"updated,omitempty"`
+	Context   Context   `json:"@context,omitempty"`
+	ID        string    `json:"id"`
+	Type      string    `json:"type"`
+	Actor     Property  `json:"actor"`
+	Object    Property  `json:"object"`
+	Target    Property  `json:"target,omitempty"`
+	Result    Property  `json:"result,omitempty"`
+	Origin    Property  `json:"origin,omitempty"`
+	To        IRIs      `json:"to,omitempty"`
+	Cc        IRIs      `json:"cc,omitempty"`  // Carbon Copy
+	Bto       IRIs      `json:"bto,omitempty"` // Blind To
+	Bcc       IRIs      `json:"bcc,omitempty"` // Blind Carbon Copy
+	Audience  IRIs      `json:"audience,omitempty"`
+	Published time.Time `json:"published,omitempty"`
+	Updated   time.Time `json:"updated,omitempty"`
+	// Extra properties Activity doesn't have a field for, they are encoded again as received
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// UnmarshalJSON
+func (a *Activity) UnmarshalJSON(data []byte) error {
+	type activity Activity
+	extra, err := decodeWithExtra(data, (*activity)(a))
+	if err != nil {
+		return err
+	}
+
+	a.Extra = extra
+	return nil
+}
+
+// MarshalJSON
+func (a Activity) MarshalJSON() ([]byte, error) {
+	type activity Activity
+	return encodeWithExtra(activity(a), a.Extra)
 }
 
 // Collection: Core Types, https://www.w3.org/TR/activitystreams-vocabulary/#dfn-collection
@@ -143,49 +203,139 @@ type OrderedCollectionPage struct {
 
 // Image: https://www.w3.org/TR/activitystreams-vocabulary/#dfn-image
 type Image struct {
-	Type      string `json:"type"`
-	URL       string `json:"url"`
-	Name      string `json:"name,omitempty"`
-	MediaType string `json:"mediaType,omitempty"`
-	Width     int    `json:"width,omitempty"`
-	Height    int    `json:"height,omitempty"`
+	Type      string   `json:"type"`
+	URL       Property `json:"url"`
+	Name      string   `json:"name,omitempty"`
+	MediaType string   `json:"mediaType,omitempty"`
+	Width     int      `json:"width,omitempty"`
+	Height    int      `json:"height,omitempty"`
+}
+
+// ImageURL get URL of the first image of property
+// image can be its URL, a Link, or an Image whose url is a URL or a Link
+func ImageURL(property Property) string {
+	if len(property) == 0 || property.Type() == "Link" || property[0].Raw == nil {
+		return property.IRI()
+	}
+
+	var image Image
+	err := property.Decode(&image)
+	if err != nil {
+		return ""
+	}
+
+	return image.URL.IRI()
 }
 
 // Place: https://www.w3.org/TR/activitystreams-vocabulary/#dfn-place
 type Place struct {
-	Type      string    `json:"type"`
-	ID        string    `json:"id,omitempty"`
-	Name      string    `json:"name"`
-	Latitude  float64   `json:"latitude,omitempty"`
-	Longitude float64   `json:"longitude,omitempty"`
-	Accuracy  float64   `json:"accuracy,omitempty"`
-	Altitude  float64   `json:"altitude,omitempty"`
-	Radius    float64   `json:"radius,omitempty"`
-	Units     string    `json:"units,omitempty"`
-	Published time.Time `json:"published,omitempty"`
-	Updated   time.Time `json:"updated,omitempty"`
+	Type      string     `json:"type"`
+	ID        string     `json:"id,omitempty"`
+	Name      string     `json:"name"`
+	Latitude  float64    `json:"latitude,omitempty"`
+	Longitude float64    `json:"longitude,omitempty"`
+	Accuracy  float64    `json:"accuracy,omitempty"`
+	Altitude  float64    `json:"altitude,omitempty"`
+	Radius    float64    `json:"radius,omitempty"`
+	Units     string     `json:"units,omitempty"`
+	Published *time.Time `json:"published,omitempty"`
+	Updated   *time.Time `json:"updated,omitempty"`
+}
+
+// UnmarshalJSON accept coordinates encoded as strings, some servers send them so
+func (p *Place) UnmarshalJSON(data []byte) error {
+	type place Place
+	decoded := struct {
+		*place
+		Latitude  json.Number `json:"latitude,omitempty"`
+		Longitude json.Number `json:"longitude,omitempty"`
+		Accuracy  json.Number `json:"accuracy,omitempty"`
+		Altitude  json.Number `json:"altitude,omitempty"`
+		Radius    json.Number `json:"radius,omitempty"`
+	}{place: (*place)(p)}
+
+	err := json.Unmarshal(data, &decoded)
+	if err != nil {
+		return err
+	}
+
+	coordinates := map[*float64]json.Number{
+		&p.Latitude:  decoded.Latitude,
+		&p.Longitude: decoded.Longitude,
+		&p.Accuracy:  decoded.Accuracy,
+		&p.Altitude:  decoded.Altitude,
+		&p.Radius:    decoded.Radius,
+	}
+	for field, number := range coordinates {
+		if number == "" {
+			continue
+		}
+
+		*field, err = number.Float64()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
 // Person: https://www.w3.org/TR/activitystreams-vocabulary/#dfn-person
 type Person struct {
-	Context           Context   `json:"@context,omitempty"`
-	ID                string    `json:"id"`
-	Type              string    `json:"type"`
-	Name              string    `json:"name,omitempty"`
-	PreferredUsername string    `json:"preferredUsername"`
-	Inbox             string    `json:"inbox"`
-	Outbox            string    `json:"outbox"`
-	Following         string    `json:"following,omitempty"`
-	Followers         string    `json:"followers,omitempty"`
-	Liked             string    `json:"liked,omitempty"`
-	URL               string    `json:"url,omitempty"`
-	PublicKey         PublicKey `json:"publicKey,omitempty"`
-	Icon              *Image    `json:"icon,omitempty"`
-	Image             *Image    `json:"image,omitempty"`
-	Tag               []Object  `json:"tag,omitempty"`
-	Attachment        []Object  `json:"attachment,omitempty"`
-	Published         time.Time `json:"published,omitempty"`
-	Updated           time.Time `json:"updated,omitempty"`
+	Context           Context    `json:"@context,omitempty"`
+	ID                string     `json:"id"`
+	Type              string     `json:"type"`
+	Name              string     `json:"name,omitempty"`
+	PreferredUsername string     `json:"preferredUsername"`
+	Inbox             string     `json:"inbox"`
+	Outbox            string     `json:"outbox"`
+	Following         string     `json:"following,omitempty"`
+	Followers         string     `json:"followers,omitempty"`
+	Liked             string     `json:"liked,omitempty"`
+	URL               Property   `json:"url,omitempty"`
+	PublicKey         PublicKey  `json:"publicKey,omitempty"`
+	Endpoints         *Endpoints `json:"endpoints,omitempty"`
+	Icon              Property   `json:"icon,omitempty"`
+	Image             Property   `json:"image,omitempty"`
+	Tag               Property   `json:"tag,omitempty"`
+	Attachment        Property   `json:"attachment,omitempty"`
+	Published         time.Time  `json:"published,omitempty"`
+	Updated           time.Time  `json:"updated,omitempty"`
+	// ManuallyApprovesFollowers: https://docs.joinmastodon.org/spec/activitypub/#as
+	ManuallyApprovesFollowers bool `json:"manuallyApprovesFollowers"`
+	// AlsoKnownAs and MovedTo: https://docs.joinmastodon.org/spec/activitypub/#as
+	AlsoKnownAs IRIs   `json:"alsoKnownAs,omitempty"`
+	MovedTo     string `json:"movedTo,omitempty"`
+	// Extra properties Person doesn't have a field for, they are encoded again as received
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// UnmarshalJSON
+func (p *Person) UnmarshalJSON(data []byte) error {
+	type person Person
+	extra, err := decodeWithExtra(data, (*person)(p))
+	if err != nil {
+		return err
+	}
+
+	p.Extra = extra
+	return nil
+}
+
+// MarshalJSON
+func (p Person) MarshalJSON() ([]byte, error) {
+	type person Person
+	return encodeWithExtra(person(p), p.Extra)
+}
+
+// IconURL URL of actor's avatar
+func (p *Person) IconURL() string {
+	return ImageURL(p.Icon)
+}
+
+// Endpoints: https://www.w3.org/TR/activitypub/#endpoints
+type Endpoints struct {
+	SharedInbox string `json:"sharedInbox,omitempty"`
 }
 
 // PublicKey:
@@ -201,6 +351,15 @@ func DefaultContext() Context {
 		"https://w3id.org/security/v1",
 		map[string]interface{}{
 			"manuallyApprovesFollowers": "as:manuallyApprovesFollowers",
+			"Hashtag":                   "as:Hashtag",
+			"alsoKnownAs": map[string]interface{}{
+				"@id":   "as:alsoKnownAs",
+				"@type": "@id",
+			},
+			"movedTo": map[string]interface{}{
+				"@id":   "as:movedTo",
+				"@type": "@id",
+			},
 		},
 	}
 }
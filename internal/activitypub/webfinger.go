@@ -0,0 +1,47 @@
+package activitypub
+
+import (
+	"fmt"
+	"strings"
+)
+
+// WebFinger const
+const (
+	// JRD media type: https://www.rfc-editor.org/rfc/rfc7033#section-10.2
+	ContentTypeJRDJSON = "application/jrd+json"
+	// ActivityPub media type: https://www.w3.org/TR/activitypub/#retrieving-objects
+	ContentTypeActivityJSON = "application/activity+json"
+
+	// link relations
+	WebFingerRelSelf        = "self"
+	WebFingerRelProfilePage = "http://webfinger.net/rel/profile-page"
+)
+
+// WebFinger JSON Resource Descriptor (JRD): https://www.rfc-editor.org/rfc/rfc7033#section-4.4
+type WebFinger struct {
+	Subject string          `json:"subject"`
+	Aliases []string        `json:"aliases,omitempty"`
+	Links   []WebFingerLink `json:"links"`
+}
+
+// WebFingerLink: https://www.rfc-editor.org/rfc/rfc7033#section-4.4.4
+type WebFingerLink struct {
+	Rel      string `json:"rel"`
+	Type     string `json:"type,omitempty"`
+	Href     string `json:"href,omitempty"`
+	Template string `json:"template,omitempty"`
+}
+
+// ParseAcctResource split "acct:username@host" (the "acct:" scheme and a leading "@" are optional)
+// into username and host
+func ParseAcctResource(resource string) (string, string, error) {
+	account := strings.TrimPrefix(resource, "acct:")
+	account = strings.TrimPrefix(account, "@")
+
+	username, host, found := strings.Cut(account, "@")
+	if !found || username == "" || host == "" || strings.Contains(host, "@") {
+		return "", "", fmt.Errorf("invalid acct resource: %s", resource)
+	}
+
+	return username, host, nil
+}
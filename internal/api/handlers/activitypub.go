@@ -0,0 +1,637 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"je-suis-ici-activitypub/internal/activitypub"
+	"je-suis-ici-activitypub/internal/api/middlewares"
+	"je-suis-ici-activitypub/internal/db/models"
+	"je-suis-ici-activitypub/internal/services"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+)
+
+const (
+	// MaxInboxBodySize max size of activity posted to inbox, 1 MB
+	MaxInboxBodySize = 1 << 20
+	// OutboxPageSize number of activities in one outbox page
+	OutboxPageSize = 20
+	// FollowCollectionPageSize number of actors in one followers or following page
+	FollowCollectionPageSize = 40
+)
+
+// profileTemplate HTML profile page for browsers visiting an actor ID
+var profileTemplate = template.Must(template.New("profile").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Name}} (@{{.PreferredUsername}}@{{.Host}})</title>
+<link rel="alternate" type="application/activity+json" href="{{.ID}}">
+</head>
+<body>
+{{with .IconURL}}<img src="{{.}}" alt="{{$.Name}}" width="96" height="96">{{end}}
+<h1>{{.Name}}</h1>
+<p>@{{.PreferredUsername}}@{{.Host}}</p>
+</body>
+</html>
+`))
+
+// ActivityPubHandler handle ActivityPub federation requests from other servers
+type ActivityPubHandler struct {
+	userService       services.UserService
+	checkinService    services.CheckinService
+	actorService      activitypub.ActorService
+	apServerService   *activitypub.ActivityPubServerService
+	signatureVerifier *activitypub.SignatureVerifier
+	serverHost        string
+	baseURL           string
+	logger            *zap.Logger
+}
+
+// NewActivityPubHandler
+func NewActivityPubHandler(userService services.UserService, checkinService services.CheckinService, actorService activitypub.ActorService, apServerService *activitypub.ActivityPubServerService, signatureVerifier *activitypub.SignatureVerifier, serverHost, baseURL string, logger *zap.Logger) *ActivityPubHandler {
+	return &ActivityPubHandler{
+		userService:       userService,
+		checkinService:    checkinService,
+		actorService:      actorService,
+		apServerService:   apServerService,
+		signatureVerifier: signatureVerifier,
+		serverHost:        serverHost,
+		baseURL:           baseURL,
+		logger:            logger,
+	}
+}
+
+// RegisterActivityPubRoutes register public ActivityPub routes
+func (ah *ActivityPubHandler) RegisterActivityPubRoutes(r chi.Router) {
+	r.Get("/users/{username}", ah.GetActor)
+	r.Get("/users/{username}/outbox", ah.GetOutbox)
+	r.Get("/users/{username}/followers", ah.GetFollowers)
+	r.Get("/users/{username}/following", ah.GetFollowing)
+	r.Get("/users/{username}/liked", ah.GetLiked)
+	r.Get("/checkins/{id}", ah.GetCheckinNote)
+	r.Get("/checkins/{id}/shares", ah.GetCheckinShares)
+	r.Get("/activities/{id}", ah.GetActivity)
+
+	// inbox routes, only accept signed activities
+	r.Group(func(r chi.Router) {
+		r.Use(middleware.RequestSize(MaxInboxBodySize))
+		r.Use(middlewares.RequireActivityContentType)
+		r.Use(middlewares.VerifyHTTPSignature(ah.signatureVerifier))
+
+		r.Post("/users/{username}/inbox", ah.PostUserInbox)
+		r.Post("/inbox", ah.PostSharedInbox)
+	})
+}
+
+// GetActor return user's Person document for ActivityPub clients, or HTML profile for browsers
+func (ah *ActivityPubHandler) GetActor(w http.ResponseWriter, r *http.Request) {
+	username := chi.URLParam(r, "username")
+	if username == "" {
+		http.Error(w, "username is required", http.StatusBadRequest)
+		return
+	}
+
+	// get user by ID, username or previous username
+	user, ok := ah.getUserByHandle(w, r, username)
+	if !ok {
+		return
+	}
+
+	// previous username redirects to actor ID, or to profile page for browsers
+	// actor IDs of users registered before IDs were built from user ID still contain a username
+	if username != user.Username && !strings.HasSuffix(user.ActorID, "/users/"+url.PathEscape(username)) {
+		location := user.ActorID
+		if !acceptsActivityJSON(r) {
+			location = activitypub.ProfileURL(ah.baseURL, user.Username)
+		}
+
+		w.Header().Set("Vary", "Accept")
+		http.Redirect(w, r, location, http.StatusMovedPermanently)
+		return
+	}
+
+	// build actor document
+	actor, err := ah.actorService.GetActor(r.Context(), user, ah.baseURL)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// response depends on Accept header
+	w.Header().Set("Vary", "Accept")
+
+	if !acceptsActivityJSON(r) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		profileTemplate.Execute(w, struct {
+			*activitypub.Person
+			Host string
+		}{
+			Person: actor,
+			Host:   ah.serverHost,
+		})
+		return
+	}
+
+	writeActivityJSON(w, http.StatusOK, actor)
+}
+
+// GetOutbox return user's outbox, a paginated collection of checkin Create activities
+// https://www.w3.org/TR/activitypub/#outbox
+func (ah *ActivityPubHandler) GetOutbox(w http.ResponseWriter, r *http.Request) {
+	username := chi.URLParam(r, "username")
+	if username == "" {
+		http.Error(w, "username is required", http.StatusBadRequest)
+		return
+	}
+
+	// get user by ID, username or previous username
+	user, ok := ah.getUserByHandle(w, r, username)
+	if !ok {
+		return
+	}
+
+	outboxURL := fmt.Sprintf("%s/outbox", user.ActorID)
+
+	totalItems, err := ah.checkinService.CountCheckinsByUserID(r.Context(), user.ID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// without page parameter, return the collection which links to its first page
+	pageParam := r.URL.Query().Get("page")
+	if pageParam == "" {
+		lastPage := (totalItems + OutboxPageSize - 1) / OutboxPageSize
+		if lastPage < 1 {
+			lastPage = 1
+		}
+
+		writeActivityJSON(w, http.StatusOK, &activitypub.OrderedCollection{
+			Context:    activitypub.DefaultContext(),
+			ID:         outboxURL,
+			Type:       activitypub.CollectionTypeOrderedCollection,
+			TotalItems: totalItems,
+			First:      fmt.Sprintf("%s?page=1", outboxURL),
+			Last:       fmt.Sprintf("%s?page=%d", outboxURL, lastPage),
+		})
+		return
+	}
+
+	page, err := strconv.Atoi(pageParam)
+	if err != nil || page < 1 {
+		http.Error(w, "invalid page", http.StatusBadRequest)
+		return
+	}
+
+	// get checkins of the page, newest first
+	checkins, err := ah.checkinService.GetCheckinsByUserID(r.Context(), user.ID, page, OutboxPageSize)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	items := make([]*activitypub.Activity, 0, len(checkins))
+	for i := range checkins {
+		items = append(items, activitypub.CheckinToCreateActivity(&checkins[i], user.ActorID, ah.baseURL))
+	}
+
+	collectionPage := &activitypub.OrderedCollectionPage{
+		Context:      activitypub.DefaultContext(),
+		ID:           fmt.Sprintf("%s?page=%d", outboxURL, page),
+		Type:         activitypub.CollectionTypeOrderedCollectionPage,
+		PartOf:       outboxURL,
+		OrderedItems: items,
+		StartIndex:   (page - 1) * OutboxPageSize,
+	}
+	if page*OutboxPageSize < totalItems {
+		collectionPage.Next = fmt.Sprintf("%s?page=%d", outboxURL, page+1)
+	}
+	if page > 1 {
+		collectionPage.Prev = fmt.Sprintf("%s?page=%d", outboxURL, page-1)
+	}
+
+	writeActivityJSON(w, http.StatusOK, collectionPage)
+}
+
+// GetCheckinNote return Note object of a checkin at its object ID
+func (ah *ActivityPubHandler) GetCheckinNote(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "invalid checkin id", http.StatusBadRequest)
+		return
+	}
+
+	checkin, err := ah.checkinService.GetCheckinByID(r.Context(), id)
+	if err != nil {
+		ah.writeTombstone(w, r, activitypub.CheckinObjectID(ah.baseURL, id))
+		return
+	}
+
+	// remote checkins are served by their own servers
+	if checkin.IsRemote {
+		http.Error(w, "checkin not found", http.StatusNotFound)
+		return
+	}
+
+	note := activitypub.CheckinToNote(checkin, checkin.User.ActorID, ah.baseURL)
+	note.Context = activitypub.DefaultContext()
+
+	writeActivityJSON(w, http.StatusOK, note)
+}
+
+// writeTombstone answer 410 Gone with Tombstone of a deleted object, or 404 when object never existed
+func (ah *ActivityPubHandler) writeTombstone(w http.ResponseWriter, r *http.Request, objectID string) {
+	tombstone, err := ah.checkinService.GetTombstone(r.Context(), objectID)
+	if err != nil {
+		http.Error(w, "checkin not found", http.StatusNotFound)
+		return
+	}
+
+	writeActivityJSON(w, http.StatusGone, &activitypub.Object{
+		Context:    activitypub.DefaultContext(),
+		ID:         tombstone.ObjectID,
+		Type:       activitypub.ObjectTypeTombstone,
+		FormerType: tombstone.FormerType,
+		Deleted:    &tombstone.DeletedAt,
+	})
+}
+
+// GetCheckinShares return collection of Announce activities of a checkin
+// without "page" parameter it's the OrderedCollection, with "page=true" it's a page starting after "cursor"
+func (ah *ActivityPubHandler) GetCheckinShares(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "invalid checkin id", http.StatusBadRequest)
+		return
+	}
+
+	checkin, err := ah.checkinService.GetCheckinByID(r.Context(), id)
+	if err != nil || checkin.IsRemote {
+		http.Error(w, "checkin not found", http.StatusNotFound)
+		return
+	}
+
+	collectionURL := fmt.Sprintf("%s/shares", activitypub.CheckinObjectID(ah.baseURL, checkin.ID))
+
+	if r.URL.Query().Get("page") == "" {
+		writeActivityJSON(w, http.StatusOK, &activitypub.OrderedCollection{
+			Context:    activitypub.DefaultContext(),
+			ID:         collectionURL,
+			Type:       activitypub.CollectionTypeOrderedCollection,
+			TotalItems: checkin.ShareCount,
+			First:      fmt.Sprintf("%s?page=true", collectionURL),
+		})
+		return
+	}
+
+	// cursor is id of the last share of previous page
+	cursor := uuid.Nil
+	cursorParam := r.URL.Query().Get("cursor")
+	if cursorParam != "" {
+		cursor, err = uuid.Parse(cursorParam)
+		if err != nil {
+			http.Error(w, "invalid cursor", http.StatusBadRequest)
+			return
+		}
+	}
+
+	shares, err := ah.apServerService.GetShares(r.Context(), checkin.ID, cursor, FollowCollectionPageSize)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	items := make([]string, 0, len(shares))
+	for _, share := range shares {
+		items = append(items, share.ActivityID)
+	}
+
+	pageURL := fmt.Sprintf("%s?page=true", collectionURL)
+	if cursor != uuid.Nil {
+		pageURL = fmt.Sprintf("%s&cursor=%s", pageURL, cursor)
+	}
+
+	collectionPage := &activitypub.OrderedCollectionPage{
+		Context:      activitypub.DefaultContext(),
+		ID:           pageURL,
+		Type:         activitypub.CollectionTypeOrderedCollectionPage,
+		PartOf:       collectionURL,
+		OrderedItems: items,
+	}
+	// a full page may have more items after it
+	if len(shares) == FollowCollectionPageSize {
+		collectionPage.Next = fmt.Sprintf("%s?page=true&cursor=%s", collectionURL, shares[len(shares)-1].ID)
+	}
+
+	writeActivityJSON(w, http.StatusOK, collectionPage)
+}
+
+// GetActivity return Create activity of a checkin at its activity ID
+func (ah *ActivityPubHandler) GetActivity(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "invalid activity id", http.StatusBadRequest)
+		return
+	}
+
+	// activity_id of checkin is the canonical activity URL
+	checkin, err := ah.checkinService.GetCheckinByActivityID(r.Context(), fmt.Sprintf("%s/activities/%s", ah.baseURL, id))
+	if err != nil {
+		http.Error(w, "activity not found", http.StatusNotFound)
+		return
+	}
+
+	activity := activitypub.CheckinToCreateActivity(checkin, checkin.User.ActorID, ah.baseURL)
+	activity.Context = activitypub.DefaultContext()
+
+	writeActivityJSON(w, http.StatusOK, activity)
+}
+
+// GetFollowers return user's followers collection
+// https://www.w3.org/TR/activitypub/#followers
+func (ah *ActivityPubHandler) GetFollowers(w http.ResponseWriter, r *http.Request) {
+	ah.writeFollowCollection(w, r, "followers",
+		ah.apServerService.CountFollowers,
+		func(ctx context.Context, userID, cursor uuid.UUID, limit int) ([]string, uuid.UUID, error) {
+			followers, err := ah.apServerService.GetFollowers(ctx, userID, cursor, limit)
+			if err != nil {
+				return nil, uuid.Nil, err
+			}
+
+			actorIDs := make([]string, 0, len(followers))
+			lastID := uuid.Nil
+			for _, follower := range followers {
+				actorIDs = append(actorIDs, follower.ActorID)
+				lastID = follower.ID
+			}
+
+			return actorIDs, lastID, nil
+		},
+	)
+}
+
+// GetFollowing return collection of actors user follows
+// https://www.w3.org/TR/activitypub/#following
+func (ah *ActivityPubHandler) GetFollowing(w http.ResponseWriter, r *http.Request) {
+	ah.writeFollowCollection(w, r, "following",
+		ah.apServerService.CountFollowing,
+		func(ctx context.Context, userID, cursor uuid.UUID, limit int) ([]string, uuid.UUID, error) {
+			followings, err := ah.apServerService.GetFollowing(ctx, userID, cursor, limit)
+			if err != nil {
+				return nil, uuid.Nil, err
+			}
+
+			actorIDs := make([]string, 0, len(followings))
+			lastID := uuid.Nil
+			for _, following := range followings {
+				actorIDs = append(actorIDs, following.TargetActorID)
+				lastID = following.ID
+			}
+
+			return actorIDs, lastID, nil
+		},
+	)
+}
+
+// GetLiked return collection of objects user likes
+// https://www.w3.org/TR/activitypub/#liked
+func (ah *ActivityPubHandler) GetLiked(w http.ResponseWriter, r *http.Request) {
+	ah.writeFollowCollection(w, r, "liked",
+		ah.apServerService.CountLiked,
+		func(ctx context.Context, userID, cursor uuid.UUID, limit int) ([]string, uuid.UUID, error) {
+			likes, err := ah.apServerService.GetLiked(ctx, userID, cursor, limit)
+			if err != nil {
+				return nil, uuid.Nil, err
+			}
+
+			objectIDs := make([]string, 0, len(likes))
+			lastID := uuid.Nil
+			for _, like := range likes {
+				objectIDs = append(objectIDs, like.ObjectID)
+				lastID = like.ID
+			}
+
+			return objectIDs, lastID, nil
+		},
+	)
+}
+
+// writeFollowCollection write followers, following or liked collection of user in URL
+// without "page" parameter it's the OrderedCollection, with "page=true" it's a page starting after "cursor"
+// when user hides social graph only totalItems is published
+func (ah *ActivityPubHandler) writeFollowCollection(
+	w http.ResponseWriter,
+	r *http.Request,
+	name string,
+	count func(ctx context.Context, userID uuid.UUID) (int, error),
+	getPage func(ctx context.Context, userID, cursor uuid.UUID, limit int) ([]string, uuid.UUID, error),
+) {
+	username := chi.URLParam(r, "username")
+	if username == "" {
+		http.Error(w, "username is required", http.StatusBadRequest)
+		return
+	}
+
+	// get user by ID, username or previous username
+	user, ok := ah.getUserByHandle(w, r, username)
+	if !ok {
+		return
+	}
+
+	collectionURL := fmt.Sprintf("%s/%s", user.ActorID, name)
+
+	if r.URL.Query().Get("page") == "" {
+		totalItems, err := count(r.Context(), user.ID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		collection := &activitypub.OrderedCollection{
+			Context:    activitypub.DefaultContext(),
+			ID:         collectionURL,
+			Type:       activitypub.CollectionTypeOrderedCollection,
+			TotalItems: totalItems,
+		}
+		if !user.HideSocialGraph {
+			collection.First = fmt.Sprintf("%s?page=true", collectionURL)
+		}
+
+		writeActivityJSON(w, http.StatusOK, collection)
+		return
+	}
+
+	if user.HideSocialGraph {
+		http.Error(w, fmt.Sprintf("%s of this user are hidden", name), http.StatusForbidden)
+		return
+	}
+
+	// cursor is id of the last item of previous page
+	cursor := uuid.Nil
+	cursorParam := r.URL.Query().Get("cursor")
+	if cursorParam != "" {
+		parsedCursor, err := uuid.Parse(cursorParam)
+		if err != nil {
+			http.Error(w, "invalid cursor", http.StatusBadRequest)
+			return
+		}
+		cursor = parsedCursor
+	}
+
+	items, lastID, err := getPage(r.Context(), user.ID, cursor, FollowCollectionPageSize)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	pageURL := fmt.Sprintf("%s?page=true", collectionURL)
+	if cursor != uuid.Nil {
+		pageURL = fmt.Sprintf("%s&cursor=%s", pageURL, cursor)
+	}
+
+	collectionPage := &activitypub.OrderedCollectionPage{
+		Context:      activitypub.DefaultContext(),
+		ID:           pageURL,
+		Type:         activitypub.CollectionTypeOrderedCollectionPage,
+		PartOf:       collectionURL,
+		OrderedItems: items,
+	}
+	// a full page may have more items after it
+	if len(items) == FollowCollectionPageSize {
+		collectionPage.Next = fmt.Sprintf("%s?page=true&cursor=%s", collectionURL, lastID)
+	}
+
+	writeActivityJSON(w, http.StatusOK, collectionPage)
+}
+
+// PostUserInbox receive activity delivered to a user's inbox
+// https://www.w3.org/TR/activitypub/#inbox-delivery
+func (ah *ActivityPubHandler) PostUserInbox(w http.ResponseWriter, r *http.Request) {
+	username := chi.URLParam(r, "username")
+	if username == "" {
+		http.Error(w, "username is required", http.StatusBadRequest)
+		return
+	}
+
+	// get user by ID, username or previous username
+	user, ok := ah.getUserByHandle(w, r, username)
+	if !ok {
+		return
+	}
+
+	// read activity
+	body, ok := readInboxBody(w, r)
+	if !ok {
+		return
+	}
+
+	// handle activity
+	err := ah.apServerService.HandleInbox(r.Context(), user.ID, body)
+	if err != nil {
+		writeInboxError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// PostSharedInbox receive activity delivered to the server's shared inbox
+// https://www.w3.org/TR/activitypub/#shared-inbox-delivery
+func (ah *ActivityPubHandler) PostSharedInbox(w http.ResponseWriter, r *http.Request) {
+	// read activity
+	body, ok := readInboxBody(w, r)
+	if !ok {
+		return
+	}
+
+	// handle activity for every addressed local user
+	err := ah.apServerService.HandleSharedInbox(r.Context(), body)
+	if err != nil {
+		writeInboxError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// getUserByHandle get user by ID, username or previous username, write error response and return false when it fails
+func (ah *ActivityPubHandler) getUserByHandle(w http.ResponseWriter, r *http.Request, username string) (*models.User, bool) {
+	user, err := ah.userService.GetUserByHandle(r.Context(), username)
+	if errors.Is(err, pgx.ErrNoRows) {
+		http.Error(w, "user not found", http.StatusNotFound)
+		return nil, false
+	}
+	if err != nil {
+		ah.logger.Error("fail to get user by handle", zap.String("handle", username), zap.Error(err))
+		http.Error(w, "fail to get user", http.StatusInternalServerError)
+		return nil, false
+	}
+
+	return user, true
+}
+
+// readInboxBody read request body, write error response and return false when it fails
+func readInboxBody(w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+			return nil, false
+		}
+
+		http.Error(w, "fail to read request body", http.StatusBadRequest)
+		return nil, false
+	}
+
+	return body, true
+}
+
+// writeInboxError write error response for inbox request
+func writeInboxError(w http.ResponseWriter, err error) {
+	if errors.Is(err, activitypub.ErrInvalidActivity) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, activitypub.ErrDomainBlocked) {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
+
+// acceptsActivityJSON check if client asks for ActivityStreams JSON-LD
+// https://www.w3.org/TR/activitypub/#retrieving-objects
+func acceptsActivityJSON(r *http.Request) bool {
+	for _, mediaRange := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, _ := strings.Cut(mediaRange, ";")
+		mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+
+		if mediaType == activitypub.ContentTypeActivityJSON || mediaType == "application/ld+json" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// writeActivityJSON write ActivityStreams JSON response
+func writeActivityJSON(w http.ResponseWriter, statusCode int, v interface{}) {
+	w.Header().Set("Content-Type", fmt.Sprintf("%s; charset=utf-8", activitypub.ContentTypeActivityJSON))
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(v)
+}
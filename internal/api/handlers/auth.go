@@ -21,15 +21,15 @@ var tracer = otel.Tracer("api/handlers/auth")
 type AuthHandler struct {
 	userService services.UserService
 	tokenAuth   *jwtauth.JWTAuth
-	serverHost  string
+	baseURL     string
 }
 
 // NewAuthHandler
-func NewAuthHandler(userService services.UserService, tokenAuth *jwtauth.JWTAuth, serverHost string) *AuthHandler {
+func NewAuthHandler(userService services.UserService, tokenAuth *jwtauth.JWTAuth, baseURL string) *AuthHandler {
 	return &AuthHandler{
 		userService: userService,
 		tokenAuth:   tokenAuth,
-		serverHost:  serverHost,
+		baseURL:     baseURL,
 	}
 }
 
@@ -109,7 +109,7 @@ func (ah *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// register a user account
-	user, err := ah.userService.Register(ctx, ah.serverHost, req.Username, req.Email, req.Password)
+	user, err := ah.userService.Register(ctx, ah.baseURL, req.Username, req.Email, req.Password)
 	if err != nil {
 		span.RecordError(err)
 		span.SetAttributes(
@@ -0,0 +1,242 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"je-suis-ici-activitypub/internal/db/models"
+	"je-suis-ici-activitypub/internal/services"
+	"net/http"
+	"net/url"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// BlockHandler handle requests of local users blocking and muting accounts
+type BlockHandler struct {
+	blockService services.BlockService
+	authHandler  AuthHandler
+}
+
+// NewBlockHandler
+func NewBlockHandler(blockService services.BlockService, authHandler AuthHandler) *BlockHandler {
+	return &BlockHandler{
+		blockService: blockService,
+		authHandler:  authHandler,
+	}
+}
+
+// RegisterBlockRoutes register block and mute routes, they need JWT token
+func (bh *BlockHandler) RegisterBlockRoutes(r chi.Router) {
+	r.Get("/blocks", bh.ListBlocks)
+	r.Post("/blocks", bh.Block)
+	r.Delete("/blocks/{account}", bh.Unblock)
+	r.Get("/mutes", bh.ListMutes)
+	r.Post("/mutes", bh.Mute)
+	r.Delete("/mutes/{account}", bh.Unmute)
+}
+
+// BlockRequest
+type BlockRequest struct {
+	// Account "user@host" or actor URL
+	Account string `json:"account"`
+}
+
+// Block block an account, it's told with a Block activity
+func (bh *BlockHandler) Block(w http.ResponseWriter, r *http.Request) {
+	// get user id
+	userID, ok := getUserID(w, r, bh.authHandler)
+	if !ok {
+		return
+	}
+
+	account, ok := getBlockRequestAccount(w, r)
+	if !ok {
+		return
+	}
+
+	block, err := bh.blockService.Block(r.Context(), userID, account)
+	if err != nil {
+		writeBlockError(w, err)
+		return
+	}
+
+	// response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(block)
+}
+
+// Unblock remove block of an account
+func (bh *BlockHandler) Unblock(w http.ResponseWriter, r *http.Request) {
+	// get user id
+	userID, ok := getUserID(w, r, bh.authHandler)
+	if !ok {
+		return
+	}
+
+	account, ok := getAccountParam(w, r)
+	if !ok {
+		return
+	}
+
+	err := bh.blockService.Unblock(r.Context(), userID, account)
+	if err != nil {
+		writeBlockError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListBlocks list accounts user blocks, next page starts after "cursor"
+func (bh *BlockHandler) ListBlocks(w http.ResponseWriter, r *http.Request) {
+	// get user id
+	userID, ok := getUserID(w, r, bh.authHandler)
+	if !ok {
+		return
+	}
+
+	cursor, limit, ok := getCursorPage(w, r)
+	if !ok {
+		return
+	}
+
+	blocks, err := bh.blockService.ListBlocks(r.Context(), userID, cursor, limit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if blocks == nil {
+		blocks = []models.Block{}
+	}
+
+	response := map[string]interface{}{
+		"blocks": blocks,
+	}
+	if len(blocks) == limit {
+		response["next_cursor"] = blocks[len(blocks)-1].ID
+	}
+
+	// response
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// Mute hide an account's content from user without telling it
+func (bh *BlockHandler) Mute(w http.ResponseWriter, r *http.Request) {
+	// get user id
+	userID, ok := getUserID(w, r, bh.authHandler)
+	if !ok {
+		return
+	}
+
+	account, ok := getBlockRequestAccount(w, r)
+	if !ok {
+		return
+	}
+
+	mute, err := bh.blockService.Mute(r.Context(), userID, account)
+	if err != nil {
+		writeBlockError(w, err)
+		return
+	}
+
+	// response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(mute)
+}
+
+// Unmute
+func (bh *BlockHandler) Unmute(w http.ResponseWriter, r *http.Request) {
+	// get user id
+	userID, ok := getUserID(w, r, bh.authHandler)
+	if !ok {
+		return
+	}
+
+	account, ok := getAccountParam(w, r)
+	if !ok {
+		return
+	}
+
+	err := bh.blockService.Unmute(r.Context(), userID, account)
+	if err != nil {
+		writeBlockError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListMutes list accounts user mutes, next page starts after "cursor"
+func (bh *BlockHandler) ListMutes(w http.ResponseWriter, r *http.Request) {
+	// get user id
+	userID, ok := getUserID(w, r, bh.authHandler)
+	if !ok {
+		return
+	}
+
+	cursor, limit, ok := getCursorPage(w, r)
+	if !ok {
+		return
+	}
+
+	mutes, err := bh.blockService.ListMutes(r.Context(), userID, cursor, limit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if mutes == nil {
+		mutes = []models.Mute{}
+	}
+
+	response := map[string]interface{}{
+		"mutes": mutes,
+	}
+	if len(mutes) == limit {
+		response["next_cursor"] = mutes[len(mutes)-1].ID
+	}
+
+	// response
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// getBlockRequestAccount parse account of request body, write error response and return false when it's invalid
+func getBlockRequestAccount(w http.ResponseWriter, r *http.Request) (string, bool) {
+	var req BlockRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil || !isValidAccount(req.Account) {
+		http.Error(w, "account must be user@host or actor URL", http.StatusBadRequest)
+		return "", false
+	}
+
+	return req.Account, true
+}
+
+// getAccountParam get account of url, write error response and return false when it's invalid
+func getAccountParam(w http.ResponseWriter, r *http.Request) (string, bool) {
+	account, err := url.PathUnescape(chi.URLParam(r, "account"))
+	if err != nil || !isValidAccount(account) {
+		http.Error(w, "account must be user@host or actor URL", http.StatusBadRequest)
+		return "", false
+	}
+
+	return account, true
+}
+
+// writeBlockError write status code matching block service error
+// other errors come from resolving the account on its server
+func writeBlockError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidBlockTarget):
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+	case errors.Is(err, services.ErrNotBlocked):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	default:
+		http.Error(w, err.Error(), http.StatusBadGateway)
+	}
+}
@@ -2,11 +2,13 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
 	"io"
 	"je-suis-ici-activitypub/internal/activitypub"
+	"je-suis-ici-activitypub/internal/db/models"
 	"je-suis-ici-activitypub/internal/services"
 	"net/http"
 	"strconv"
@@ -20,18 +22,18 @@ type CheckinHandler struct {
 	mediaService    services.MediaService
 	apServerService *activitypub.ActivityPubServerService
 	authHandler     AuthHandler
-	serverHost      string
+	baseURL         string
 }
 
 // NewCheckinHandler
-func NewCheckinHandler(userService services.UserService, checkinService services.CheckinService, mediaService services.MediaService, apServerService *activitypub.ActivityPubServerService, authHandler AuthHandler, serverHost string) *CheckinHandler {
+func NewCheckinHandler(userService services.UserService, checkinService services.CheckinService, mediaService services.MediaService, apServerService *activitypub.ActivityPubServerService, authHandler AuthHandler, baseURL string) *CheckinHandler {
 	return &CheckinHandler{
 		userService:     userService,
 		checkinService:  checkinService,
 		mediaService:    mediaService,
 		apServerService: apServerService,
 		authHandler:     authHandler,
-		serverHost:      serverHost,
+		baseURL:         baseURL,
 	}
 }
 
@@ -41,6 +43,9 @@ func (ch *CheckinHandler) RegisterCheckinRoutes(r chi.Router) {
 	r.Post("/checkins", ch.CreateCheckin)
 	r.Get("/checkins", ch.GetUserCheckins)
 	r.Get("/checkins/{id}", ch.GetCheckinByID)
+	r.Patch("/checkins/{id}", ch.UpdateCheckin)
+	r.Get("/checkins/{id}/edits", ch.GetCheckinEdits)
+	r.Delete("/checkins/{id}", ch.DeleteCheckin)
 }
 
 // CreateCheckin
@@ -77,14 +82,19 @@ func (ch *CheckinHandler) CreateCheckin(w http.ResponseWriter, r *http.Request)
 	checkin, err := ch.checkinService.CreateCheckin(
 		r.Context(),
 		userID, req.Content, req.LocationName,
-		req.Latitude, req.Longitude, req.MediaIDs, r.Host,
+		req.Latitude, req.Longitude, req.MediaIDs, ch.baseURL,
 	)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	// TODO: public this new checkin to ActivityPub global newsfeed
+	// send new checkin to author's followers
+	err = ch.apServerService.PublishCheckin(r.Context(), checkin)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("checkin is created but fail to publish it: %v", err), http.StatusInternalServerError)
+		return
+	}
 
 	// return created checkin
 	w.Header().Set("Content-Type", "application/json")
@@ -92,17 +102,123 @@ func (ch *CheckinHandler) CreateCheckin(w http.ResponseWriter, r *http.Request)
 	json.NewEncoder(w).Encode(checkin)
 }
 
+// UpdateCheckin edit content, location or media of user's checkin and send Update to its audience
+func (ch *CheckinHandler) UpdateCheckin(w http.ResponseWriter, r *http.Request) {
+	// get user id
+	userID, ok := getUserID(w, r, ch.authHandler)
+	if !ok {
+		return
+	}
+
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "invalid checkin id", http.StatusBadRequest)
+		return
+	}
+
+	// parse request, fields which aren't given stay unchanged
+	var req services.CheckinUpdate
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+
+	checkin, err := ch.checkinService.UpdateCheckin(r.Context(), userID, id, req)
+	if err != nil {
+		writeCheckinError(w, err)
+		return
+	}
+
+	// send edit to servers which received the checkin
+	err = ch.apServerService.PublishCheckinUpdate(r.Context(), checkin)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("checkin is updated but fail to publish it: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(checkin)
+}
+
+// GetCheckinEdits get edit history of a checkin, newest first
+func (ch *CheckinHandler) GetCheckinEdits(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "invalid checkin id", http.StatusBadRequest)
+		return
+	}
+
+	edits, err := ch.checkinService.GetCheckinEdits(r.Context(), id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if edits == nil {
+		edits = []models.CheckinEdit{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"edits": edits,
+	})
+}
+
+// DeleteCheckin delete user's checkin and send Delete to servers which received it
+func (ch *CheckinHandler) DeleteCheckin(w http.ResponseWriter, r *http.Request) {
+	// get user id
+	userID, ok := getUserID(w, r, ch.authHandler)
+	if !ok {
+		return
+	}
+
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "invalid checkin id", http.StatusBadRequest)
+		return
+	}
+
+	checkin, err := ch.checkinService.DeleteCheckin(r.Context(), userID, id, ch.baseURL)
+	if err != nil {
+		writeCheckinError(w, err)
+		return
+	}
+
+	// tell servers which received the checkin
+	err = ch.apServerService.PublishCheckinDelete(r.Context(), checkin)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("checkin is deleted but fail to publish it: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeCheckinError write status code matching checkin service error
+func writeCheckinError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, services.ErrCheckinNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, services.ErrNotCheckinOwner):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, services.ErrInvalidMedia):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
 // UploadMedia
 func (ch *CheckinHandler) UploadMedia(w http.ResponseWriter, r *http.Request) {
-	// valid user
-	_, err := ch.authHandler.GetUserIDByAuthTokenFromRequest(r)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusUnauthorized)
+	// valid user, media is attached to checkins of its uploader only
+	userID, ok := getUserID(w, r, ch.authHandler)
+	if !ok {
 		return
 	}
 
 	// parse form data from request
-	err = r.ParseMultipartForm(32 << 20) // max 32 MB
+	err := r.ParseMultipartForm(32 << 20) // max 32 MB
 	if err != nil {
 		http.Error(w, "Invalid form", http.StatusBadRequest)
 		return
@@ -131,7 +247,7 @@ func (ch *CheckinHandler) UploadMedia(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// upload file
-	media, err := ch.mediaService.UploadMedia(r.Context(), fileData, "image", contentType)
+	media, err := ch.mediaService.UploadMedia(r.Context(), userID, fileData, "image", contentType)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -298,7 +414,7 @@ func (ch *CheckinHandler) SendCheckinToUser(w http.ResponseWriter, r *http.Reque
 
 	// create a checkin object
 	checkinID := uuid.New()
-	checkinURL := fmt.Sprintf("https://%s/checkins/%s", ch.serverHost, checkinID)
+	checkinURL := activitypub.CheckinObjectID(ch.baseURL, checkinID)
 
 	// create activityPub Note object for the checkin
 	note := &activitypub.Object{
@@ -307,13 +423,13 @@ func (ch *CheckinHandler) SendCheckinToUser(w http.ResponseWriter, r *http.Reque
 		Type:         "Note",
 		Content:      req.Content,
 		Published:    time.Now().UTC(),
-		AttributedTo: sender.ActorID,
-		Location: &activitypub.Place{
+		AttributedTo: activitypub.IRIProperty(sender.ActorID),
+		Location: activitypub.ObjectProperty(&activitypub.Place{
 			Type:      "Place",
 			Name:      req.LocationName,
 			Latitude:  req.Latitude,
 			Longitude: req.Longitude,
-		},
+		}),
 	}
 
 	// create activity
@@ -321,8 +437,8 @@ func (ch *CheckinHandler) SendCheckinToUser(w http.ResponseWriter, r *http.Reque
 		Context:   activitypub.DefaultContext(),
 		ID:        fmt.Sprintf("%s/activity", checkinURL),
 		Type:      "Create",
-		Actor:     sender.ActorID,
-		Object:    note,
+		Actor:     activitypub.IRIProperty(sender.ActorID),
+		Object:    activitypub.ObjectProperty(note),
 		Published: time.Now().UTC(),
 		To:        []string{recipient.ActorID},
 	}
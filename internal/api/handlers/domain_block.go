@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"je-suis-ici-activitypub/internal/db/models"
+	"je-suis-ici-activitypub/internal/services"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// MaxDomainBlockImportSize limit size of an imported domain block list
+const MaxDomainBlockImportSize = 1 << 20
+
+// DomainBlockHandler handle requests of admins managing blocked and silenced domains
+type DomainBlockHandler struct {
+	domainBlockService services.DomainBlockService
+	authHandler        AuthHandler
+}
+
+// NewDomainBlockHandler
+func NewDomainBlockHandler(domainBlockService services.DomainBlockService, authHandler AuthHandler) *DomainBlockHandler {
+	return &DomainBlockHandler{
+		domainBlockService: domainBlockService,
+		authHandler:        authHandler,
+	}
+}
+
+// RegisterDomainBlockRoutes register domain block routes, they need JWT token of an admin
+func (dbh *DomainBlockHandler) RegisterDomainBlockRoutes(r chi.Router) {
+	r.Get("/admin/domain-blocks", dbh.GetDomainBlocks)
+	r.Post("/admin/domain-blocks", dbh.BlockDomain)
+	r.Get("/admin/domain-blocks/export", dbh.ExportDomainBlocks)
+	r.Post("/admin/domain-blocks/import", dbh.ImportDomainBlocks)
+	r.Delete("/admin/domain-blocks/{domain}", dbh.UnblockDomain)
+}
+
+// BlockDomainRequest
+type BlockDomainRequest struct {
+	// Domain blocked with its subdomains, "*.example.com" is accepted
+	Domain string `json:"domain"`
+	// Severity "suspend" (default) or "silence"
+	Severity      string `json:"severity,omitempty"`
+	RejectMedia   bool   `json:"reject_media,omitempty"`
+	RejectReports bool   `json:"reject_reports,omitempty"`
+	PublicComment string `json:"public_comment,omitempty"`
+	Obfuscate     bool   `json:"obfuscate,omitempty"`
+}
+
+// GetDomainBlocks list all domain blocks
+func (dbh *DomainBlockHandler) GetDomainBlocks(w http.ResponseWriter, r *http.Request) {
+	// get user id
+	userID, ok := getUserID(w, r, dbh.authHandler)
+	if !ok {
+		return
+	}
+
+	blocks, err := dbh.domainBlockService.GetDomainBlocks(r.Context(), userID)
+	if err != nil {
+		writeDomainBlockError(w, err)
+		return
+	}
+
+	if blocks == nil {
+		blocks = []models.DomainBlock{}
+	}
+
+	// response
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"domain_blocks": blocks,
+	})
+}
+
+// BlockDomain add or replace block of a domain
+func (dbh *DomainBlockHandler) BlockDomain(w http.ResponseWriter, r *http.Request) {
+	// get user id
+	userID, ok := getUserID(w, r, dbh.authHandler)
+	if !ok {
+		return
+	}
+
+	var req BlockDomainRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil || req.Domain == "" {
+		http.Error(w, "domain is required", http.StatusBadRequest)
+		return
+	}
+
+	block := &models.DomainBlock{
+		Domain:        req.Domain,
+		Severity:      req.Severity,
+		RejectMedia:   req.RejectMedia,
+		RejectReports: req.RejectReports,
+		PublicComment: req.PublicComment,
+		Obfuscate:     req.Obfuscate,
+	}
+
+	err = dbh.domainBlockService.BlockDomain(r.Context(), userID, block)
+	if err != nil {
+		writeDomainBlockError(w, err)
+		return
+	}
+
+	// response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(block)
+}
+
+// UnblockDomain remove block of a domain
+func (dbh *DomainBlockHandler) UnblockDomain(w http.ResponseWriter, r *http.Request) {
+	// get user id
+	userID, ok := getUserID(w, r, dbh.authHandler)
+	if !ok {
+		return
+	}
+
+	err := dbh.domainBlockService.UnblockDomain(r.Context(), userID, chi.URLParam(r, "domain"))
+	if err != nil {
+		writeDomainBlockError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ImportDomainBlocks add blocks of a CSV file in Mastodon's export format, request body is the file
+func (dbh *DomainBlockHandler) ImportDomainBlocks(w http.ResponseWriter, r *http.Request) {
+	// get user id
+	userID, ok := getUserID(w, r, dbh.authHandler)
+	if !ok {
+		return
+	}
+
+	body := http.MaxBytesReader(w, r.Body, MaxDomainBlockImportSize)
+
+	imported, err := dbh.domainBlockService.ImportDomainBlocks(r.Context(), userID, body)
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		writeDomainBlockError(w, err)
+		return
+	}
+
+	// response
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"imported": imported,
+	})
+}
+
+// ExportDomainBlocks download all blocks as CSV in Mastodon's export format
+func (dbh *DomainBlockHandler) ExportDomainBlocks(w http.ResponseWriter, r *http.Request) {
+	// get user id
+	userID, ok := getUserID(w, r, dbh.authHandler)
+	if !ok {
+		return
+	}
+
+	var csvFile bytes.Buffer
+	err := dbh.domainBlockService.ExportDomainBlocks(r.Context(), userID, &csvFile)
+	if err != nil {
+		writeDomainBlockError(w, err)
+		return
+	}
+
+	// response
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", "domain_blocks.csv"))
+	w.Write(csvFile.Bytes())
+}
+
+// writeDomainBlockError write status code matching domain block service error
+func writeDomainBlockError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, services.ErrNotAdmin):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, services.ErrInvalidDomain), errors.Is(err, services.ErrInvalidSeverity),
+		errors.Is(err, services.ErrInvalidDomainBlockCSV):
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -3,6 +3,8 @@ package handlers
 import (
 	"encoding/json"
 	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"je-suis-ici-activitypub/internal/activitypub"
 	"je-suis-ici-activitypub/internal/services"
 	"net/http"
 	"strconv"
@@ -10,16 +12,24 @@ import (
 
 type FeedHandler struct {
 	checkinService services.CheckinService
+	authHandler    AuthHandler
 }
 
-func NewFeedHandler(checkinService services.CheckinService) *FeedHandler {
+func NewFeedHandler(checkinService services.CheckinService, authHandler AuthHandler) *FeedHandler {
 	return &FeedHandler{
 		checkinService: checkinService,
+		authHandler:    authHandler,
 	}
 }
 
 func (fh *FeedHandler) RegisterFeedRouters(r chi.Router) {
 	r.Get("/feed", fh.GetGlobalFeed)
+	r.Get("/tags/{tag}", fh.GetTagFeed)
+}
+
+// RegisterHomeFeedRouters register home feed routes, they need JWT token
+func (fh *FeedHandler) RegisterHomeFeedRouters(r chi.Router) {
+	r.Get("/feed/home", fh.GetHomeFeed)
 }
 
 func (fh *FeedHandler) GetGlobalFeed(w http.ResponseWriter, r *http.Request) {
@@ -34,8 +44,14 @@ func (fh *FeedHandler) GetGlobalFeed(w http.ResponseWriter, r *http.Request) {
 		pageSize = 20
 	}
 
+	// signed in viewer doesn't see actors they blocked or muted, token is optional on this route
+	viewerID := uuid.Nil
+	if userID, err := fh.authHandler.GetUserIDByAuthTokenFromRequest(r); err == nil {
+		viewerID, _ = uuid.Parse(userID)
+	}
+
 	// get global feed
-	checkins, err := fh.checkinService.GetGlobalFeed(r.Context(), page, pageSize)
+	checkins, err := fh.checkinService.GetGlobalFeed(r.Context(), viewerID, page, pageSize)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -49,3 +65,78 @@ func (fh *FeedHandler) GetGlobalFeed(w http.ResponseWriter, r *http.Request) {
 		"page_size": pageSize,
 	})
 }
+
+// GetHomeFeed get checkins of user and accounts user follows, boosted checkins have "boosted_by"
+func (fh *FeedHandler) GetHomeFeed(w http.ResponseWriter, r *http.Request) {
+	// get user id
+	userID, ok := getUserID(w, r, fh.authHandler)
+	if !ok {
+		return
+	}
+
+	// get pagination
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	if page <= 0 {
+		page = 1
+	}
+
+	pageSize, _ := strconv.Atoi(r.URL.Query().Get("page_size"))
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	// get home feed
+	checkins, err := fh.checkinService.GetHomeFeed(r.Context(), userID, page, pageSize)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// return home feed
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"checkins":  checkins,
+		"page":      page,
+		"page_size": pageSize,
+	})
+}
+
+// GetTagFeed get local and remote checkins with a hashtag, token is optional like the global feed
+func (fh *FeedHandler) GetTagFeed(w http.ResponseWriter, r *http.Request) {
+	tag := chi.URLParam(r, "tag")
+	if tag == "" {
+		http.Error(w, "tag is required", http.StatusBadRequest)
+		return
+	}
+
+	// get pagination
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	if page <= 0 {
+		page = 1
+	}
+
+	pageSize, _ := strconv.Atoi(r.URL.Query().Get("page_size"))
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	viewerID := uuid.Nil
+	if userID, err := fh.authHandler.GetUserIDByAuthTokenFromRequest(r); err == nil {
+		viewerID, _ = uuid.Parse(userID)
+	}
+
+	checkins, err := fh.checkinService.GetCheckinsByTag(r.Context(), viewerID, tag, page, pageSize)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// return hashtag feed
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"tag":       activitypub.NormalizeTag(tag),
+		"checkins":  checkins,
+		"page":      page,
+		"page_size": pageSize,
+	})
+}
@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"je-suis-ici-activitypub/internal/activitypub"
+	"je-suis-ici-activitypub/internal/services"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// FollowHandler handle requests of local users following remote accounts
+type FollowHandler struct {
+	followService services.FollowService
+	authHandler   AuthHandler
+}
+
+// NewFollowHandler
+func NewFollowHandler(followService services.FollowService, authHandler AuthHandler) *FollowHandler {
+	return &FollowHandler{
+		followService: followService,
+		authHandler:   authHandler,
+	}
+}
+
+// RegisterFollowRoutes register follow routes, they need JWT token
+func (fh *FollowHandler) RegisterFollowRoutes(r chi.Router) {
+	r.Post("/follows", fh.Follow)
+	r.Get("/follows", fh.ListFollowing)
+	r.Delete("/follows/{account}", fh.Unfollow)
+}
+
+// FollowRequest
+type FollowRequest struct {
+	// Account "user@host" or actor URL
+	Account string `json:"account"`
+}
+
+// Follow follow a remote account, follow stays pending until the remote server accepts it
+func (fh *FollowHandler) Follow(w http.ResponseWriter, r *http.Request) {
+	// get user id
+	userID, ok := getUserID(w, r, fh.authHandler)
+	if !ok {
+		return
+	}
+
+	// parse request
+	var req FollowRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+
+	if !isValidAccount(req.Account) {
+		http.Error(w, "account must be user@host or actor URL", http.StatusBadRequest)
+		return
+	}
+
+	following, err := fh.followService.Follow(r.Context(), userID, req.Account)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	// response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(following)
+}
+
+// Unfollow stop following a remote account
+func (fh *FollowHandler) Unfollow(w http.ResponseWriter, r *http.Request) {
+	// get user id
+	userID, ok := getUserID(w, r, fh.authHandler)
+	if !ok {
+		return
+	}
+
+	account, err := url.PathUnescape(chi.URLParam(r, "account"))
+	if err != nil || !isValidAccount(account) {
+		http.Error(w, "account must be user@host or actor URL", http.StatusBadRequest)
+		return
+	}
+
+	err = fh.followService.Unfollow(r.Context(), userID, account)
+	if err != nil {
+		if errors.Is(err, services.ErrNotFollowing) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListFollowing list accounts user follows with their status
+func (fh *FollowHandler) ListFollowing(w http.ResponseWriter, r *http.Request) {
+	// get user id
+	userID, ok := getUserID(w, r, fh.authHandler)
+	if !ok {
+		return
+	}
+
+	followings, err := fh.followService.ListFollowing(r.Context(), userID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if followings == nil {
+		followings = []activitypub.Following{}
+	}
+
+	// response
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"following": followings,
+	})
+}
+
+// getUserID get authenticated user ID, write error response and return false when it fails
+func getUserID(w http.ResponseWriter, r *http.Request, authHandler AuthHandler) (uuid.UUID, bool) {
+	userIDFromRequest, err := authHandler.GetUserIDByAuthTokenFromRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return uuid.Nil, false
+	}
+
+	userID, err := uuid.Parse(userIDFromRequest)
+	if err != nil {
+		http.Error(w, "invalid user ID", http.StatusBadRequest)
+		return uuid.Nil, false
+	}
+
+	return userID, true
+}
+
+// isValidAccount check if account is "user@host" or actor URL
+func isValidAccount(account string) bool {
+	if strings.HasPrefix(account, "https://") || strings.HasPrefix(account, "http://") {
+		_, err := url.Parse(account)
+		return err == nil
+	}
+
+	_, _, err := activitypub.ParseAcctResource(account)
+	return err == nil
+}
@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"je-suis-ici-activitypub/internal/activitypub"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// FollowRequestHandler handle requests of locked users approving or rejecting their follow requests
+type FollowRequestHandler struct {
+	apServerService *activitypub.ActivityPubServerService
+	authHandler     AuthHandler
+}
+
+// NewFollowRequestHandler
+func NewFollowRequestHandler(apServerService *activitypub.ActivityPubServerService, authHandler AuthHandler) *FollowRequestHandler {
+	return &FollowRequestHandler{
+		apServerService: apServerService,
+		authHandler:     authHandler,
+	}
+}
+
+// RegisterFollowRequestRoutes register follow request routes, they need JWT token
+func (frh *FollowRequestHandler) RegisterFollowRequestRoutes(r chi.Router) {
+	r.Get("/follow-requests", frh.GetFollowRequests)
+	r.Post("/follow-requests/{id}/approve", frh.ApproveFollowRequest)
+	r.Post("/follow-requests/{id}/reject", frh.RejectFollowRequest)
+}
+
+// GetFollowRequests list user's pending follow requests, next page starts after "cursor"
+func (frh *FollowRequestHandler) GetFollowRequests(w http.ResponseWriter, r *http.Request) {
+	// get user id
+	userID, ok := getUserID(w, r, frh.authHandler)
+	if !ok {
+		return
+	}
+
+	cursor, limit, ok := getCursorPage(w, r)
+	if !ok {
+		return
+	}
+
+	requests, err := frh.apServerService.GetFollowRequests(r.Context(), userID, cursor, limit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if requests == nil {
+		requests = []activitypub.FollowRequest{}
+	}
+
+	response := map[string]interface{}{
+		"follow_requests": requests,
+	}
+	if len(requests) == limit {
+		response["next_cursor"] = requests[len(requests)-1].ID
+	}
+
+	// response
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// ApproveFollowRequest accept follow request, the requesting actor becomes user's follower
+func (frh *FollowRequestHandler) ApproveFollowRequest(w http.ResponseWriter, r *http.Request) {
+	frh.answerFollowRequest(w, r, frh.apServerService.ApproveFollowRequest)
+}
+
+// RejectFollowRequest reject follow request
+func (frh *FollowRequestHandler) RejectFollowRequest(w http.ResponseWriter, r *http.Request) {
+	frh.answerFollowRequest(w, r, frh.apServerService.RejectFollowRequest)
+}
+
+// answerFollowRequest approve or reject the follow request in url
+func (frh *FollowRequestHandler) answerFollowRequest(w http.ResponseWriter, r *http.Request, answer func(ctx context.Context, userID, requestID uuid.UUID) error) {
+	// get user id
+	userID, ok := getUserID(w, r, frh.authHandler)
+	if !ok {
+		return
+	}
+
+	requestID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "invalid follow request id", http.StatusBadRequest)
+		return
+	}
+
+	err = answer(r.Context(), userID, requestID)
+	if err != nil {
+		writeFollowRequestError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// getCursorPage get "cursor" and "limit" query parameters of a keyset paginated list
+// cursor is id of the last item of previous page, write error response and return false when they're invalid
+func getCursorPage(w http.ResponseWriter, r *http.Request) (uuid.UUID, int, bool) {
+	var err error
+
+	cursor := uuid.Nil
+	cursorParam := r.URL.Query().Get("cursor")
+	if cursorParam != "" {
+		cursor, err = uuid.Parse(cursorParam)
+		if err != nil {
+			http.Error(w, "invalid cursor", http.StatusBadRequest)
+			return uuid.Nil, 0, false
+		}
+	}
+
+	limit := 10
+	limitParam := r.URL.Query().Get("limit")
+	if limitParam != "" {
+		limit, err = strconv.Atoi(limitParam)
+		if err != nil || limit <= 0 || limit > 50 {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return uuid.Nil, 0, false
+		}
+	}
+
+	return cursor, limit, true
+}
+
+// writeFollowRequestError write status code matching follow request error
+func writeFollowRequestError(w http.ResponseWriter, err error) {
+	if errors.Is(err, activitypub.ErrFollowRequestNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
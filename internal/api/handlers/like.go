@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"je-suis-ici-activitypub/internal/services"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// LikeHandler handle requests of local users liking checkins
+type LikeHandler struct {
+	likeService services.LikeService
+	authHandler AuthHandler
+}
+
+// NewLikeHandler
+func NewLikeHandler(likeService services.LikeService, authHandler AuthHandler) *LikeHandler {
+	return &LikeHandler{
+		likeService: likeService,
+		authHandler: authHandler,
+	}
+}
+
+// RegisterLikeRoutes register like routes, they need JWT token
+func (lh *LikeHandler) RegisterLikeRoutes(r chi.Router) {
+	r.Post("/checkins/{id}/like", lh.Like)
+	r.Delete("/checkins/{id}/like", lh.Unlike)
+}
+
+// Like like a local or remote checkin
+func (lh *LikeHandler) Like(w http.ResponseWriter, r *http.Request) {
+	// get user id
+	userID, ok := getUserID(w, r, lh.authHandler)
+	if !ok {
+		return
+	}
+
+	checkinID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "invalid checkin id", http.StatusBadRequest)
+		return
+	}
+
+	like, err := lh.likeService.Like(r.Context(), userID, checkinID)
+	if err != nil {
+		writeLikeError(w, err)
+		return
+	}
+
+	// response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(like)
+}
+
+// Unlike remove like on a checkin
+func (lh *LikeHandler) Unlike(w http.ResponseWriter, r *http.Request) {
+	// get user id
+	userID, ok := getUserID(w, r, lh.authHandler)
+	if !ok {
+		return
+	}
+
+	checkinID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "invalid checkin id", http.StatusBadRequest)
+		return
+	}
+
+	err = lh.likeService.Unlike(r.Context(), userID, checkinID)
+	if err != nil {
+		writeLikeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeLikeError write status code matching like service error
+func writeLikeError(w http.ResponseWriter, err error) {
+	if errors.Is(err, services.ErrCheckinNotFound) || errors.Is(err, services.ErrNotLiked) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
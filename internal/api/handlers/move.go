@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"je-suis-ici-activitypub/internal/services"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// MoveHandler handle requests of local users setting aliases and moving to another account
+type MoveHandler struct {
+	moveService services.MoveService
+	authHandler AuthHandler
+}
+
+// NewMoveHandler
+func NewMoveHandler(moveService services.MoveService, authHandler AuthHandler) *MoveHandler {
+	return &MoveHandler{
+		moveService: moveService,
+		authHandler: authHandler,
+	}
+}
+
+// RegisterMoveRoutes register alias and move routes, they need JWT token
+func (mh *MoveHandler) RegisterMoveRoutes(r chi.Router) {
+	r.Put("/account/aliases", mh.SetAliases)
+	r.Post("/account/move", mh.Move)
+}
+
+// SetAliasesRequest
+type SetAliasesRequest struct {
+	// Aliases accounts as "user@host" or actor URLs
+	Aliases []string `json:"aliases"`
+}
+
+// MoveRequest
+type MoveRequest struct {
+	// Target account as "user@host" or actor URL
+	Target string `json:"target"`
+}
+
+// SetAliases replace user's aliases, the account user moves from must be listed before moving
+func (mh *MoveHandler) SetAliases(w http.ResponseWriter, r *http.Request) {
+	// get user id
+	userID, ok := getUserID(w, r, mh.authHandler)
+	if !ok {
+		return
+	}
+
+	var req SetAliasesRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	user, err := mh.moveService.SetAliases(r.Context(), userID, req.Aliases)
+	if err != nil {
+		writeMoveError(w, err)
+		return
+	}
+
+	// response
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"actor_id":      user.ActorID,
+		"also_known_as": user.AlsoKnownAs,
+	})
+}
+
+// Move move user's followers to the target account
+func (mh *MoveHandler) Move(w http.ResponseWriter, r *http.Request) {
+	// get user id
+	userID, ok := getUserID(w, r, mh.authHandler)
+	if !ok {
+		return
+	}
+
+	var req MoveRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil || req.Target == "" {
+		http.Error(w, "target is required", http.StatusBadRequest)
+		return
+	}
+
+	user, err := mh.moveService.Move(r.Context(), userID, req.Target)
+	if err != nil {
+		writeMoveError(w, err)
+		return
+	}
+
+	// response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"actor_id": user.ActorID,
+		"moved_to": user.MovedTo,
+	})
+}
+
+// writeMoveError write status code matching move service error
+func writeMoveError(w http.ResponseWriter, err error) {
+	if errors.Is(err, services.ErrInvalidAlias) || errors.Is(err, services.ErrMoveTargetNotAliased) {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"je-suis-ici-activitypub/internal/activitypub"
+	"je-suis-ici-activitypub/internal/services"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// NodeInfoHandler handle NodeInfo discovery and document requests
+type NodeInfoHandler struct {
+	userService    services.UserService
+	checkinService services.CheckinService
+	baseURL        string
+}
+
+// NewNodeInfoHandler
+func NewNodeInfoHandler(userService services.UserService, checkinService services.CheckinService, baseURL string) *NodeInfoHandler {
+	return &NodeInfoHandler{
+		userService:    userService,
+		checkinService: checkinService,
+		baseURL:        baseURL,
+	}
+}
+
+// RegisterNodeInfoDiscoveryRoutes register discovery route under "/.well-known"
+func (nh *NodeInfoHandler) RegisterNodeInfoDiscoveryRoutes(r chi.Router) {
+	r.Get("/nodeinfo", nh.GetNodeInfoDiscovery)
+}
+
+// RegisterNodeInfoRoutes register NodeInfo document routes
+func (nh *NodeInfoHandler) RegisterNodeInfoRoutes(r chi.Router) {
+	r.Get("/nodeinfo/2.1", nh.GetNodeInfo)
+}
+
+// GetNodeInfoDiscovery return links to the NodeInfo documents this server supports
+func (nh *NodeInfoHandler) GetNodeInfoDiscovery(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(activitypub.NodeInfoDiscovery{
+		Links: []activitypub.NodeInfoLink{
+			{
+				Rel:  activitypub.NodeInfoSchema21,
+				Href: fmt.Sprintf("%s/nodeinfo/2.1", nh.baseURL),
+			},
+		},
+	})
+}
+
+// GetNodeInfo return NodeInfo 2.1 document
+func (nh *NodeInfoHandler) GetNodeInfo(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	now := time.Now()
+
+	// get usage counts
+	totalUsers, err := nh.userService.CountUsers(ctx)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	activeMonth, err := nh.userService.CountActiveUsers(ctx, now.AddDate(0, 0, -30))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	activeHalfyear, err := nh.userService.CountActiveUsers(ctx, now.AddDate(0, 0, -180))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	localPosts, err := nh.checkinService.CountLocalCheckins(ctx)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// response
+	w.Header().Set("Content-Type", activitypub.ContentTypeNodeInfo21)
+	json.NewEncoder(w).Encode(activitypub.NodeInfo{
+		Version: "2.1",
+		Software: activitypub.NodeInfoSoftware{
+			Name:       activitypub.SoftwareName,
+			Version:    activitypub.SoftwareVersion,
+			Repository: activitypub.SoftwareRepository,
+			Homepage:   activitypub.SoftwareRepository,
+		},
+		Protocols: []string{activitypub.ProtocolActivityPub},
+		Services: activitypub.NodeInfoServices{
+			Inbound:  []string{},
+			Outbound: []string{},
+		},
+		// anyone can create an account through /auth/register
+		OpenRegistrations: true,
+		Usage: activitypub.NodeInfoUsage{
+			Users: activitypub.NodeInfoUsers{
+				Total:          totalUsers,
+				ActiveMonth:    activeMonth,
+				ActiveHalfyear: activeHalfyear,
+			},
+			LocalPosts: localPosts,
+		},
+		Metadata: map[string]interface{}{},
+	})
+}
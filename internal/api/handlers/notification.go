@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"encoding/json"
+	"je-suis-ici-activitypub/internal/services"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// NotificationHandler handle requests of local users reading their notifications
+type NotificationHandler struct {
+	notificationService services.NotificationService
+	authHandler         AuthHandler
+}
+
+// NewNotificationHandler
+func NewNotificationHandler(notificationService services.NotificationService, authHandler AuthHandler) *NotificationHandler {
+	return &NotificationHandler{
+		notificationService: notificationService,
+		authHandler:         authHandler,
+	}
+}
+
+// RegisterNotificationRoutes register notification routes, they need JWT token
+func (nh *NotificationHandler) RegisterNotificationRoutes(r chi.Router) {
+	r.Get("/notifications", nh.GetNotifications)
+	r.Post("/notifications/read", nh.MarkNotificationsRead)
+}
+
+// GetNotifications get user's notifications, newest first
+func (nh *NotificationHandler) GetNotifications(w http.ResponseWriter, r *http.Request) {
+	// get user id
+	userID, ok := getUserID(w, r, nh.authHandler)
+	if !ok {
+		return
+	}
+
+	// get pagination
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	if page <= 0 {
+		page = 1
+	}
+
+	pageSize, _ := strconv.Atoi(r.URL.Query().Get("page_size"))
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	notifications, err := nh.notificationService.GetNotifications(r.Context(), userID, page, pageSize)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// response
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"notifications": notifications,
+		"page":          page,
+		"page_size":     pageSize,
+	})
+}
+
+// MarkNotificationsRead mark all user's notifications as read
+func (nh *NotificationHandler) MarkNotificationsRead(w http.ResponseWriter, r *http.Request) {
+	// get user id
+	userID, ok := getUserID(w, r, nh.authHandler)
+	if !ok {
+		return
+	}
+
+	err := nh.notificationService.MarkNotificationsRead(r.Context(), userID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"je-suis-ici-activitypub/internal/services"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// ShareHandler handle requests of local users boosting checkins
+type ShareHandler struct {
+	shareService services.ShareService
+	authHandler  AuthHandler
+}
+
+// NewShareHandler
+func NewShareHandler(shareService services.ShareService, authHandler AuthHandler) *ShareHandler {
+	return &ShareHandler{
+		shareService: shareService,
+		authHandler:  authHandler,
+	}
+}
+
+// RegisterShareRoutes register boost routes, they need JWT token
+func (sh *ShareHandler) RegisterShareRoutes(r chi.Router) {
+	r.Post("/checkins/{id}/boost", sh.Boost)
+	r.Delete("/checkins/{id}/boost", sh.Unboost)
+}
+
+// Boost boost a local or remote checkin to user's followers
+func (sh *ShareHandler) Boost(w http.ResponseWriter, r *http.Request) {
+	// get user id
+	userID, ok := getUserID(w, r, sh.authHandler)
+	if !ok {
+		return
+	}
+
+	checkinID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "invalid checkin id", http.StatusBadRequest)
+		return
+	}
+
+	share, err := sh.shareService.Boost(r.Context(), userID, checkinID)
+	if err != nil {
+		writeShareError(w, err)
+		return
+	}
+
+	// response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(share)
+}
+
+// Unboost remove boost of a checkin
+func (sh *ShareHandler) Unboost(w http.ResponseWriter, r *http.Request) {
+	// get user id
+	userID, ok := getUserID(w, r, sh.authHandler)
+	if !ok {
+		return
+	}
+
+	checkinID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "invalid checkin id", http.StatusBadRequest)
+		return
+	}
+
+	err = sh.shareService.Unboost(r.Context(), userID, checkinID)
+	if err != nil {
+		writeShareError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeShareError write status code matching share service error
+func writeShareError(w http.ResponseWriter, err error) {
+	if errors.Is(err, services.ErrCheckinNotFound) || errors.Is(err, services.ErrNotBoosted) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
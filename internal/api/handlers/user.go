@@ -2,6 +2,8 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
 	"je-suis-ici-activitypub/internal/activitypub"
@@ -11,18 +13,18 @@ import (
 )
 
 type UserHandler struct {
-	userService  services.UserService
-	actorService activitypub.ActorService
-	authHandler  AuthHandler
-	serverHost   string
+	userService     services.UserService
+	apServerService *activitypub.ActivityPubServerService
+	authHandler     AuthHandler
+	serverHost      string
 }
 
-func NewUserHandler(userService services.UserService, actorService activitypub.ActorService, authHandler AuthHandler, serverHost string) *UserHandler {
+func NewUserHandler(userService services.UserService, apServerService *activitypub.ActivityPubServerService, authHandler AuthHandler, serverHost string) *UserHandler {
 	return &UserHandler{
-		userService:  userService,
-		actorService: actorService,
-		authHandler:  authHandler,
-		serverHost:   serverHost,
+		userService:     userService,
+		apServerService: apServerService,
+		authHandler:     authHandler,
+		serverHost:      serverHost,
 	}
 }
 
@@ -38,18 +40,24 @@ type UpdateUserRequest struct {
 	AvatarURL   *string `json:"avatar_url,omitempty"`
 	PublicKey   *string `json:"public-key,omitempty"`
 	PrivateKey  *string `json:"private-key,omitempty"`
+	// HideSocialGraph only publish number of followers and following
+	HideSocialGraph *bool `json:"hide_social_graph,omitempty"`
+	// Locked follows wait for user's approval
+	Locked *bool `json:"locked,omitempty"`
 }
 
 type UpdateUserResponse struct {
-	ID          uuid.UUID `json:"id"`
-	Username    string    `json:"username"`
-	DisplayName string    `json:"display_name,omitempty"`
-	Email       string    `json:"email"`
-	AvatarURL   string    `json:"avatar_url,omitempty"`
-	ActorID     string    `json:"actor_id,omitempty"`
-	PublicKey   string    `json:"public_key,omitempty"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID              uuid.UUID `json:"id"`
+	Username        string    `json:"username"`
+	DisplayName     string    `json:"display_name,omitempty"`
+	Email           string    `json:"email"`
+	AvatarURL       string    `json:"avatar_url,omitempty"`
+	ActorID         string    `json:"actor_id,omitempty"`
+	PublicKey       string    `json:"public_key,omitempty"`
+	HideSocialGraph bool      `json:"hide_social_graph"`
+	Locked          bool      `json:"locked"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
 }
 
 func (uh *UserHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
@@ -87,10 +95,13 @@ func (uh *UserHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// keep current data, remote servers are told when fields of actor document change
+	before := *currentUser
+
 	// add updated data
+	// actor ID doesn't change with username, previous username keeps resolving to user
 	if updatedUser.Username != nil && *updatedUser.Username != "" {
 		currentUser.Username = *updatedUser.Username
-		currentUser.ActorID = uh.actorService.GenerateActorID(uh.serverHost, *updatedUser.Username)
 	}
 	if updatedUser.DisplayName != nil && *updatedUser.DisplayName != "" {
 		currentUser.DisplayName = *updatedUser.DisplayName
@@ -107,27 +118,54 @@ func (uh *UserHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
 	if updatedUser.PrivateKey != nil && *updatedUser.PrivateKey != "" {
 		currentUser.PrivateKey = *updatedUser.PrivateKey
 	}
+	if updatedUser.HideSocialGraph != nil {
+		currentUser.HideSocialGraph = *updatedUser.HideSocialGraph
+	}
+	if updatedUser.Locked != nil {
+		currentUser.Locked = *updatedUser.Locked
+	}
 
 	// update user
 	err = uh.userService.UpdateUser(r.Context(), currentUser)
+	if errors.Is(err, services.ErrUsernameTaken) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if errors.Is(err, services.ErrInvalidUsername) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	// send new actor document to followers
+	if currentUser.Username != before.Username || currentUser.DisplayName != before.DisplayName ||
+		currentUser.AvatarURL != before.AvatarURL || currentUser.PublicKey != before.PublicKey ||
+		currentUser.Locked != before.Locked {
+		err = uh.apServerService.PublishActorUpdate(r.Context(), currentUser)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("user is updated but fail to publish it: %v", err), http.StatusInternalServerError)
+			return
+		}
+	}
+
 	// response
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(UpdateUserResponse{
-		ID:          currentUser.ID,
-		Username:    currentUser.Username,
-		DisplayName: currentUser.DisplayName,
-		Email:       currentUser.Email,
-		AvatarURL:   currentUser.AvatarURL,
-		ActorID:     currentUser.ActorID,
-		PublicKey:   currentUser.PublicKey,
-		CreatedAt:   currentUser.CreatedAt,
-		UpdatedAt:   currentUser.UpdatedAt,
+		ID:              currentUser.ID,
+		Username:        currentUser.Username,
+		DisplayName:     currentUser.DisplayName,
+		Email:           currentUser.Email,
+		AvatarURL:       currentUser.AvatarURL,
+		ActorID:         currentUser.ActorID,
+		PublicKey:       currentUser.PublicKey,
+		HideSocialGraph: currentUser.HideSocialGraph,
+		Locked:          currentUser.Locked,
+		CreatedAt:       currentUser.CreatedAt,
+		UpdatedAt:       currentUser.UpdatedAt,
 	})
 }
 
@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"je-suis-ici-activitypub/internal/activitypub"
+	"je-suis-ici-activitypub/internal/services"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+)
+
+// WebFingerHandler handle WebFinger discovery requests
+type WebFingerHandler struct {
+	userService services.UserService
+	serverHost  string
+	baseURL     string
+}
+
+// NewWebFingerHandler
+func NewWebFingerHandler(userService services.UserService, serverHost, baseURL string) *WebFingerHandler {
+	return &WebFingerHandler{
+		userService: userService,
+		serverHost:  serverHost,
+		baseURL:     baseURL,
+	}
+}
+
+// RegisterWebFingerRoutes register WebFinger routes under "/.well-known"
+func (wh *WebFingerHandler) RegisterWebFingerRoutes(r chi.Router) {
+	r.Get("/webfinger", wh.GetWebFinger)
+}
+
+// GetWebFinger resolve "acct:username@host" or actor URL resource to a JRD document
+// https://www.rfc-editor.org/rfc/rfc7033
+func (wh *WebFingerHandler) GetWebFinger(w http.ResponseWriter, r *http.Request) {
+	resource := r.URL.Query().Get("resource")
+	if resource == "" {
+		http.Error(w, "resource is required", http.StatusBadRequest)
+		return
+	}
+
+	// get username and host from resource
+	var username, host string
+	if strings.HasPrefix(resource, "http://") || strings.HasPrefix(resource, "https://") {
+		resourceURL, err := url.Parse(resource)
+		if err != nil {
+			http.Error(w, "invalid resource", http.StatusBadRequest)
+			return
+		}
+
+		// actor URL is like https://host/users/{user ID}, or https://host/users/{username} for older actors
+		escapedUsername, found := strings.CutPrefix(resourceURL.EscapedPath(), "/users/")
+		if !found || escapedUsername == "" || strings.Contains(escapedUsername, "/") {
+			http.Error(w, "resource not found", http.StatusNotFound)
+			return
+		}
+
+		username, err = url.PathUnescape(escapedUsername)
+		if err != nil {
+			http.Error(w, "invalid resource", http.StatusBadRequest)
+			return
+		}
+		host = resourceURL.Host
+	} else {
+		var err error
+		username, host, err = activitypub.ParseAcctResource(resource)
+		if err != nil {
+			http.Error(w, "invalid resource", http.StatusBadRequest)
+			return
+		}
+	}
+
+	// only answer for accounts on this server
+	if !strings.EqualFold(host, wh.serverHost) && !strings.EqualFold(host, r.Host) {
+		http.Error(w, "resource not found", http.StatusNotFound)
+		return
+	}
+
+	// get user by ID, username or previous username
+	// subject is always the current username, so clients looking up a renamed account find the new one
+	user, err := wh.userService.GetUserByHandle(r.Context(), username)
+	if errors.Is(err, pgx.ErrNoRows) {
+		http.Error(w, "resource not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	links := []activitypub.WebFingerLink{
+		{
+			Rel:  activitypub.WebFingerRelSelf,
+			Type: activitypub.ContentTypeActivityJSON,
+			Href: user.ActorID,
+		},
+		{
+			Rel:  activitypub.WebFingerRelProfilePage,
+			Type: "text/html",
+			Href: activitypub.ProfileURL(wh.baseURL, user.Username),
+		},
+	}
+
+	// "rel" parameter can be repeated to filter returned links
+	// https://www.rfc-editor.org/rfc/rfc7033#section-4.3
+	rels := r.URL.Query()["rel"]
+	if len(rels) > 0 {
+		filteredLinks := []activitypub.WebFingerLink{}
+		for _, link := range links {
+			for _, rel := range rels {
+				if link.Rel == rel {
+					filteredLinks = append(filteredLinks, link)
+					break
+				}
+			}
+		}
+		links = filteredLinks
+	}
+
+	// response
+	w.Header().Set("Content-Type", activitypub.ContentTypeJRDJSON)
+	json.NewEncoder(w).Encode(activitypub.WebFinger{
+		Subject: fmt.Sprintf("acct:%s@%s", user.Username, wh.serverHost),
+		Aliases: []string{user.ActorID},
+		Links:   links,
+	})
+}
@@ -0,0 +1,23 @@
+package middlewares
+
+import (
+	"je-suis-ici-activitypub/internal/activitypub"
+	"net/http"
+	"strings"
+)
+
+// RequireActivityContentType reject requests whose body isn't ActivityStreams JSON
+// it runs before signature verification, so a wrong media type doesn't cost fetching the signing key
+func RequireActivityContentType(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mediaType, _, _ := strings.Cut(r.Header.Get("Content-Type"), ";")
+		mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+
+		if mediaType != activitypub.ContentTypeActivityJSON && mediaType != "application/ld+json" {
+			http.Error(w, "unsupported content type", http.StatusUnsupportedMediaType)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
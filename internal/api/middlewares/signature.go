@@ -0,0 +1,56 @@
+package middlewares
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"je-suis-ici-activitypub/internal/activitypub"
+	"net/http"
+)
+
+// VerifyHTTPSignature verify HTTP Signature of inbound activity
+// and check the signing key belongs to the activity actor
+func VerifyHTTPSignature(verifier *activitypub.SignatureVerifier) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// read body to verify digest, it's restored for next handler
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				var maxBytesErr *http.MaxBytesError
+				if errors.As(err, &maxBytesErr) {
+					http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+					return
+				}
+
+				http.Error(w, "fail to read request body", http.StatusBadRequest)
+				return
+			}
+			r.Body.Close()
+
+			// verify signature
+			signer, err := verifier.VerifyRequest(r.Context(), r, body)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusUnauthorized)
+				return
+			}
+
+			// signer must be the actor of the activity
+			var activity activitypub.Activity
+			err = json.Unmarshal(body, &activity)
+			if err != nil {
+				http.Error(w, "invalid activity", http.StatusBadRequest)
+				return
+			}
+
+			if activity.Actor.IRI() != signer {
+				http.Error(w, fmt.Sprintf("signing key belongs to %s, not activity actor", signer), http.StatusUnauthorized)
+				return
+			}
+
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			next.ServeHTTP(w, r)
+		})
+	}
+}
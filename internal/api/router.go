@@ -19,10 +19,19 @@ func NewRouter(
 	userService services.UserService,
 	checkinService services.CheckinService,
 	mediaService services.MediaService,
+	followService services.FollowService,
+	likeService services.LikeService,
+	shareService services.ShareService,
+	moveService services.MoveService,
+	domainBlockService services.DomainBlockService,
+	blockService services.BlockService,
+	notificationService services.NotificationService,
 	apServerService *activitypub.ActivityPubServerService,
 	actorService activitypub.ActorService,
+	signatureVerifier *activitypub.SignatureVerifier,
 	tokenAuth *jwtauth.JWTAuth,
 	serverHost string,
+	baseURL string,
 ) http.Handler {
 	r := chi.NewRouter()
 
@@ -36,7 +45,7 @@ func NewRouter(
 	// CORS setup
 	r.Use(cors.Handler(cors.Options{
 		AllowedOrigins:   []string{"https://*", "http://*"},
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
 		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
 		ExposedHeaders:   []string{"Link"}, // allow browser read in response head
 		AllowCredentials: true,
@@ -44,10 +53,21 @@ func NewRouter(
 	}))
 
 	// handlers
-	authHandler := handlers.NewAuthHandler(userService, tokenAuth, serverHost)
-	userHandler := handlers.NewUserHandler(userService, actorService, *authHandler, serverHost)
-	checkinHandler := handlers.NewCheckinHandler(userService, checkinService, mediaService, apServerService, *authHandler, serverHost)
-	feedHandler := handlers.NewFeedHandler(checkinService)
+	authHandler := handlers.NewAuthHandler(userService, tokenAuth, baseURL)
+	userHandler := handlers.NewUserHandler(userService, apServerService, *authHandler, serverHost)
+	checkinHandler := handlers.NewCheckinHandler(userService, checkinService, mediaService, apServerService, *authHandler, baseURL)
+	feedHandler := handlers.NewFeedHandler(checkinService, *authHandler)
+	followHandler := handlers.NewFollowHandler(followService, *authHandler)
+	likeHandler := handlers.NewLikeHandler(likeService, *authHandler)
+	shareHandler := handlers.NewShareHandler(shareService, *authHandler)
+	moveHandler := handlers.NewMoveHandler(moveService, *authHandler)
+	followRequestHandler := handlers.NewFollowRequestHandler(apServerService, *authHandler)
+	domainBlockHandler := handlers.NewDomainBlockHandler(domainBlockService, *authHandler)
+	blockHandler := handlers.NewBlockHandler(blockService, *authHandler)
+	notificationHandler := handlers.NewNotificationHandler(notificationService, *authHandler)
+	webFingerHandler := handlers.NewWebFingerHandler(userService, serverHost, baseURL)
+	nodeInfoHandler := handlers.NewNodeInfoHandler(userService, checkinService, baseURL)
+	activityPubHandler := handlers.NewActivityPubHandler(userService, checkinService, actorService, apServerService, signatureVerifier, serverHost, baseURL, logger)
 
 	// public routes (no need JWT token)
 	r.Group(func(r chi.Router) {
@@ -58,15 +78,24 @@ func NewRouter(
 
 		// ActivityPub routes
 		r.Route("/.well-known", func(r chi.Router) {
-			r.Get("/webfinger", nil) // implement WebFinger for ActivityPub
-			r.Get("/nodeinfo", nil)  // implement NodeInfo for ActivityPub
+			webFingerHandler.RegisterWebFingerRoutes(r)
+			nodeInfoHandler.RegisterNodeInfoDiscoveryRoutes(r)
 		})
+
+		// NodeInfo document
+		nodeInfoHandler.RegisterNodeInfoRoutes(r)
+
+		// ActivityPub actor routes
+		activityPubHandler.RegisterActivityPubRoutes(r)
 	})
 
 	// routes with "/api" prefix
 	r.Route("/api", func(r chi.Router) {
 		// public routes (no need JWT token)
 		r.Group(func(r chi.Router) {
+			// token is optional, global and hashtag feeds hide actors a signed in user blocked or muted
+			r.Use(jwtauth.Verifier(tokenAuth))
+
 			feedHandler.RegisterFeedRouters(r)
 		})
 
@@ -76,6 +105,15 @@ func NewRouter(
 			r.Use(middlewares.AuthJWT(tokenAuth))
 
 			checkinHandler.RegisterCheckinRoutes(r)
+			followHandler.RegisterFollowRoutes(r)
+			likeHandler.RegisterLikeRoutes(r)
+			shareHandler.RegisterShareRoutes(r)
+			moveHandler.RegisterMoveRoutes(r)
+			followRequestHandler.RegisterFollowRequestRoutes(r)
+			domainBlockHandler.RegisterDomainBlockRoutes(r)
+			blockHandler.RegisterBlockRoutes(r)
+			notificationHandler.RegisterNotificationRoutes(r)
+			feedHandler.RegisterHomeFeedRouters(r)
 
 			r.Put("/users/{id}", userHandler.UpdateUser)
 			r.Delete("/users/{id}", userHandler.DeleteUser)
@@ -2,6 +2,7 @@ package config
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/spf13/viper"
 )
@@ -12,11 +13,16 @@ type Config struct {
 	MinioConfig MinioConfig
 	JWT         JWTConfig
 	Jaeger      JaegerConfig `mapstructure:"jaeger"`
+	Delivery    DeliveryConfig
+	Inbox       InboxConfig
+	ActorCache  ActorCacheConfig
 }
 
 type ServerConfig struct {
 	Host string
 	Port int
+	// Scheme scheme of IDs and URLs this server publishes
+	Scheme string
 }
 
 type DatabaseConfig struct {
@@ -47,6 +53,31 @@ type JaegerConfig struct {
 	Enable      bool   `mapstructure:"enable"`
 }
 
+// DeliveryConfig outbound ActivityPub delivery queue
+type DeliveryConfig struct {
+	Workers      int
+	PollInterval time.Duration
+	BaseBackoff  time.Duration
+	MaxBackoff   time.Duration
+	RetryHorizon time.Duration
+}
+
+// InboxConfig inbound ActivityPub activity processing
+type InboxConfig struct {
+	Workers      int
+	PollInterval time.Duration
+	BaseBackoff  time.Duration
+	MaxBackoff   time.Duration
+	MaxAttempts  int
+}
+
+// ActorCacheConfig remote actor cache
+type ActorCacheConfig struct {
+	TTL              time.Duration
+	RefreshInterval  time.Duration
+	RefreshBatchSize int
+}
+
 // LoadConfig get variables from .env and load
 func LoadConfig() (*Config, error) {
 	// use default values as setup
@@ -56,7 +87,7 @@ func LoadConfig() (*Config, error) {
 	viper.SetConfigFile(".env")
 	err := viper.ReadInConfig()
 	if err != nil {
-		fmt.Printf("cannot read .env file: %w", err)
+		fmt.Printf("cannot read .env file: %v\n", err)
 	}
 
 	// check and use env variables
@@ -64,8 +95,9 @@ func LoadConfig() (*Config, error) {
 
 	return &Config{
 		Server: ServerConfig{
-			Host: viper.GetString("SERVER_HOST"),
-			Port: viper.GetInt("SERVER_PORT"),
+			Host:   viper.GetString("SERVER_HOST"),
+			Port:   viper.GetInt("SERVER_PORT"),
+			Scheme: viper.GetString("SERVER_SCHEME"),
 		},
 		Database: DatabaseConfig{
 			Host:     viper.GetString("DB_HOST"),
@@ -91,6 +123,25 @@ func LoadConfig() (*Config, error) {
 			Environment: viper.GetString("JAEGER_ENVIRONMENT"),
 			Enable:      viper.GetBool("JAEGER_ENABLE"),
 		},
+		Delivery: DeliveryConfig{
+			Workers:      viper.GetInt("DELIVERY_WORKERS"),
+			PollInterval: viper.GetDuration("DELIVERY_POLL_INTERVAL"),
+			BaseBackoff:  viper.GetDuration("DELIVERY_BASE_BACKOFF"),
+			MaxBackoff:   viper.GetDuration("DELIVERY_MAX_BACKOFF"),
+			RetryHorizon: viper.GetDuration("DELIVERY_RETRY_HORIZON"),
+		},
+		Inbox: InboxConfig{
+			Workers:      viper.GetInt("INBOX_WORKERS"),
+			PollInterval: viper.GetDuration("INBOX_POLL_INTERVAL"),
+			BaseBackoff:  viper.GetDuration("INBOX_BASE_BACKOFF"),
+			MaxBackoff:   viper.GetDuration("INBOX_MAX_BACKOFF"),
+			MaxAttempts:  viper.GetInt("INBOX_MAX_ATTEMPTS"),
+		},
+		ActorCache: ActorCacheConfig{
+			TTL:              viper.GetDuration("ACTOR_CACHE_TTL"),
+			RefreshInterval:  viper.GetDuration("ACTOR_CACHE_REFRESH_INTERVAL"),
+			RefreshBatchSize: viper.GetInt("ACTOR_CACHE_REFRESH_BATCH_SIZE"),
+		},
 	}, nil
 }
 
@@ -99,6 +150,7 @@ func setDefaults() {
 	// server default setup
 	viper.SetDefault("SERVER_HOST", "localhost")
 	viper.SetDefault("SERVER_PORT", 8080)
+	viper.SetDefault("SERVER_SCHEME", "https")
 
 	// database default setup
 	viper.SetDefault("DB_HOST", "localhost")
@@ -123,11 +175,35 @@ func setDefaults() {
 	viper.SetDefault("JAEGER_SERVICE_NAME", "checkin-service")
 	viper.SetDefault("JAEGER_ENVIRONMENT", "development")
 	viper.SetDefault("JAEGER_ENABLE", true)
+
+	// ActivityPub delivery queue setup
+	viper.SetDefault("DELIVERY_WORKERS", 4)
+	viper.SetDefault("DELIVERY_POLL_INTERVAL", "1s")
+	viper.SetDefault("DELIVERY_BASE_BACKOFF", "30s")
+	viper.SetDefault("DELIVERY_MAX_BACKOFF", "6h")
+	viper.SetDefault("DELIVERY_RETRY_HORIZON", "72h")
+
+	// ActivityPub inbox processing setup
+	viper.SetDefault("INBOX_WORKERS", 4)
+	viper.SetDefault("INBOX_POLL_INTERVAL", "1s")
+	viper.SetDefault("INBOX_BASE_BACKOFF", "10s")
+	viper.SetDefault("INBOX_MAX_BACKOFF", "1h")
+	viper.SetDefault("INBOX_MAX_ATTEMPTS", 8)
+
+	// remote actor cache setup
+	viper.SetDefault("ACTOR_CACHE_TTL", "24h")
+	viper.SetDefault("ACTOR_CACHE_REFRESH_INTERVAL", "1m")
+	viper.SetDefault("ACTOR_CACHE_REFRESH_BATCH_SIZE", 50)
+}
+
+// GetServerBaseURL get scheme and host of IDs and URLs this server publishes, e.g. "https://ici.example"
+func (c *Config) GetServerBaseURL() string {
+	return fmt.Sprintf("%s://%s", c.Server.Scheme, c.Server.Host)
 }
 
 // GetServerAddress get server host address
 func (c *Config) GetServerAddress() string {
-	serverAddress := fmt.Sprintf("%s:%d", c.Server.Host, c.Server.Host)
+	serverAddress := fmt.Sprintf("%s:%d", c.Server.Host, c.Server.Port)
 	return serverAddress
 }
 
@@ -0,0 +1,6 @@
+-- drop index
+DROP INDEX IF EXISTS idx_following_target_actor_id;
+DROP INDEX IF EXISTS idx_following_user_id;
+
+-- drop following table
+DROP TABLE IF EXISTS following;
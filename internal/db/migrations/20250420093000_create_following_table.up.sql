@@ -0,0 +1,15 @@
+-- create following table, remote actors followed by local users
+CREATE TABLE IF NOT EXISTS following (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    target_actor_id VARCHAR(255) NOT NULL,
+    target_inbox VARCHAR(255) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE(user_id, target_actor_id)
+);
+
+-- create index for following table
+CREATE INDEX IF NOT EXISTS idx_following_user_id ON following(user_id);
+CREATE INDEX IF NOT EXISTS idx_following_target_actor_id ON following(target_actor_id);
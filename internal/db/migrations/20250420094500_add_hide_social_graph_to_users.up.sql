@@ -0,0 +1 @@
+ALTER TABLE IF EXISTS users ADD COLUMN IF NOT EXISTS hide_social_graph BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_following_activity_id;
+
+ALTER TABLE IF EXISTS following DROP COLUMN IF EXISTS activity_id;
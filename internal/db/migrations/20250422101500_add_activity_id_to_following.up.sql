@@ -0,0 +1,4 @@
+-- id of the Follow activity sent to remote actor, Accept and Reject refer to it
+ALTER TABLE IF EXISTS following ADD COLUMN IF NOT EXISTS activity_id VARCHAR(255);
+
+CREATE INDEX IF NOT EXISTS idx_following_activity_id ON following(activity_id);
@@ -0,0 +1,6 @@
+-- drop index
+DROP INDEX IF EXISTS idx_deliveries_activity_id;
+DROP INDEX IF EXISTS idx_deliveries_status_next_attempt_at;
+
+-- drop deliveries table
+DROP TABLE IF EXISTS deliveries;
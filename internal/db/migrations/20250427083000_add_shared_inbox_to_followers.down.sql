@@ -0,0 +1 @@
+ALTER TABLE IF EXISTS followers DROP COLUMN IF EXISTS follower_shared_inbox;
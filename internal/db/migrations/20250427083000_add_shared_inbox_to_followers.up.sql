@@ -0,0 +1,2 @@
+-- shared inbox of follower's server, one delivery is enough for all followers on the same server
+ALTER TABLE IF EXISTS followers ADD COLUMN IF NOT EXISTS follower_shared_inbox VARCHAR(255);
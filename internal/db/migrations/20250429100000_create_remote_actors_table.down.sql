@@ -0,0 +1,5 @@
+-- drop index
+DROP INDEX IF EXISTS idx_remote_actors_domain;
+
+-- drop remote_actors table
+DROP TABLE IF EXISTS remote_actors;
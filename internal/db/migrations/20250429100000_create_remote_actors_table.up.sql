@@ -0,0 +1,17 @@
+-- create remote_actors table, actors of other servers whose content is stored here
+CREATE TABLE IF NOT EXISTS remote_actors (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    actor_id VARCHAR(255) NOT NULL UNIQUE,
+    username VARCHAR(255) NOT NULL,
+    domain VARCHAR(255) NOT NULL,
+    display_name VARCHAR(255),
+    avatar_url VARCHAR(1000),
+    inbox VARCHAR(255) NOT NULL,
+    shared_inbox VARCHAR(255),
+    url VARCHAR(1000),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- create index for remote_actors table
+CREATE INDEX IF NOT EXISTS idx_remote_actors_domain ON remote_actors(domain);
@@ -0,0 +1,12 @@
+ALTER TABLE IF EXISTS media DROP COLUMN IF EXISTS remote_url;
+
+DROP INDEX IF EXISTS idx_checkins_created_at;
+DROP INDEX IF EXISTS idx_checkins_remote_actor_id;
+
+DELETE FROM checkins WHERE is_remote = TRUE;
+
+ALTER TABLE IF EXISTS checkins DROP COLUMN IF EXISTS url;
+ALTER TABLE IF EXISTS checkins DROP COLUMN IF EXISTS object_id;
+ALTER TABLE IF EXISTS checkins DROP COLUMN IF EXISTS remote_actor_id;
+ALTER TABLE IF EXISTS checkins DROP COLUMN IF EXISTS is_remote;
+ALTER TABLE IF EXISTS checkins ALTER COLUMN user_id SET NOT NULL;
@@ -0,0 +1,12 @@
+-- remote checkins are Notes received from followed actors, they don't belong to a local user
+ALTER TABLE IF EXISTS checkins ALTER COLUMN user_id DROP NOT NULL;
+ALTER TABLE IF EXISTS checkins ADD COLUMN IF NOT EXISTS is_remote BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE IF EXISTS checkins ADD COLUMN IF NOT EXISTS remote_actor_id UUID REFERENCES remote_actors(id) ON DELETE CASCADE;
+ALTER TABLE IF EXISTS checkins ADD COLUMN IF NOT EXISTS object_id VARCHAR(255) UNIQUE;
+ALTER TABLE IF EXISTS checkins ADD COLUMN IF NOT EXISTS url VARCHAR(1000);
+
+CREATE INDEX IF NOT EXISTS idx_checkins_remote_actor_id ON checkins(remote_actor_id);
+CREATE INDEX IF NOT EXISTS idx_checkins_created_at ON checkins(created_at);
+
+-- media of remote checkins stays on the remote server
+ALTER TABLE IF EXISTS media ADD COLUMN IF NOT EXISTS remote_url VARCHAR(1000);
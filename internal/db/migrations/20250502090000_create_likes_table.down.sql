@@ -0,0 +1,5 @@
+-- drop index
+DROP INDEX IF EXISTS idx_likes_user_id;
+
+-- drop likes table
+DROP TABLE IF EXISTS likes;
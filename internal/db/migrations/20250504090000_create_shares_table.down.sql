@@ -0,0 +1,6 @@
+-- drop index
+DROP INDEX IF EXISTS idx_shares_actor_id;
+DROP INDEX IF EXISTS idx_shares_user_id;
+
+-- drop shares table
+DROP TABLE IF EXISTS shares;
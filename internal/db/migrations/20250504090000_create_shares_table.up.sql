@@ -0,0 +1,15 @@
+-- create shares table, boosts (Announce) of local and remote actors on checkins
+CREATE TABLE IF NOT EXISTS shares (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    checkin_id UUID NOT NULL REFERENCES checkins(id) ON DELETE CASCADE,
+    user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+    actor_id VARCHAR(255) NOT NULL,
+    object_id VARCHAR(255) NOT NULL,
+    activity_id VARCHAR(255) NOT NULL UNIQUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE(checkin_id, actor_id)
+);
+
+-- create index for shares table
+CREATE INDEX IF NOT EXISTS idx_shares_user_id ON shares(user_id);
+CREATE INDEX IF NOT EXISTS idx_shares_actor_id ON shares(actor_id);
@@ -0,0 +1,2 @@
+-- drop tombstones table
+DROP TABLE IF EXISTS tombstones;
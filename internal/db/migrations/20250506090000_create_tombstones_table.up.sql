@@ -0,0 +1,7 @@
+-- create tombstones table, object IDs of deleted local checkins
+CREATE TABLE IF NOT EXISTS tombstones (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    object_id VARCHAR(255) NOT NULL UNIQUE,
+    former_type VARCHAR(50) NOT NULL,
+    deleted_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
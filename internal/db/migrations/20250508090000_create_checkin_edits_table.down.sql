@@ -0,0 +1,5 @@
+-- drop index
+DROP INDEX IF EXISTS idx_checkin_edits_checkin_id;
+
+-- drop checkin_edits table
+DROP TABLE IF EXISTS checkin_edits;
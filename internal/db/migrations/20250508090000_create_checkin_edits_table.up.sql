@@ -0,0 +1,13 @@
+-- create checkin_edits table, previous versions of edited checkins
+CREATE TABLE IF NOT EXISTS checkin_edits (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    checkin_id UUID NOT NULL REFERENCES checkins(id) ON DELETE CASCADE,
+    content TEXT NOT NULL,
+    location_name VARCHAR(255) NOT NULL,
+    latitude DECIMAL(10, 8) NOT NULL,
+    longitude DECIMAL(11, 8) NOT NULL,
+    edited_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- create index for checkin_edits table
+CREATE INDEX IF NOT EXISTS idx_checkin_edits_checkin_id ON checkin_edits(checkin_id);
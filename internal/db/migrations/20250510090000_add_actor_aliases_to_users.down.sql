@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_username_history_user_id;
+DROP TABLE IF EXISTS username_history;
+
+ALTER TABLE IF EXISTS users DROP COLUMN IF EXISTS moved_to;
+ALTER TABLE IF EXISTS users DROP COLUMN IF EXISTS also_known_as;
@@ -0,0 +1,13 @@
+-- actors moving between accounts list their other accounts in alsoKnownAs and point to the new one with movedTo
+ALTER TABLE IF EXISTS users ADD COLUMN IF NOT EXISTS also_known_as TEXT[] NOT NULL DEFAULT ARRAY[]::TEXT[];
+ALTER TABLE IF EXISTS users ADD COLUMN IF NOT EXISTS moved_to VARCHAR(255);
+
+-- create username_history table, previous usernames keep resolving to their user and can't be taken by others
+CREATE TABLE IF NOT EXISTS username_history (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    username VARCHAR(50) NOT NULL UNIQUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_username_history_user_id ON username_history(user_id);
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_remote_actors_fetched_at;
+
+ALTER TABLE IF EXISTS remote_actors DROP COLUMN IF EXISTS fetch_attempted_at;
+ALTER TABLE IF EXISTS remote_actors DROP COLUMN IF EXISTS fetched_at;
+ALTER TABLE IF EXISTS remote_actors DROP COLUMN IF EXISTS public_key_pem;
+ALTER TABLE IF EXISTS remote_actors DROP COLUMN IF EXISTS public_key_id;
+ALTER TABLE IF EXISTS remote_actors DROP COLUMN IF EXISTS document;
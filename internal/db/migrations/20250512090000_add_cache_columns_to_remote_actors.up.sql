@@ -0,0 +1,8 @@
+-- remote_actors caches actor documents, they're fetched again when older than the cache TTL
+ALTER TABLE IF EXISTS remote_actors ADD COLUMN IF NOT EXISTS document JSONB;
+ALTER TABLE IF EXISTS remote_actors ADD COLUMN IF NOT EXISTS public_key_id VARCHAR(255);
+ALTER TABLE IF EXISTS remote_actors ADD COLUMN IF NOT EXISTS public_key_pem TEXT;
+ALTER TABLE IF EXISTS remote_actors ADD COLUMN IF NOT EXISTS fetched_at TIMESTAMPTZ NOT NULL DEFAULT now();
+ALTER TABLE IF EXISTS remote_actors ADD COLUMN IF NOT EXISTS fetch_attempted_at TIMESTAMPTZ NOT NULL DEFAULT now();
+
+CREATE INDEX IF NOT EXISTS idx_remote_actors_fetched_at ON remote_actors(fetched_at);
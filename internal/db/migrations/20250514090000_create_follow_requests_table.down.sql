@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_follow_requests_user_id;
+DROP TABLE IF EXISTS follow_requests;
+
+ALTER TABLE IF EXISTS users DROP COLUMN IF EXISTS locked;
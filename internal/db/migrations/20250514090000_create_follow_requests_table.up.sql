@@ -0,0 +1,17 @@
+-- locked users approve their followers
+ALTER TABLE IF EXISTS users ADD COLUMN IF NOT EXISTS locked BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- create follow_requests table, Follows of locked users waiting for approval
+CREATE TABLE IF NOT EXISTS follow_requests (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    actor_id VARCHAR(255) NOT NULL,
+    inbox VARCHAR(255) NOT NULL,
+    shared_inbox VARCHAR(255),
+    activity_id VARCHAR(255) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE(user_id, actor_id)
+);
+
+-- create index for follow_requests table
+CREATE INDEX IF NOT EXISTS idx_follow_requests_user_id ON follow_requests(user_id);
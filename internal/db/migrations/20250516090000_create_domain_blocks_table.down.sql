@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS domain_blocks;
+
+ALTER TABLE IF EXISTS users DROP COLUMN IF EXISTS is_admin;
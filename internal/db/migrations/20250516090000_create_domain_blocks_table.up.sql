@@ -0,0 +1,16 @@
+-- admins manage instance moderation, e.g. domain blocks
+ALTER TABLE IF EXISTS users ADD COLUMN IF NOT EXISTS is_admin BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- create domain_blocks table, a block applies to the domain and all its subdomains
+-- severity is "suspend" (no federation at all) or "silence" (kept out of public timelines)
+CREATE TABLE IF NOT EXISTS domain_blocks (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    domain VARCHAR(255) NOT NULL UNIQUE,
+    severity VARCHAR(20) NOT NULL,
+    reject_media BOOLEAN NOT NULL DEFAULT FALSE,
+    reject_reports BOOLEAN NOT NULL DEFAULT FALSE,
+    public_comment TEXT,
+    obfuscate BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS idx_activities_unprocessed;
+
+ALTER TABLE IF EXISTS activities DROP COLUMN IF EXISTS processed_at;
+ALTER TABLE IF EXISTS activities DROP COLUMN IF EXISTS last_error;
+ALTER TABLE IF EXISTS activities DROP COLUMN IF EXISTS locked_until;
+ALTER TABLE IF EXISTS activities DROP COLUMN IF EXISTS next_attempt_at;
+ALTER TABLE IF EXISTS activities DROP COLUMN IF EXISTS attempts;
+ALTER TABLE IF EXISTS activities DROP COLUMN IF EXISTS recipient_id;
@@ -0,0 +1,14 @@
+-- inbound activities are processed by a background worker, failed ones are retried until next_attempt_at
+-- recipient_id is the user whose inbox received the activity, NULL for the shared inbox
+ALTER TABLE IF EXISTS activities ADD COLUMN IF NOT EXISTS recipient_id UUID REFERENCES users(id) ON DELETE CASCADE;
+ALTER TABLE IF EXISTS activities ADD COLUMN IF NOT EXISTS attempts INT NOT NULL DEFAULT 0;
+ALTER TABLE IF EXISTS activities ADD COLUMN IF NOT EXISTS next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT now();
+ALTER TABLE IF EXISTS activities ADD COLUMN IF NOT EXISTS locked_until TIMESTAMPTZ;
+ALTER TABLE IF EXISTS activities ADD COLUMN IF NOT EXISTS last_error TEXT;
+ALTER TABLE IF EXISTS activities ADD COLUMN IF NOT EXISTS processed_at TIMESTAMPTZ;
+
+-- activities stored before were handled when they were received
+UPDATE activities SET processed = TRUE, processed_at = created_at WHERE processed = FALSE;
+
+-- create index for claiming due activities
+CREATE INDEX IF NOT EXISTS idx_activities_unprocessed ON activities(next_attempt_at) WHERE processed = FALSE;
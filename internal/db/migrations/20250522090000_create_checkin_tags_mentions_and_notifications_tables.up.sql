@@ -0,0 +1,34 @@
+-- create checkin_tags table, hashtags of a checkin are stored lowercased without "#"
+CREATE TABLE IF NOT EXISTS checkin_tags (
+    checkin_id UUID NOT NULL REFERENCES checkins(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    PRIMARY KEY (checkin_id, name)
+);
+
+-- create index for hashtag timelines
+CREATE INDEX IF NOT EXISTS idx_checkin_tags_name ON checkin_tags(name);
+
+-- create checkin_mentions table, acct is "user@host" of the mentioned actor
+CREATE TABLE IF NOT EXISTS checkin_mentions (
+    checkin_id UUID NOT NULL REFERENCES checkins(id) ON DELETE CASCADE,
+    actor_id VARCHAR(255) NOT NULL,
+    acct VARCHAR(255) NOT NULL,
+    url VARCHAR(255),
+    PRIMARY KEY (checkin_id, actor_id)
+);
+
+-- create notifications table, checkin_id is NULL when the Note isn't stored as a checkin, e.g. a reply
+CREATE TABLE IF NOT EXISTS notifications (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    type VARCHAR(20) NOT NULL,
+    actor_id VARCHAR(255) NOT NULL,
+    object_id VARCHAR(255) NOT NULL,
+    checkin_id UUID REFERENCES checkins(id) ON DELETE CASCADE,
+    read BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE(user_id, type, object_id)
+);
+
+-- create index for listing user's notifications
+CREATE INDEX IF NOT EXISTS idx_notifications_user_id ON notifications(user_id, created_at DESC);
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_remote_actors_public_key_id;
@@ -0,0 +1,2 @@
+-- signing keys are looked up by their id, which isn't always under the actor id, e.g. GoToSocial's ".../main-key"
+CREATE INDEX IF NOT EXISTS idx_remote_actors_public_key_id ON remote_actors(public_key_id);
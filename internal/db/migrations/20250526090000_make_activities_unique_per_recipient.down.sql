@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS idx_activities_activity_id_shared;
+DROP INDEX IF EXISTS idx_activities_activity_id_recipient_id;
+
+-- keep the first stored row of each activity
+DELETE FROM activities a USING activities b
+WHERE a.activity_id = b.activity_id AND (a.created_at, a.id) > (b.created_at, b.id);
+
+CREATE UNIQUE INDEX IF NOT EXISTS activities_activity_id_key ON activities(activity_id);
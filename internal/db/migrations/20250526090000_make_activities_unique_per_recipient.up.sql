@@ -0,0 +1,6 @@
+-- the same activity can be delivered to the shared inbox and to personal inboxes, each recipient keeps its own row
+DROP INDEX IF EXISTS activities@activities_activity_id_key CASCADE;
+
+-- recipient_id is NULL for the shared inbox, which gets one row per activity
+CREATE UNIQUE INDEX IF NOT EXISTS idx_activities_activity_id_recipient_id ON activities(activity_id, recipient_id) WHERE recipient_id IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_activities_activity_id_shared ON activities(activity_id) WHERE recipient_id IS NULL;
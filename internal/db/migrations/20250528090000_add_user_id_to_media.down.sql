@@ -0,0 +1 @@
+ALTER TABLE IF EXISTS media DROP COLUMN IF EXISTS user_id;
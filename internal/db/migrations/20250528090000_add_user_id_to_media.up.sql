@@ -0,0 +1,2 @@
+-- uploader of local media, only they can attach it to a checkin
+ALTER TABLE IF EXISTS media ADD COLUMN IF NOT EXISTS user_id UUID REFERENCES users(id) ON DELETE CASCADE;
@@ -0,0 +1,237 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Block actor blocked by a local user, it can't follow the user and its content is hidden from the user
+type Block struct {
+	ID            uuid.UUID `json:"id"`
+	UserID        uuid.UUID `json:"user_id"`
+	TargetActorID string    `json:"target_actor_id"`
+	// ActivityID id of the Block activity sent to the actor, Undo refers to it
+	ActivityID string    `json:"activity_id"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// Mute actor muted by a local user, its content is hidden from the user without telling the actor
+type Mute struct {
+	ID            uuid.UUID `json:"id"`
+	UserID        uuid.UUID `json:"user_id"`
+	TargetActorID string    `json:"target_actor_id"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// BlockRepository manipulate block and mute data
+type BlockRepository interface {
+	AddBlock(ctx context.Context, block *Block) error
+	RemoveBlock(ctx context.Context, userID uuid.UUID, targetActorID string) error
+	GetBlock(ctx context.Context, userID uuid.UUID, targetActorID string) (*Block, error)
+	IsBlocked(ctx context.Context, userID uuid.UUID, actorID string) (bool, error)
+	GetBlocks(ctx context.Context, userID uuid.UUID, cursor uuid.UUID, limit int) ([]Block, error)
+	AddMute(ctx context.Context, mute *Mute) error
+	RemoveMute(ctx context.Context, userID uuid.UUID, targetActorID string) error
+	GetMutes(ctx context.Context, userID uuid.UUID, cursor uuid.UUID, limit int) ([]Mute, error)
+}
+
+// BlockRepositoryImplement
+type BlockRepositoryImplement struct {
+	pool *pgxpool.Pool
+}
+
+// NewBlockRepository
+func NewBlockRepository(pool *pgxpool.Pool) BlockRepository {
+	return &BlockRepositoryImplement{pool: pool}
+}
+
+// actorHiddenFromUser condition matching actorColumn when user $1 blocked or muted it
+func actorHiddenFromUser(actorColumn string) string {
+	return `
+		SELECT 1 FROM user_blocks ub WHERE ub.user_id = $1 AND ub.target_actor_id = ` + actorColumn + `
+		UNION ALL
+		SELECT 1 FROM user_mutes um WHERE um.user_id = $1 AND um.target_actor_id = ` + actorColumn
+}
+
+// blockColumns columns selected for a Block, in scanBlock order
+const blockColumns = `id, user_id, target_actor_id, activity_id, created_at`
+
+// scanBlock scan a row selected with blockColumns
+func scanBlock(row pgx.Row) (*Block, error) {
+	block := &Block{}
+
+	err := row.Scan(&block.ID, &block.UserID, &block.TargetActorID, &block.ActivityID, &block.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return block, nil
+}
+
+// AddBlock store block, blocking an actor again keeps the first block
+func (br *BlockRepositoryImplement) AddBlock(ctx context.Context, block *Block) error {
+	query := `
+		INSERT INTO user_blocks (user_id, target_actor_id, activity_id)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (user_id, target_actor_id) DO UPDATE SET target_actor_id = excluded.target_actor_id
+		RETURNING id, activity_id, created_at
+	`
+
+	err := br.pool.QueryRow(ctx, query, block.UserID, block.TargetActorID, block.ActivityID).
+		Scan(&block.ID, &block.ActivityID, &block.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("fail to add block: %w", err)
+	}
+
+	return nil
+}
+
+// RemoveBlock
+func (br *BlockRepositoryImplement) RemoveBlock(ctx context.Context, userID uuid.UUID, targetActorID string) error {
+	query := `DELETE FROM user_blocks WHERE user_id = $1 AND target_actor_id = $2`
+
+	_, err := br.pool.Exec(ctx, query, userID, targetActorID)
+	if err != nil {
+		return fmt.Errorf("fail to remove block: %w", err)
+	}
+
+	return nil
+}
+
+// GetBlock
+func (br *BlockRepositoryImplement) GetBlock(ctx context.Context, userID uuid.UUID, targetActorID string) (*Block, error) {
+	query := `SELECT ` + blockColumns + ` FROM user_blocks WHERE user_id = $1 AND target_actor_id = $2`
+
+	block, err := scanBlock(br.pool.QueryRow(ctx, query, userID, targetActorID))
+	if err != nil {
+		return nil, fmt.Errorf("fail to get block: %w", err)
+	}
+
+	return block, nil
+}
+
+// IsBlocked check whether user blocked actor
+func (br *BlockRepositoryImplement) IsBlocked(ctx context.Context, userID uuid.UUID, actorID string) (bool, error) {
+	query := `SELECT EXISTS (SELECT 1 FROM user_blocks WHERE user_id = $1 AND target_actor_id = $2)`
+
+	var exists bool
+	err := br.pool.QueryRow(ctx, query, userID, actorID).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("fail to check block: %w", err)
+	}
+
+	return exists, nil
+}
+
+// GetBlocks get user's blocks after cursor, ordered by id
+func (br *BlockRepositoryImplement) GetBlocks(ctx context.Context, userID uuid.UUID, cursor uuid.UUID, limit int) ([]Block, error) {
+	query := `
+		SELECT ` + blockColumns + `
+		FROM user_blocks
+		WHERE user_id = $1 AND id > $2
+		ORDER BY id
+		LIMIT $3
+	`
+
+	if limit <= 0 {
+		limit = 10
+	}
+
+	rows, err := br.pool.Query(ctx, query, userID, cursor, limit)
+	if err != nil {
+		return nil, fmt.Errorf("fail to get blocks: %w", err)
+	}
+	defer rows.Close()
+
+	var blocks []Block
+
+	for rows.Next() {
+		block, err := scanBlock(rows)
+		if err != nil {
+			return nil, fmt.Errorf("fail to scan block: %w", err)
+		}
+
+		blocks = append(blocks, *block)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("error on iterating block rows: %w", err)
+	}
+
+	return blocks, nil
+}
+
+// AddMute store mute, muting an actor again keeps the first mute
+func (br *BlockRepositoryImplement) AddMute(ctx context.Context, mute *Mute) error {
+	query := `
+		INSERT INTO user_mutes (user_id, target_actor_id)
+		VALUES ($1, $2)
+		ON CONFLICT (user_id, target_actor_id) DO UPDATE SET target_actor_id = excluded.target_actor_id
+		RETURNING id, created_at
+	`
+
+	err := br.pool.QueryRow(ctx, query, mute.UserID, mute.TargetActorID).Scan(&mute.ID, &mute.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("fail to add mute: %w", err)
+	}
+
+	return nil
+}
+
+// RemoveMute
+func (br *BlockRepositoryImplement) RemoveMute(ctx context.Context, userID uuid.UUID, targetActorID string) error {
+	query := `DELETE FROM user_mutes WHERE user_id = $1 AND target_actor_id = $2`
+
+	_, err := br.pool.Exec(ctx, query, userID, targetActorID)
+	if err != nil {
+		return fmt.Errorf("fail to remove mute: %w", err)
+	}
+
+	return nil
+}
+
+// GetMutes get user's mutes after cursor, ordered by id
+func (br *BlockRepositoryImplement) GetMutes(ctx context.Context, userID uuid.UUID, cursor uuid.UUID, limit int) ([]Mute, error) {
+	query := `
+		SELECT id, user_id, target_actor_id, created_at
+		FROM user_mutes
+		WHERE user_id = $1 AND id > $2
+		ORDER BY id
+		LIMIT $3
+	`
+
+	if limit <= 0 {
+		limit = 10
+	}
+
+	rows, err := br.pool.Query(ctx, query, userID, cursor, limit)
+	if err != nil {
+		return nil, fmt.Errorf("fail to get mutes: %w", err)
+	}
+	defer rows.Close()
+
+	var mutes []Mute
+
+	for rows.Next() {
+		var mute Mute
+		err := rows.Scan(&mute.ID, &mute.UserID, &mute.TargetActorID, &mute.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("fail to scan mute: %w", err)
+		}
+
+		mutes = append(mutes, mute)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("error on iterating mute rows: %w", err)
+	}
+
+	return mutes, nil
+}
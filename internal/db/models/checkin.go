@@ -2,8 +2,10 @@ package models
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"time"
 )
@@ -16,19 +18,63 @@ type Checkin struct {
 	Latitude     float64   `json:"latitude"`
 	Longitude    float64   `json:"longitude"`
 	ActivityID   string    `json:"activity_id"`
-	Media        []Media   `json:"media,omitempty"`
-	User         *User     `json:"user,omitempty"`
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
+	// IsRemote checkin is a Note received from a remote actor, UserID is uuid.Nil
+	IsRemote      bool      `json:"is_remote"`
+	RemoteActorID uuid.UUID `json:"-"`
+	ObjectID      string    `json:"object_id,omitempty"`
+	URL           string    `json:"url,omitempty"`
+	LikeCount     int       `json:"like_count"`
+	ShareCount    int       `json:"share_count"`
+	Media         []Media   `json:"media,omitempty"`
+	// Tags hashtags in content, lowercased without "#"
+	Tags     []string  `json:"tags,omitempty"`
+	Mentions []Mention `json:"mentions,omitempty"`
+	User     *User     `json:"user,omitempty"`
+	// BoostedBy actor whose boost put checkin in home feed
+	BoostedBy *User     `json:"boosted_by,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Mention actor mentioned in checkin content
+type Mention struct {
+	ActorID string `json:"actor_id"`
+	// Acct "user@host" of the actor, it's what "@user@host" in content refers to
+	Acct string `json:"acct"`
+	// URL profile page linked from content, empty when actor doesn't have one
+	URL string `json:"url,omitempty"`
+}
+
+// CheckinEdit previous version of an edited checkin
+type CheckinEdit struct {
+	ID           uuid.UUID `json:"id"`
+	CheckinID    uuid.UUID `json:"checkin_id"`
+	Content      string    `json:"content"`
+	LocationName string    `json:"location_name"`
+	Latitude     float64   `json:"latitude"`
+	Longitude    float64   `json:"longitude"`
+	EditedAt     time.Time `json:"edited_at"`
 }
 
 // CheckinRepository methods to manipulate checkin data
 type CheckinRepository interface {
 	CreateCheckin(ctx context.Context, checkin *Checkin) error
+	CreateRemoteCheckin(ctx context.Context, checkin *Checkin) error
 	GetCheckinByID(ctx context.Context, id uuid.UUID) (*Checkin, error)
 	GetCheckinByActivityID(ctx context.Context, activityID string) (*Checkin, error)
+	GetCheckinByObjectID(ctx context.Context, objectID string) (*Checkin, error)
 	GetCheckinsByUserID(ctx context.Context, userID uuid.UUID, limit, offset int) ([]Checkin, error)
-	GetGlobalFeed(ctx context.Context, limit, offest int) ([]Checkin, error)
+	CountCheckinsByUserID(ctx context.Context, userID uuid.UUID) (int, error)
+	GetGlobalFeed(ctx context.Context, viewerID uuid.UUID, limit, offest int) ([]Checkin, error)
+	GetHomeFeed(ctx context.Context, userID uuid.UUID, limit, offset int) ([]Checkin, error)
+	GetCheckinsByTag(ctx context.Context, viewerID uuid.UUID, tag string, limit, offset int) ([]Checkin, error)
+	CountLocalCheckins(ctx context.Context) (int, error)
+	UpdateCheckin(ctx context.Context, checkin *Checkin, mediaIDs []uuid.UUID) ([]Media, error)
+	ReplaceRemoteMedia(ctx context.Context, checkinID uuid.UUID, media []Media) error
+	GetCheckinEdits(ctx context.Context, checkinID uuid.UUID) ([]CheckinEdit, error)
+	DeleteCheckin(ctx context.Context, id uuid.UUID, objectID, formerType string) error
+	DeleteRemoteCheckin(ctx context.Context, objectID, actorID string) error
+	GetTombstone(ctx context.Context, objectID string) (*Tombstone, error)
 }
 
 // CheckinRepositoryImplement implement functions in checkin repository interface
@@ -41,7 +87,61 @@ func NewCheckinRepository(pool *pgxpool.Pool) CheckinRepository {
 	return &CheckinRepositoryImplement{pool: pool}
 }
 
+// checkinColumns columns selected for a Checkin and its author, in scanCheckin order
+// author is a local user or a remote actor, the query must use checkinJoins
+const checkinColumns = `
+	c.id, COALESCE(c.user_id, '00000000-0000-0000-0000-000000000000'::UUID), c.content, c.location_name,
+	c.latitude, c.longitude, c.activity_id, c.is_remote,
+	COALESCE(c.remote_actor_id, '00000000-0000-0000-0000-000000000000'::UUID), COALESCE(c.object_id, ''),
+	COALESCE(c.url, ''), c.created_at, c.updated_at,
+	(SELECT count(*) FROM likes l WHERE l.checkin_id = c.id),
+	(SELECT count(*) FROM shares s WHERE s.checkin_id = c.id),
+	COALESCE(u.username, ra.username || '@' || ra.domain, ''), COALESCE(u.display_name, ra.display_name, ''),
+	COALESCE(u.avatar_url, ra.avatar_url, ''), COALESCE(u.actor_id, ra.actor_id, '')`
+
+// checkinAuthorJoins join author of checkin c
+const checkinAuthorJoins = `
+	LEFT JOIN users u ON c.user_id = u.id
+	LEFT JOIN remote_actors ra ON c.remote_actor_id = ra.id`
+
+// checkinJoins select checkins with their author
+const checkinJoins = `
+	FROM checkins c` + checkinAuthorJoins
+
+// scanCheckin scan a row selected with checkinColumns, extra columns after them are scanned into dest
+func scanCheckin(row pgx.Row, dest ...interface{}) (*Checkin, error) {
+	var checkin Checkin
+	var user User
+
+	columns := []interface{}{
+		&checkin.ID, &checkin.UserID, &checkin.Content, &checkin.LocationName,
+		&checkin.Latitude, &checkin.Longitude, &checkin.ActivityID, &checkin.IsRemote,
+		&checkin.RemoteActorID, &checkin.ObjectID,
+		&checkin.URL, &checkin.CreatedAt, &checkin.UpdatedAt,
+		&checkin.LikeCount, &checkin.ShareCount,
+		&user.Username, &user.DisplayName,
+		&user.AvatarURL, &user.ActorID,
+	}
+
+	err := row.Scan(append(columns, dest...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	user.ID = checkin.UserID
+	checkin.User = &user
+
+	return &checkin, nil
+}
+
+// CreateCheckin store checkin with its hashtags and mentions
 func (cr *CheckinRepositoryImplement) CreateCheckin(ctx context.Context, checkin *Checkin) error {
+	tx, err := cr.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("fail to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
 	query := `
 		INSERT INTO checkins (
 			user_id, content, location_name, latitude, longitude, activity_id
@@ -49,7 +149,7 @@ func (cr *CheckinRepositoryImplement) CreateCheckin(ctx context.Context, checkin
 		RETURNING id, created_at, updated_at
 	`
 
-	err := cr.pool.QueryRow(ctx, query,
+	err = tx.QueryRow(ctx, query,
 		checkin.UserID, checkin.Content, checkin.LocationName,
 		checkin.Latitude, checkin.Longitude, checkin.ActivityID,
 	).Scan(&checkin.ID, &checkin.CreatedAt, &checkin.UpdatedAt)
@@ -58,106 +158,142 @@ func (cr *CheckinRepositoryImplement) CreateCheckin(ctx context.Context, checkin
 		return fmt.Errorf("fail to create checkin: %w", err)
 	}
 
+	err = replaceEntities(ctx, tx, checkin)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return fmt.Errorf("fail to commit checkin: %w", err)
+	}
+
 	return nil
 }
 
-func (cr *CheckinRepositoryImplement) GetCheckinByID(ctx context.Context, id uuid.UUID) (*Checkin, error) {
+// CreateRemoteCheckin store checkin received from a remote actor with its media
+// CreatedAt and UpdatedAt are the Note's published and updated time
+// a Note already stored is ignored and checkin.ID stays uuid.Nil
+func (cr *CheckinRepositoryImplement) CreateRemoteCheckin(ctx context.Context, checkin *Checkin) error {
+	tx, err := cr.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("fail to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
 	query := `
-SELECT
-c.id, c.user_id, c.content, c.location_name, c.latitude, c.longitude, 
-c.activity_id, c.created_at, c.updated_at,
-u.id, u.username, u.display_name, u.avatar_url, u.actor_id
-FROM checkins c
-JOIN users u ON c.user_id = u.id
-WHERE c.id = $1
-`
+		INSERT INTO checkins (
+			content, location_name, latitude, longitude, activity_id,
+			is_remote, remote_actor_id, object_id, url, created_at, updated_at
+		) VALUES ($1, $2, $3, $4, $5, true, $6, $7, NULLIF($8, ''), $9, $10)
+		ON CONFLICT DO NOTHING
+		RETURNING id
+	`
 
-	row := cr.pool.QueryRow(ctx, query, id)
+	err = tx.QueryRow(ctx, query,
+		checkin.Content, checkin.LocationName, checkin.Latitude, checkin.Longitude, checkin.ActivityID,
+		checkin.RemoteActorID, checkin.ObjectID, checkin.URL, checkin.CreatedAt, checkin.UpdatedAt,
+	).Scan(&checkin.ID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("fail to create remote checkin: %w", err)
+	}
 
-	var checkin Checkin
-	var user User
+	mediaQuery := `
+		INSERT INTO media (
+			checkin_id, file_path, file_type, file_size, width, height, remote_url
+		) VALUES ($1, '', $2, 0, $3, $4, $5)
+		RETURNING id, created_at
+	`
 
-	// get checkin data and user data
-	err := row.Scan(
-		&checkin.ID, &checkin.UserID, &checkin.Content, &checkin.LocationName, &checkin.Latitude, &checkin.Longitude,
-		&checkin.ActivityID, &checkin.CreatedAt, &checkin.UpdatedAt,
-		&user.ID, &user.Username, &user.DisplayName, &user.AvatarURL, &user.ActorID,
-	)
+	for i := range checkin.Media {
+		media := &checkin.Media[i]
+		media.CheckinID = checkin.ID
+
+		err := tx.QueryRow(ctx, mediaQuery,
+			checkin.ID, media.FileType, media.Width, media.Height, media.RemoteURL,
+		).Scan(&media.ID, &media.CreatedAt)
+		if err != nil {
+			return fmt.Errorf("fail to create remote media: %w", err)
+		}
+	}
 
+	err = replaceEntities(ctx, tx, checkin)
 	if err != nil {
-		return nil, fmt.Errorf("fail to get checkin by ID: %w", err)
+		return err
 	}
 
-	// get media data
-	mediaQuery := `
-SELECT id, file_path, file_type, file_size, width, height, created_at
-FROM media
-WHERE checkin_id = $1
-`
-	mediaRows, err := cr.pool.Query(ctx, mediaQuery, id)
+	err = tx.Commit(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("fail to query media: %w", err)
+		return fmt.Errorf("fail to commit remote checkin: %w", err)
 	}
-	defer mediaRows.Close()
 
-	// if next row exists, mediaRows.Next() return true and scan media data
-	// if it's at the end, mediaRows.Next() return false and break the loop
-	for mediaRows.Next() {
-		var media Media
-		err := mediaRows.Scan(
-			&media.ID, &media.FilePath, &media.FileType, &media.FileSize,
-			&media.Width, &media.Height, &media.CreatedAt,
-		)
+	return nil
+}
 
-		if err != nil {
-			return nil, fmt.Errorf("fail to scan media: %w", err)
-		}
+func (cr *CheckinRepositoryImplement) GetCheckinByID(ctx context.Context, id uuid.UUID) (*Checkin, error) {
+	query := `SELECT ` + checkinColumns + checkinJoins + `
+	WHERE c.id = $1`
 
-		media.CheckinID = id
-		checkin.Media = append(checkin.Media, media)
+	// get checkin data and user data
+	checkin, err := scanCheckin(cr.pool.QueryRow(ctx, query, id))
+	if err != nil {
+		return nil, fmt.Errorf("fail to get checkin by ID: %w", err)
 	}
 
-	err = mediaRows.Err()
+	// get media data
+	checkin.Media, err = cr.getMedia(ctx, checkin.ID)
 	if err != nil {
-		return nil, fmt.Errorf("error on iterating media rows: %w", err)
+		return nil, err
 	}
 
-	return &checkin, nil
+	// get hashtags and mentions
+	checkin.Tags, checkin.Mentions, err = cr.getEntities(ctx, checkin.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return checkin, nil
 }
 
 func (cr *CheckinRepositoryImplement) GetCheckinByActivityID(ctx context.Context, activityID string) (*Checkin, error) {
 	query := `
-		SELECT c.id, c.user_id, c.content, c.location_name, c.latitude, c.longitude,
-		c.activity_id, c.created_at, c.updated_at,
-FROM checkins c
-WHERE activity_id = $1
-`
+		SELECT c.id FROM checkins c
+		WHERE c.activity_id = $1
+	`
 
-	row := cr.pool.QueryRow(ctx, query, activityID)
+	var id uuid.UUID
+	err := cr.pool.QueryRow(ctx, query, activityID).Scan(&id)
+	if err != nil {
+		return nil, fmt.Errorf("fail to get checkin by activity ID: %w", err)
+	}
 
-	var checkin Checkin
+	// get checkin with its user and media
+	return cr.GetCheckinByID(ctx, id)
+}
 
-	err := row.Scan(
-		&checkin.ID, &checkin.UserID, &checkin.Content, &checkin.LocationName, &checkin.Latitude, &checkin.Longitude,
-		&checkin.ActivityID, &checkin.CreatedAt, &checkin.UpdatedAt,
-	)
+// GetCheckinByObjectID get checkin by ID of its Note, for remote checkins
+func (cr *CheckinRepositoryImplement) GetCheckinByObjectID(ctx context.Context, objectID string) (*Checkin, error) {
+	query := `
+		SELECT c.id FROM checkins c
+		WHERE c.object_id = $1
+	`
 
+	var id uuid.UUID
+	err := cr.pool.QueryRow(ctx, query, objectID).Scan(&id)
 	if err != nil {
-		return nil, fmt.Errorf("fail to get checkin by activity ID: %w", err)
+		return nil, fmt.Errorf("fail to get checkin by object ID: %w", err)
 	}
 
-	return &checkin, nil
+	return cr.GetCheckinByID(ctx, id)
 }
 
 func (cr *CheckinRepositoryImplement) GetCheckinsByUserID(ctx context.Context, userID uuid.UUID, limit, offset int) ([]Checkin, error) {
-	query := `
-		SELECT c.id, c.user_id, c.content, c.location_name, c.latitude, c.longitude,
-		c.activity_id, c.created_at, c.updated_at,
-		u.id, u.username, u.display_name, u.avatar_url, u.actor_id
-		FROM checkins c
-		JOIN users u ON c.user_id = u.id
+	query := `SELECT ` + checkinColumns + checkinJoins + `
 		WHERE c.user_id = $1
-	ORDER BY c.created_at DESC
+		ORDER BY c.created_at DESC
 		LIMIT $2 OFFSET $3
 	`
 
@@ -172,151 +308,609 @@ func (cr *CheckinRepositoryImplement) GetCheckinsByUserID(ctx context.Context, u
 	if err != nil {
 		return nil, fmt.Errorf("fail to get checkins by user ID: %w", err)
 	}
+
+	return cr.collectCheckins(ctx, rows)
+}
+
+// CountCheckinsByUserID count checkins posted by a user
+func (cr *CheckinRepositoryImplement) CountCheckinsByUserID(ctx context.Context, userID uuid.UUID) (int, error) {
+	query := `SELECT count(*) FROM checkins WHERE user_id = $1`
+
+	var count int
+	err := cr.pool.QueryRow(ctx, query, userID).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("fail to count checkins by user ID: %w", err)
+	}
+
+	return count, nil
+}
+
+// GetGlobalFeed get local and remote checkins, newest first
+// checkins of remote actors on blocked or silenced domains are left out, and so are checkins of actors viewer blocked or muted
+// pass uuid.Nil as viewerID for anonymous viewers
+func (cr *CheckinRepositoryImplement) GetGlobalFeed(ctx context.Context, viewerID uuid.UUID, limit, offest int) ([]Checkin, error) {
+	query := `SELECT ` + checkinColumns + checkinJoins + `
+		WHERE NOT EXISTS (` + remoteActorDomainBlocked + `)
+			AND NOT EXISTS (` + actorHiddenFromUser("COALESCE(u.actor_id, ra.actor_id)") + `)
+		ORDER BY c.created_at DESC
+		LIMIT $2 OFFSET $3
+	`
+
+	rows, err := cr.pool.Query(ctx, query, viewerID, limit, offest)
+	if err != nil {
+		return nil, fmt.Errorf("fail to get global feed: %w", err)
+	}
+
+	return cr.collectCheckins(ctx, rows)
+}
+
+// GetHomeFeed get checkins of user and actors user follows, with checkins boosted by them, newest first
+// a boosted checkin is ordered by time of the boost and has BoostedBy set
+// checkins of remote actors on blocked domains are left out, silenced ones stay for their followers
+// checkins of actors user blocked or muted are left out, and so are boosts by them
+func (cr *CheckinRepositoryImplement) GetHomeFeed(ctx context.Context, userID uuid.UUID, limit, offset int) ([]Checkin, error) {
+	query := `
+		SELECT ` + checkinColumns + `,
+			COALESCE(feed.boosted_by, ''), COALESCE(bu.username, br.username || '@' || br.domain, ''),
+			COALESCE(bu.display_name, br.display_name, ''), COALESCE(bu.avatar_url, br.avatar_url, '')
+		FROM (
+			SELECT c.id AS checkin_id, NULL::VARCHAR AS boosted_by, c.created_at AS sorted_at
+			FROM checkins c` + checkinAuthorJoins + `
+			WHERE c.user_id = $1 OR COALESCE(u.actor_id, ra.actor_id) IN (
+				SELECT target_actor_id FROM following WHERE user_id = $1 AND status = 'accepted'
+			)
+			UNION ALL
+			SELECT s.checkin_id, s.actor_id, s.created_at
+			FROM shares s
+			WHERE s.user_id = $1 OR s.actor_id IN (
+				SELECT target_actor_id FROM following WHERE user_id = $1 AND status = 'accepted'
+			)
+		) feed
+		JOIN checkins c ON c.id = feed.checkin_id` + checkinAuthorJoins + `
+		LEFT JOIN users bu ON bu.actor_id = feed.boosted_by
+		LEFT JOIN remote_actors br ON br.actor_id = feed.boosted_by
+		WHERE NOT EXISTS (` + remoteActorDomainBlocked + ` AND db.severity = '` + DomainBlockSeveritySuspend + `')
+			AND NOT EXISTS (` + actorHiddenFromUser("COALESCE(u.actor_id, ra.actor_id)") + `)
+			AND NOT EXISTS (` + actorHiddenFromUser("feed.boosted_by") + `)
+		ORDER BY feed.sorted_at DESC
+		LIMIT $2 OFFSET $3
+	`
+
+	if limit <= 0 {
+		limit = 10
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	rows, err := cr.pool.Query(ctx, query, userID, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("fail to get home feed: %w", err)
+	}
 	defer rows.Close()
 
 	var checkins []Checkin
 
 	for rows.Next() {
-		var checkin Checkin
-		var user User
+		var booster User
+		checkin, err := scanCheckin(rows, &booster.ActorID, &booster.Username, &booster.DisplayName, &booster.AvatarURL)
+		if err != nil {
+			return nil, fmt.Errorf("fail to scan checkin: %w", err)
+		}
 
-		err := rows.Scan(
-			&checkin.ID, &checkin.UserID, &checkin.Content, &checkin.LocationName, &checkin.Latitude, &checkin.Longitude,
-			&checkin.ActivityID, &checkin.CreatedAt, &checkin.UpdatedAt,
-			&user.ID, &user.Username, &user.DisplayName, &user.AvatarURL, &user.ActorID,
-		)
+		if booster.ActorID != "" {
+			checkin.BoostedBy = &booster
+		}
+
+		checkins = append(checkins, *checkin)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("error on iterating checkin rows: %w", err)
+	}
+	rows.Close()
+
+	err = cr.attachMedia(ctx, checkins)
+	if err != nil {
+		return nil, err
+	}
 
+	err = cr.attachEntities(ctx, checkins)
+	if err != nil {
+		return nil, err
+	}
+
+	return checkins, nil
+}
+
+// GetCheckinsByTag get local and remote checkins with hashtag, newest first
+// checkins are filtered like the global feed, pass uuid.Nil as viewerID for anonymous viewers
+func (cr *CheckinRepositoryImplement) GetCheckinsByTag(ctx context.Context, viewerID uuid.UUID, tag string, limit, offset int) ([]Checkin, error) {
+	query := `SELECT ` + checkinColumns + checkinJoins + `
+		WHERE EXISTS (SELECT 1 FROM checkin_tags ct WHERE ct.checkin_id = c.id AND ct.name = $4)
+			AND NOT EXISTS (` + remoteActorDomainBlocked + `)
+			AND NOT EXISTS (` + actorHiddenFromUser("COALESCE(u.actor_id, ra.actor_id)") + `)
+		ORDER BY c.created_at DESC
+		LIMIT $2 OFFSET $3
+	`
+
+	if limit <= 0 {
+		limit = 10
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	rows, err := cr.pool.Query(ctx, query, viewerID, limit, offset, tag)
+	if err != nil {
+		return nil, fmt.Errorf("fail to get checkins by tag: %w", err)
+	}
+
+	return cr.collectCheckins(ctx, rows)
+}
+
+// collectCheckins scan checkin rows selected with checkinColumns and get each checkin's media, hashtags and mentions
+func (cr *CheckinRepositoryImplement) collectCheckins(ctx context.Context, rows pgx.Rows) ([]Checkin, error) {
+	defer rows.Close()
+
+	var checkins []Checkin
+
+	for rows.Next() {
+		checkin, err := scanCheckin(rows)
 		if err != nil {
 			return nil, fmt.Errorf("fail to scan checkin: %w", err)
 		}
 
-		checkin.User = &user
-		checkins = append(checkins, checkin)
+		checkins = append(checkins, *checkin)
 	}
 
-	err = rows.Err()
+	err := rows.Err()
 	if err != nil {
 		return nil, fmt.Errorf("error on iterating checkin rows: %w", err)
 	}
+	rows.Close()
+
+	err = cr.attachMedia(ctx, checkins)
+	if err != nil {
+		return nil, err
+	}
+
+	err = cr.attachEntities(ctx, checkins)
+	if err != nil {
+		return nil, err
+	}
 
-	// get each checkin's media data
+	return checkins, nil
+}
+
+// attachMedia get each checkin's media data
+func (cr *CheckinRepositoryImplement) attachMedia(ctx context.Context, checkins []Checkin) error {
 	for i := range checkins {
-		mediaQuery := `
-			SELECT id, file_path, file_type, file_size, width, height, created_at
-			FROM media
-			WHERE checkin_id = $1
-		`
+		media, err := cr.getMedia(ctx, checkins[i].ID)
+		if err != nil {
+			return err
+		}
+
+		checkins[i].Media = media
+	}
 
-		mediaRows, err := cr.pool.Query(ctx, mediaQuery, checkins[i].ID)
+	return nil
+}
+
+// getMedia get media of a checkin
+func (cr *CheckinRepositoryImplement) getMedia(ctx context.Context, checkinID uuid.UUID) ([]Media, error) {
+	mediaQuery := `
+		SELECT id, file_path, file_type, file_size, width, height, COALESCE(remote_url, ''), created_at
+		FROM media
+		WHERE checkin_id = $1
+	`
+
+	mediaRows, err := cr.pool.Query(ctx, mediaQuery, checkinID)
+	if err != nil {
+		return nil, fmt.Errorf("fail to query media: %w", err)
+	}
+	defer mediaRows.Close()
+
+	var medias []Media
+
+	// if next row exists, mediaRows.Next() return true and scan media data
+	// if it's at the end, mediaRows.Next() return false and break the loop
+	for mediaRows.Next() {
+		var media Media
+		err := mediaRows.Scan(
+			&media.ID, &media.FilePath, &media.FileType, &media.FileSize,
+			&media.Width, &media.Height, &media.RemoteURL, &media.CreatedAt,
+		)
 		if err != nil {
-			return nil, fmt.Errorf("fail to query media: %w", err)
+			return nil, fmt.Errorf("fail to scan media: %w", err)
 		}
 
-		for mediaRows.Next() {
-			var media Media
-			err := mediaRows.Scan(
-				&media.ID, &media.FilePath, &media.FileType, &media.FileSize,
-				&media.Width, &media.Height, &media.CreatedAt,
-			)
+		media.CheckinID = checkinID
+		medias = append(medias, media)
+	}
+
+	err = mediaRows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("error on iterating media rows: %w", err)
+	}
 
-			if err != nil {
-				mediaRows.Close()
-				return nil, fmt.Errorf("fail to scan media: %w", err)
-			}
+	return medias, nil
+}
 
-			media.CheckinID = checkins[i].ID
-			checkins[i].Media = append(checkins[i].Media, media)
+// attachEntities get each checkin's hashtags and mentions
+func (cr *CheckinRepositoryImplement) attachEntities(ctx context.Context, checkins []Checkin) error {
+	for i := range checkins {
+		tags, mentions, err := cr.getEntities(ctx, checkins[i].ID)
+		if err != nil {
+			return err
 		}
 
-		if err := mediaRows.Err(); err != nil {
-			mediaRows.Close()
-			return nil, fmt.Errorf("error on iterating media rows: %w", err)
+		checkins[i].Tags = tags
+		checkins[i].Mentions = mentions
+	}
+
+	return nil
+}
+
+// getEntities get hashtags and mentions of a checkin
+func (cr *CheckinRepositoryImplement) getEntities(ctx context.Context, checkinID uuid.UUID) ([]string, []Mention, error) {
+	tagRows, err := cr.pool.Query(ctx, `SELECT name FROM checkin_tags WHERE checkin_id = $1 ORDER BY name`, checkinID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("fail to query tags: %w", err)
+	}
+	defer tagRows.Close()
+
+	var tags []string
+
+	for tagRows.Next() {
+		var tag string
+		err := tagRows.Scan(&tag)
+		if err != nil {
+			return nil, nil, fmt.Errorf("fail to scan tag: %w", err)
 		}
 
-		mediaRows.Close()
+		tags = append(tags, tag)
 	}
 
-	return checkins, nil
+	err = tagRows.Err()
+	if err != nil {
+		return nil, nil, fmt.Errorf("error on iterating tag rows: %w", err)
+	}
+	tagRows.Close()
+
+	mentionQuery := `
+		SELECT actor_id, acct, COALESCE(url, '')
+		FROM checkin_mentions
+		WHERE checkin_id = $1
+		ORDER BY acct
+	`
+
+	mentionRows, err := cr.pool.Query(ctx, mentionQuery, checkinID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("fail to query mentions: %w", err)
+	}
+	defer mentionRows.Close()
+
+	var mentions []Mention
+
+	for mentionRows.Next() {
+		var mention Mention
+		err := mentionRows.Scan(&mention.ActorID, &mention.Acct, &mention.URL)
+		if err != nil {
+			return nil, nil, fmt.Errorf("fail to scan mention: %w", err)
+		}
+
+		mentions = append(mentions, mention)
+	}
+
+	err = mentionRows.Err()
+	if err != nil {
+		return nil, nil, fmt.Errorf("error on iterating mention rows: %w", err)
+	}
+
+	return tags, mentions, nil
+}
+
+// replaceEntities replace stored hashtags and mentions of checkin with checkin.Tags and checkin.Mentions
+func replaceEntities(ctx context.Context, tx pgx.Tx, checkin *Checkin) error {
+	_, err := tx.Exec(ctx, `DELETE FROM checkin_tags WHERE checkin_id = $1`, checkin.ID)
+	if err != nil {
+		return fmt.Errorf("fail to delete tags: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `DELETE FROM checkin_mentions WHERE checkin_id = $1`, checkin.ID)
+	if err != nil {
+		return fmt.Errorf("fail to delete mentions: %w", err)
+	}
+
+	tagQuery := `
+		INSERT INTO checkin_tags (checkin_id, name)
+		VALUES ($1, $2)
+		ON CONFLICT DO NOTHING
+	`
+
+	for _, tag := range checkin.Tags {
+		_, err := tx.Exec(ctx, tagQuery, checkin.ID, tag)
+		if err != nil {
+			return fmt.Errorf("fail to create tag: %w", err)
+		}
+	}
+
+	mentionQuery := `
+		INSERT INTO checkin_mentions (checkin_id, actor_id, acct, url)
+		VALUES ($1, $2, $3, NULLIF($4, ''))
+		ON CONFLICT DO NOTHING
+	`
+
+	for _, mention := range checkin.Mentions {
+		_, err := tx.Exec(ctx, mentionQuery, checkin.ID, mention.ActorID, mention.Acct, mention.URL)
+		if err != nil {
+			return fmt.Errorf("fail to create mention: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// CountLocalCheckins count checkins posted on this server
+func (cr *CheckinRepositoryImplement) CountLocalCheckins(ctx context.Context) (int, error) {
+	query := `SELECT count(*) FROM checkins WHERE is_remote = false`
+
+	var count int
+	err := cr.pool.QueryRow(ctx, query).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("fail to count local checkins: %w", err)
+	}
+
+	return count, nil
 }
 
-func (cr *CheckinRepositoryImplement) GetGlobalFeed(ctx context.Context, limit, offest int) ([]Checkin, error) {
+// UpdateCheckin save edited content, location, hashtags and mentions of checkin at checkin.UpdatedAt
+// when mediaIDs isn't nil, they replace media of checkin, media left out are deleted and returned
+// previous version is kept in checkin_edits
+func (cr *CheckinRepositoryImplement) UpdateCheckin(ctx context.Context, checkin *Checkin, mediaIDs []uuid.UUID) ([]Media, error) {
+	tx, err := cr.pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("fail to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	editQuery := `
+		INSERT INTO checkin_edits (checkin_id, content, location_name, latitude, longitude, edited_at)
+		SELECT id, content, location_name, latitude, longitude, $2
+		FROM checkins
+		WHERE id = $1
+	`
+
+	_, err = tx.Exec(ctx, editQuery, checkin.ID, checkin.UpdatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("fail to save checkin edit: %w", err)
+	}
+
 	query := `
-		SELECT c.id, c.user_id, c.content, c.location_name, c.latitude, c.longitude,
-			c.activity_id, c.created_at, c.updated_at,
-			u.id, u.username, u.display_name, u.avatar_url, u.actor_id
-		FROM checkins c
-		JOIN users u ON c.user_id = u.id
-		ORDER BY c.created_at DESC
-		LIMIT $1 OFFSET $2
+		UPDATE checkins
+		SET content = $1, location_name = $2, latitude = $3, longitude = $4, updated_at = $5
+		WHERE id = $6
 	`
 
-	rows, err := cr.pool.Query(ctx, query, limit, offest)
+	_, err = tx.Exec(ctx, query,
+		checkin.Content, checkin.LocationName, checkin.Latitude, checkin.Longitude,
+		checkin.UpdatedAt, checkin.ID,
+	)
 	if err != nil {
-		return nil, fmt.Errorf("fail to get global feed: %w", err)
+		return nil, fmt.Errorf("fail to update checkin: %w", err)
 	}
-	defer rows.Close()
 
-	var checkins []Checkin
+	err = replaceEntities(ctx, tx, checkin)
+	if err != nil {
+		return nil, err
+	}
 
-	for rows.Next() {
-		var checkin Checkin
-		var user User
+	var removed []Media
+	if mediaIDs != nil {
+		removed, err = replaceMedia(ctx, tx, checkin, mediaIDs)
+		if err != nil {
+			return nil, err
+		}
+	}
 
-		err := rows.Scan(
-			&checkin.ID, &checkin.UserID, &checkin.Content, &checkin.LocationName, &checkin.Latitude, &checkin.Longitude,
-			&checkin.ActivityID, &checkin.CreatedAt, &checkin.UpdatedAt,
-			&user.ID, &user.Username, &user.DisplayName, &user.AvatarURL, &user.ActorID,
-		)
+	err = tx.Commit(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("fail to commit checkin update: %w", err)
+	}
+
+	return removed, nil
+}
 
+// replaceMedia replace media of a local checkin with mediaIDs and return media left out, they are deleted
+// new media must be uploads of checkin's author which aren't attached to a checkin yet
+func replaceMedia(ctx context.Context, tx pgx.Tx, checkin *Checkin, mediaIDs []uuid.UUID) ([]Media, error) {
+	ids := make([]string, 0, len(mediaIDs))
+	seen := make(map[uuid.UUID]bool)
+	for _, mediaID := range mediaIDs {
+		if seen[mediaID] {
+			continue
+		}
+		seen[mediaID] = true
+		ids = append(ids, mediaID.String())
+	}
+
+	rows, err := tx.Query(ctx, `
+		DELETE FROM media
+		WHERE checkin_id = $1 AND NOT (id = ANY($2::UUID[]))
+		RETURNING id, file_path
+	`, checkin.ID, ids)
+	if err != nil {
+		return nil, fmt.Errorf("fail to delete media: %w", err)
+	}
+	defer rows.Close()
+
+	var removed []Media
+	for rows.Next() {
+		media := Media{CheckinID: checkin.ID}
+		err = rows.Scan(&media.ID, &media.FilePath)
 		if err != nil {
-			return nil, fmt.Errorf("fail to scan checkin: %w", err)
+			return nil, fmt.Errorf("fail to scan deleted media: %w", err)
 		}
+		removed = append(removed, media)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("fail to delete media: %w", err)
+	}
 
-		checkin.User = &user
-		checkins = append(checkins, checkin)
+	tag, err := tx.Exec(ctx, `
+		UPDATE media
+		SET checkin_id = $1
+		WHERE id = ANY($2::UUID[]) AND (checkin_id = $1 OR (checkin_id IS NULL AND user_id = $3))
+	`, checkin.ID, ids, checkin.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("fail to attach media: %w", err)
 	}
 
-	err = rows.Err()
+	if tag.RowsAffected() != int64(len(ids)) {
+		return nil, ErrMediaNotAttachable
+	}
+
+	return removed, nil
+}
+
+// ReplaceRemoteMedia replace media of a remote checkin, they only have remote URL
+func (cr *CheckinRepositoryImplement) ReplaceRemoteMedia(ctx context.Context, checkinID uuid.UUID, media []Media) error {
+	tx, err := cr.pool.Begin(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("error iterating checkin rows: %w", err)
+		return fmt.Errorf("fail to begin transaction: %w", err)
 	}
+	defer tx.Rollback(ctx)
 
-	// get each checkin's media data
-	for i := range checkins {
-		mediaQuery := `
-			SELECT id, file_path, file_type, file_size, width, height, created_at
-			FROM media
-			WHERE checkin_id = $1
-		`
+	_, err = tx.Exec(ctx, `DELETE FROM media WHERE checkin_id = $1`, checkinID)
+	if err != nil {
+		return fmt.Errorf("fail to delete remote media: %w", err)
+	}
+
+	mediaQuery := `
+		INSERT INTO media (
+			checkin_id, file_path, file_type, file_size, width, height, remote_url
+		) VALUES ($1, '', $2, 0, $3, $4, $5)
+	`
 
-		mediaRows, err := cr.pool.Query(ctx, mediaQuery, checkins[i].ID)
+	for _, m := range media {
+		_, err := tx.Exec(ctx, mediaQuery, checkinID, m.FileType, m.Width, m.Height, m.RemoteURL)
 		if err != nil {
-			return nil, fmt.Errorf("fail to get media: %w", err)
+			return fmt.Errorf("fail to create remote media: %w", err)
 		}
+	}
 
-		for mediaRows.Next() {
-			var media Media
+	err = tx.Commit(ctx)
+	if err != nil {
+		return fmt.Errorf("fail to commit remote media: %w", err)
+	}
 
-			err := mediaRows.Scan(
-				&media.ID, &media.FilePath, &media.FileType, &media.FileSize,
-				&media.Width, &media.Height, &media.CreatedAt,
-			)
-			if err != nil {
-				mediaRows.Close()
-				return nil, fmt.Errorf("fail to scan media: %w", err)
-			}
+	return nil
+}
 
-			media.CheckinID = checkins[i].ID
-			checkins[i].Media = append(checkins[i].Media, media)
-		}
+// GetCheckinEdits get previous versions of checkin, newest first
+func (cr *CheckinRepositoryImplement) GetCheckinEdits(ctx context.Context, checkinID uuid.UUID) ([]CheckinEdit, error) {
+	query := `
+		SELECT id, checkin_id, content, location_name, latitude, longitude, edited_at
+		FROM checkin_edits
+		WHERE checkin_id = $1
+		ORDER BY edited_at DESC
+	`
 
-		mediaRows.Close()
+	rows, err := cr.pool.Query(ctx, query, checkinID)
+	if err != nil {
+		return nil, fmt.Errorf("fail to get checkin edits: %w", err)
+	}
+	defer rows.Close()
 
-		err = mediaRows.Err()
+	var edits []CheckinEdit
+
+	for rows.Next() {
+		var edit CheckinEdit
+		err := rows.Scan(
+			&edit.ID, &edit.CheckinID, &edit.Content, &edit.LocationName,
+			&edit.Latitude, &edit.Longitude, &edit.EditedAt,
+		)
 		if err != nil {
-			return nil, fmt.Errorf("error iterating media rows: %w", err)
+			return nil, fmt.Errorf("fail to scan checkin edit: %w", err)
 		}
+
+		edits = append(edits, edit)
 	}
 
-	return checkins, nil
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("error on iterating checkin edit rows: %w", err)
+	}
+
+	return edits, nil
+}
+
+// DeleteCheckin delete local checkin with its media data, and leave a tombstone at its object ID
+func (cr *CheckinRepositoryImplement) DeleteCheckin(ctx context.Context, id uuid.UUID, objectID, formerType string) error {
+	tx, err := cr.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("fail to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// media, likes and shares are deleted by cascade
+	_, err = tx.Exec(ctx, `DELETE FROM checkins WHERE id = $1 AND is_remote = false`, id)
+	if err != nil {
+		return fmt.Errorf("fail to delete checkin: %w", err)
+	}
+
+	query := `
+		INSERT INTO tombstones (object_id, former_type)
+		VALUES ($1, $2)
+		ON CONFLICT (object_id) DO NOTHING
+	`
+
+	_, err = tx.Exec(ctx, query, objectID, formerType)
+	if err != nil {
+		return fmt.Errorf("fail to create tombstone: %w", err)
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return fmt.Errorf("fail to commit checkin deletion: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteRemoteCheckin delete stored copy of a remote Note, only its author can delete it
+func (cr *CheckinRepositoryImplement) DeleteRemoteCheckin(ctx context.Context, objectID, actorID string) error {
+	query := `
+		DELETE FROM checkins
+		WHERE object_id = $1 AND is_remote = true
+		AND remote_actor_id IN (SELECT id FROM remote_actors WHERE actor_id = $2)
+	`
+
+	_, err := cr.pool.Exec(ctx, query, objectID, actorID)
+	if err != nil {
+		return fmt.Errorf("fail to delete remote checkin: %w", err)
+	}
+
+	return nil
+}
+
+// GetTombstone get tombstone left at object ID of a deleted checkin
+func (cr *CheckinRepositoryImplement) GetTombstone(ctx context.Context, objectID string) (*Tombstone, error) {
+	query := `
+		SELECT id, object_id, former_type, deleted_at
+		FROM tombstones
+		WHERE object_id = $1
+	`
+
+	tombstone := &Tombstone{}
+	err := cr.pool.QueryRow(ctx, query, objectID).Scan(
+		&tombstone.ID, &tombstone.ObjectID, &tombstone.FormerType, &tombstone.DeletedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("fail to get tombstone: %w", err)
+	}
+
+	return tombstone, nil
 }
@@ -0,0 +1,188 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// domain block severity, names follow Mastodon's domain block export
+const (
+	// DomainBlockSeveritySuspend activities are rejected, nothing is delivered and follow relations are removed
+	DomainBlockSeveritySuspend = "suspend"
+	// DomainBlockSeveritySilence actors can still follow, their checkins are kept out of the global feed
+	DomainBlockSeveritySilence = "silence"
+)
+
+// DomainBlock moderation of a remote domain and all its subdomains
+type DomainBlock struct {
+	ID            uuid.UUID `json:"id"`
+	Domain        string    `json:"domain"`
+	Severity      string    `json:"severity"`
+	RejectMedia   bool      `json:"reject_media"`
+	RejectReports bool      `json:"reject_reports"`
+	PublicComment string    `json:"public_comment,omitempty"`
+	Obfuscate     bool      `json:"obfuscate"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// DomainBlockRepository manipulate domain block data
+type DomainBlockRepository interface {
+	UpsertDomainBlock(ctx context.Context, block *DomainBlock) error
+	DeleteDomainBlock(ctx context.Context, domain string) error
+	GetDomainBlocks(ctx context.Context) ([]DomainBlock, error)
+	FindDomainBlock(ctx context.Context, host string) (*DomainBlock, error)
+}
+
+// DomainBlockRepositoryImplement
+type DomainBlockRepositoryImplement struct {
+	pool *pgxpool.Pool
+}
+
+// NewDomainBlockRepository
+func NewDomainBlockRepository(pool *pgxpool.Pool) DomainBlockRepository {
+	return &DomainBlockRepositoryImplement{pool: pool}
+}
+
+// remoteActorDomainBlocked condition matching remote actor ra whose domain or a parent domain is blocked as db
+// ra.domain may carry a port, blocks only have host names
+const remoteActorDomainBlocked = `
+	SELECT 1 FROM domain_blocks db
+	WHERE split_part(ra.domain, ':', 1) = db.domain OR split_part(ra.domain, ':', 1) LIKE '%.' || db.domain`
+
+// domainBlockColumns columns selected for a DomainBlock, in scanDomainBlock order
+const domainBlockColumns = `id, domain, severity, reject_media, reject_reports, COALESCE(public_comment, ''),
+	obfuscate, created_at, updated_at`
+
+// scanDomainBlock scan a row selected with domainBlockColumns
+func scanDomainBlock(row pgx.Row) (*DomainBlock, error) {
+	block := &DomainBlock{}
+
+	err := row.Scan(
+		&block.ID, &block.Domain, &block.Severity, &block.RejectMedia, &block.RejectReports, &block.PublicComment,
+		&block.Obfuscate, &block.CreatedAt, &block.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return block, nil
+}
+
+// UpsertDomainBlock add domain block or replace the existing block of the same domain
+func (dbr *DomainBlockRepositoryImplement) UpsertDomainBlock(ctx context.Context, block *DomainBlock) error {
+	query := `
+		INSERT INTO domain_blocks (domain, severity, reject_media, reject_reports, public_comment, obfuscate)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6)
+		ON CONFLICT (domain) DO UPDATE
+		SET severity = excluded.severity, reject_media = excluded.reject_media, reject_reports = excluded.reject_reports,
+			public_comment = excluded.public_comment, obfuscate = excluded.obfuscate, updated_at = now()
+		RETURNING id, created_at, updated_at
+	`
+
+	err := dbr.pool.QueryRow(ctx, query,
+		block.Domain, block.Severity, block.RejectMedia, block.RejectReports, block.PublicComment, block.Obfuscate,
+	).Scan(&block.ID, &block.CreatedAt, &block.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("fail to upsert domain block: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteDomainBlock
+func (dbr *DomainBlockRepositoryImplement) DeleteDomainBlock(ctx context.Context, domain string) error {
+	query := `DELETE FROM domain_blocks WHERE domain = $1`
+
+	_, err := dbr.pool.Exec(ctx, query, domain)
+	if err != nil {
+		return fmt.Errorf("fail to delete domain block: %w", err)
+	}
+
+	return nil
+}
+
+// GetDomainBlocks get all domain blocks ordered by domain
+func (dbr *DomainBlockRepositoryImplement) GetDomainBlocks(ctx context.Context) ([]DomainBlock, error) {
+	query := `SELECT ` + domainBlockColumns + ` FROM domain_blocks ORDER BY domain`
+
+	rows, err := dbr.pool.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("fail to get domain blocks: %w", err)
+	}
+	defer rows.Close()
+
+	var blocks []DomainBlock
+
+	for rows.Next() {
+		block, err := scanDomainBlock(rows)
+		if err != nil {
+			return nil, fmt.Errorf("fail to scan domain block: %w", err)
+		}
+
+		blocks = append(blocks, *block)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("error on iterating domain block rows: %w", err)
+	}
+
+	return blocks, nil
+}
+
+// FindDomainBlock get the most specific block of host or one of its parent domains
+// return nil when host isn't blocked
+func (dbr *DomainBlockRepositoryImplement) FindDomainBlock(ctx context.Context, host string) (*DomainBlock, error) {
+	query := `
+		SELECT ` + domainBlockColumns + `
+		FROM domain_blocks
+		WHERE domain = ANY($1)
+		ORDER BY length(domain) DESC
+		LIMIT 1
+	`
+
+	domains := parentDomains(host)
+	if len(domains) == 0 {
+		return nil, nil
+	}
+
+	block, err := scanDomainBlock(dbr.pool.QueryRow(ctx, query, domains))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("fail to find domain block: %w", err)
+	}
+
+	return block, nil
+}
+
+// parentDomains list host and its parent domains, e.g. a.example.com, example.com and com
+// port is dropped
+func parentDomains(host string) []string {
+	if i := strings.LastIndex(host, ":"); i >= 0 && !strings.Contains(host[i:], "]") {
+		host = host[:i]
+	}
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+
+	var domains []string
+	for host != "" {
+		domains = append(domains, host)
+
+		_, parent, found := strings.Cut(host, ".")
+		if !found {
+			break
+		}
+		host = parent
+	}
+
+	return domains
+}
@@ -0,0 +1,179 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Like like of a local user or a remote actor on a checkin
+type Like struct {
+	ID        uuid.UUID `json:"id"`
+	CheckinID uuid.UUID `json:"checkin_id"`
+	// UserID local user who likes the checkin, uuid.Nil when liker is a remote actor
+	UserID     uuid.UUID `json:"user_id"`
+	ActorID    string    `json:"actor_id"`
+	ObjectID   string    `json:"object_id"`
+	ActivityID string    `json:"activity_id"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// LikeRepository manipulate like data
+type LikeRepository interface {
+	AddLike(ctx context.Context, like *Like) error
+	RemoveLike(ctx context.Context, checkinID uuid.UUID, actorID string) error
+	RemoveLikeByActivityID(ctx context.Context, activityID, actorID string) error
+	GetLike(ctx context.Context, checkinID uuid.UUID, actorID string) (*Like, error)
+	GetLikedByUserID(ctx context.Context, userID uuid.UUID, cursor uuid.UUID, limit int) ([]Like, error)
+	CountLikedByUserID(ctx context.Context, userID uuid.UUID) (int, error)
+}
+
+// LikeRepositoryImplement
+type LikeRepositoryImplement struct {
+	pool *pgxpool.Pool
+}
+
+// NewLikeRepository
+func NewLikeRepository(pool *pgxpool.Pool) LikeRepository {
+	return &LikeRepositoryImplement{pool: pool}
+}
+
+// likeColumns columns selected for a Like, in scanLike order
+const likeColumns = `id, checkin_id, COALESCE(user_id, '00000000-0000-0000-0000-000000000000'::UUID), actor_id, object_id, activity_id, created_at`
+
+// scanLike scan a row selected with likeColumns
+func scanLike(row pgx.Row) (*Like, error) {
+	like := &Like{}
+
+	err := row.Scan(
+		&like.ID, &like.CheckinID, &like.UserID, &like.ActorID,
+		&like.ObjectID, &like.ActivityID, &like.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return like, nil
+}
+
+// AddLike store like, an actor likes a checkin only once
+// when actor already likes the checkin, like is filled with the stored one
+func (lr *LikeRepositoryImplement) AddLike(ctx context.Context, like *Like) error {
+	query := `
+		INSERT INTO likes (checkin_id, user_id, actor_id, object_id, activity_id)
+		VALUES ($1, NULLIF($2, '00000000-0000-0000-0000-000000000000'::UUID), $3, $4, $5)
+		ON CONFLICT DO NOTHING
+		RETURNING id, created_at
+	`
+
+	err := lr.pool.QueryRow(ctx, query,
+		like.CheckinID, like.UserID, like.ActorID, like.ObjectID, like.ActivityID,
+	).Scan(&like.ID, &like.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		existing, err := lr.GetLike(ctx, like.CheckinID, like.ActorID)
+		if err != nil {
+			return err
+		}
+
+		*like = *existing
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("fail to add like: %w", err)
+	}
+
+	return nil
+}
+
+// RemoveLike
+func (lr *LikeRepositoryImplement) RemoveLike(ctx context.Context, checkinID uuid.UUID, actorID string) error {
+	query := `DELETE FROM likes WHERE checkin_id = $1 AND actor_id = $2`
+
+	_, err := lr.pool.Exec(ctx, query, checkinID, actorID)
+	if err != nil {
+		return fmt.Errorf("fail to remove like: %w", err)
+	}
+
+	return nil
+}
+
+// RemoveLikeByActivityID remove like by its Like activity, only the actor who sent it can remove it
+func (lr *LikeRepositoryImplement) RemoveLikeByActivityID(ctx context.Context, activityID, actorID string) error {
+	query := `DELETE FROM likes WHERE activity_id = $1 AND actor_id = $2`
+
+	_, err := lr.pool.Exec(ctx, query, activityID, actorID)
+	if err != nil {
+		return fmt.Errorf("fail to remove like by activity ID: %w", err)
+	}
+
+	return nil
+}
+
+// GetLike get like of an actor on a checkin
+func (lr *LikeRepositoryImplement) GetLike(ctx context.Context, checkinID uuid.UUID, actorID string) (*Like, error) {
+	query := `SELECT ` + likeColumns + ` FROM likes WHERE checkin_id = $1 AND actor_id = $2`
+
+	like, err := scanLike(lr.pool.QueryRow(ctx, query, checkinID, actorID))
+	if err != nil {
+		return nil, fmt.Errorf("fail to get like: %w", err)
+	}
+
+	return like, nil
+}
+
+// GetLikedByUserID get likes of a local user after cursor, ordered by id
+func (lr *LikeRepositoryImplement) GetLikedByUserID(ctx context.Context, userID uuid.UUID, cursor uuid.UUID, limit int) ([]Like, error) {
+	query := `
+		SELECT ` + likeColumns + `
+		FROM likes
+		WHERE user_id = $1 AND id > $2
+		ORDER BY id
+		LIMIT $3
+	`
+
+	if limit <= 0 {
+		limit = 10
+	}
+
+	rows, err := lr.pool.Query(ctx, query, userID, cursor, limit)
+	if err != nil {
+		return nil, fmt.Errorf("fail to get liked: %w", err)
+	}
+	defer rows.Close()
+
+	var likes []Like
+
+	for rows.Next() {
+		like, err := scanLike(rows)
+		if err != nil {
+			return nil, fmt.Errorf("fail to scan like: %w", err)
+		}
+
+		likes = append(likes, *like)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("error on iterating like rows: %w", err)
+	}
+
+	return likes, nil
+}
+
+// CountLikedByUserID count checkins a local user likes
+func (lr *LikeRepositoryImplement) CountLikedByUserID(ctx context.Context, userID uuid.UUID) (int, error) {
+	query := `SELECT count(*) FROM likes WHERE user_id = $1`
+
+	var count int
+	err := lr.pool.QueryRow(ctx, query, userID).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("fail to count liked: %w", err)
+	}
+
+	return count, nil
+}
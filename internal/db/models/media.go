@@ -2,21 +2,28 @@ package models
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"time"
 )
 
+// ErrMediaNotAttachable media doesn't exist, isn't uploaded by checkin's author or is attached to another checkin
+var ErrMediaNotAttachable = errors.New("media can't be attached to the checkin")
+
 type Media struct {
 	ID        uuid.UUID `json:"id"`
 	CheckinID uuid.UUID `json:"checkin_id,omitempty"`
+	UserID    uuid.UUID `json:"user_id,omitempty"` // uploader of local media, only they can attach it to a checkin
 	FilePath  string    `json:"file_path"`
 	FileType  string    `json:"file_type"`
 	FileSize  int       `json:"file_size"`
 	Width     int       `json:"width,omitempty"`
 	Height    int       `json:"height,omitempty"`
 	URL       string    `json:"url,omitempty"` // not in database, generate by server
+	// RemoteURL media of remote checkin, it isn't stored in MinIO
+	RemoteURL string    `json:"-"`
 	CreatedAt time.Time `json:"created_at"`
 }
 
@@ -25,6 +32,7 @@ type MediaRepository interface {
 	CreateMedia(ctx context.Context, media *Media) error
 	GetMediaByID(ctx context.Context, id uuid.UUID) (*Media, error)
 	UpdateMedia(ctx context.Context, media *Media) error
+	DeleteMedia(ctx context.Context, id uuid.UUID) error
 }
 
 // MediaRepositoryImplement
@@ -41,8 +49,8 @@ func NewMediaRepository(pool *pgxpool.Pool) MediaRepository {
 func (mr *MediaRepositoryImplement) CreateMedia(ctx context.Context, media *Media) error {
 	query := `
 		INSERT INTO media (
-			checkin_id, file_path, file_type, file_size, width, height
-		) VALUES ($1, $2, $3, $4, $5, $6)
+			checkin_id, user_id, file_path, file_type, file_size, width, height
+		) VALUES ($1, $2, $3, $4, $5, $6, $7)
 		RETURNING id, created_at
 	`
 
@@ -53,9 +61,13 @@ func (mr *MediaRepositoryImplement) CreateMedia(ctx context.Context, media *Medi
 	} else {
 		checkinID = media.CheckinID
 	}
+	var userID interface{}
+	if media.UserID != uuid.Nil {
+		userID = media.UserID
+	}
 
 	err := mr.pool.QueryRow(ctx, query,
-		checkinID, media.FilePath, media.FileType, media.FileSize, media.Width, media.Height,
+		checkinID, userID, media.FilePath, media.FileType, media.FileSize, media.Width, media.Height,
 	).Scan(&media.ID, &media.CreatedAt)
 
 	if err != nil {
@@ -68,14 +80,14 @@ func (mr *MediaRepositoryImplement) CreateMedia(ctx context.Context, media *Medi
 // GetMediaByID
 func (mr *MediaRepositoryImplement) GetMediaByID(ctx context.Context, id uuid.UUID) (*Media, error) {
 	query := `
-		SELECT id, checkin_id, file_path, file_type, file_size, width, height, created_at
+		SELECT id, checkin_id, user_id, file_path, file_type, file_size, width, height, created_at
 		FROM media
 		WHERE id = $1
 	`
 
 	media := &Media{}
 	err := mr.pool.QueryRow(ctx, query, id).Scan(
-		&media.ID, &media.CheckinID, &media.FilePath, &media.FileType,
+		&media.ID, &media.CheckinID, &media.UserID, &media.FilePath, &media.FileType,
 		&media.FileSize, &media.Width, &media.Height, &media.CreatedAt,
 	)
 
@@ -105,3 +117,15 @@ func (mr *MediaRepositoryImplement) UpdateMedia(ctx context.Context, media *Medi
 
 	return nil
 }
+
+// DeleteMedia
+func (mr *MediaRepositoryImplement) DeleteMedia(ctx context.Context, id uuid.UUID) error {
+	query := `DELETE FROM media WHERE id = $1`
+
+	_, err := mr.pool.Exec(ctx, query, id)
+	if err != nil {
+		return fmt.Errorf("fail to delete media: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,136 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// NotificationTypeMention user is mentioned in a checkin or a remote Note
+const NotificationTypeMention = "mention"
+
+// Notification tell a local user about another actor's content
+type Notification struct {
+	ID     uuid.UUID `json:"id"`
+	UserID uuid.UUID `json:"user_id"`
+	Type   string    `json:"type"`
+	// ActorID actor whose content the notification is about, e.g. author of a mention
+	ActorID string `json:"actor_id"`
+	// ObjectID Note the notification is about
+	ObjectID string `json:"object_id"`
+	// CheckinID stored checkin of the Note, uuid.Nil when the Note isn't stored, e.g. a reply
+	CheckinID uuid.UUID `json:"checkin_id"`
+	Read      bool      `json:"read"`
+	CreatedAt time.Time `json:"created_at"`
+	// Actor local user or remote actor of ActorID
+	Actor *User `json:"actor,omitempty"`
+}
+
+// NotificationRepository manipulate notification data
+type NotificationRepository interface {
+	AddNotification(ctx context.Context, notification *Notification) error
+	GetNotifications(ctx context.Context, userID uuid.UUID, limit, offset int) ([]Notification, error)
+	MarkNotificationsRead(ctx context.Context, userID uuid.UUID) error
+}
+
+// NotificationRepositoryImplement
+type NotificationRepositoryImplement struct {
+	pool *pgxpool.Pool
+}
+
+// NewNotificationRepository
+func NewNotificationRepository(pool *pgxpool.Pool) NotificationRepository {
+	return &NotificationRepositoryImplement{pool: pool}
+}
+
+// AddNotification store notification, a notification of the same type about the same object is only stored once
+func (nr *NotificationRepositoryImplement) AddNotification(ctx context.Context, notification *Notification) error {
+	query := `
+		INSERT INTO notifications (user_id, type, actor_id, object_id, checkin_id)
+		VALUES ($1, $2, $3, $4, NULLIF($5, '00000000-0000-0000-0000-000000000000'::UUID))
+		ON CONFLICT (user_id, type, object_id) DO NOTHING
+	`
+
+	_, err := nr.pool.Exec(ctx, query,
+		notification.UserID, notification.Type, notification.ActorID, notification.ObjectID, notification.CheckinID,
+	)
+	if err != nil {
+		return fmt.Errorf("fail to add notification: %w", err)
+	}
+
+	return nil
+}
+
+// GetNotifications get user's notifications with their actor, newest first
+// notifications from actors user blocked or muted are left out
+func (nr *NotificationRepositoryImplement) GetNotifications(ctx context.Context, userID uuid.UUID, limit, offset int) ([]Notification, error) {
+	query := `
+		SELECT n.id, n.user_id, n.type, n.actor_id, n.object_id,
+			COALESCE(n.checkin_id, '00000000-0000-0000-0000-000000000000'::UUID), n.read, n.created_at,
+			COALESCE(u.id, '00000000-0000-0000-0000-000000000000'::UUID),
+			COALESCE(u.username, ra.username || '@' || ra.domain, ''), COALESCE(u.display_name, ra.display_name, ''),
+			COALESCE(u.avatar_url, ra.avatar_url, '')
+		FROM notifications n
+		LEFT JOIN users u ON u.actor_id = n.actor_id
+		LEFT JOIN remote_actors ra ON ra.actor_id = n.actor_id
+		WHERE n.user_id = $1
+			AND NOT EXISTS (` + actorHiddenFromUser("n.actor_id") + `)
+		ORDER BY n.created_at DESC
+		LIMIT $2 OFFSET $3
+	`
+
+	if limit <= 0 {
+		limit = 10
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	rows, err := nr.pool.Query(ctx, query, userID, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("fail to get notifications: %w", err)
+	}
+	defer rows.Close()
+
+	var notifications []Notification
+
+	for rows.Next() {
+		var notification Notification
+		var actor User
+		err := rows.Scan(
+			&notification.ID, &notification.UserID, &notification.Type, &notification.ActorID, &notification.ObjectID,
+			&notification.CheckinID, &notification.Read, &notification.CreatedAt,
+			&actor.ID, &actor.Username, &actor.DisplayName, &actor.AvatarURL,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("fail to scan notification: %w", err)
+		}
+
+		actor.ActorID = notification.ActorID
+		notification.Actor = &actor
+
+		notifications = append(notifications, notification)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("error on iterating notification rows: %w", err)
+	}
+
+	return notifications, nil
+}
+
+// MarkNotificationsRead mark all user's notifications as read
+func (nr *NotificationRepositoryImplement) MarkNotificationsRead(ctx context.Context, userID uuid.UUID) error {
+	query := `UPDATE notifications SET read = TRUE WHERE user_id = $1 AND read = FALSE`
+
+	_, err := nr.pool.Exec(ctx, query, userID)
+	if err != nil {
+		return fmt.Errorf("fail to mark notifications as read: %w", err)
+	}
+
+	return nil
+}
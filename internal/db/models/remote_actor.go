@@ -0,0 +1,194 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// RemoteActor cached actor of another server
+type RemoteActor struct {
+	ID          uuid.UUID `json:"id"`
+	ActorID     string    `json:"actor_id"`
+	Username    string    `json:"username"`
+	Domain      string    `json:"domain"`
+	DisplayName string    `json:"display_name,omitempty"`
+	AvatarURL   string    `json:"avatar_url,omitempty"`
+	Inbox       string    `json:"inbox"`
+	SharedInbox string    `json:"shared_inbox,omitempty"`
+	URL         string    `json:"url,omitempty"`
+	// Document fetched actor document as JSON, empty for actors stored before documents were cached
+	Document     []byte `json:"-"`
+	PublicKeyID  string `json:"public_key_id,omitempty"`
+	PublicKeyPem string `json:"public_key_pem,omitempty"`
+	// FetchedAt last time document was fetched, FetchAttemptedAt last time fetching was tried
+	FetchedAt        time.Time `json:"fetched_at"`
+	FetchAttemptedAt time.Time `json:"fetch_attempted_at"`
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
+
+// RemoteActorRepository manipulate remote actor data
+type RemoteActorRepository interface {
+	UpsertRemoteActor(ctx context.Context, actor *RemoteActor) error
+	GetByActorID(ctx context.Context, actorID string) (*RemoteActor, error)
+	GetByPublicKeyID(ctx context.Context, publicKeyID, domain string) (*RemoteActor, error)
+	GetStaleRemoteActors(ctx context.Context, before time.Time, limit int) ([]RemoteActor, error)
+	MarkFetchAttempted(ctx context.Context, actorID string) error
+}
+
+// RemoteActorRepositoryImplement
+type RemoteActorRepositoryImplement struct {
+	pool *pgxpool.Pool
+}
+
+// NewRemoteActorRepository
+func NewRemoteActorRepository(pool *pgxpool.Pool) RemoteActorRepository {
+	return &RemoteActorRepositoryImplement{pool: pool}
+}
+
+// remoteActorColumns columns selected for a RemoteActor, in scanRemoteActor order
+const remoteActorColumns = `id, actor_id, username, domain, COALESCE(display_name, ''), COALESCE(avatar_url, ''),
+	inbox, COALESCE(shared_inbox, ''), COALESCE(url, ''), COALESCE(document::STRING, ''),
+	COALESCE(public_key_id, ''), COALESCE(public_key_pem, ''), fetched_at, fetch_attempted_at, created_at, updated_at`
+
+// scanRemoteActor scan a row selected with remoteActorColumns
+func scanRemoteActor(row pgx.Row) (*RemoteActor, error) {
+	actor := &RemoteActor{}
+	var document string
+
+	err := row.Scan(
+		&actor.ID, &actor.ActorID, &actor.Username, &actor.Domain, &actor.DisplayName, &actor.AvatarURL,
+		&actor.Inbox, &actor.SharedInbox, &actor.URL, &document,
+		&actor.PublicKeyID, &actor.PublicKeyPem, &actor.FetchedAt, &actor.FetchAttemptedAt, &actor.CreatedAt, &actor.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if document != "" {
+		actor.Document = []byte(document)
+	}
+
+	return actor, nil
+}
+
+// UpsertRemoteActor insert remote actor or refresh it when it's already stored
+// the stored document is kept when actor doesn't carry one
+func (rar *RemoteActorRepositoryImplement) UpsertRemoteActor(ctx context.Context, actor *RemoteActor) error {
+	query := `
+		INSERT INTO remote_actors (
+			actor_id, username, domain, display_name, avatar_url, inbox, shared_inbox, url,
+			document, public_key_id, public_key_pem, fetched_at, fetch_attempted_at
+		) VALUES (
+			$1, $2, $3, NULLIF($4, ''), NULLIF($5, ''), $6, NULLIF($7, ''), NULLIF($8, ''),
+			NULLIF($9, '')::JSONB, NULLIF($10, ''), NULLIF($11, ''), now(), now()
+		)
+		ON CONFLICT (actor_id) DO UPDATE
+		SET username = excluded.username, domain = excluded.domain, display_name = excluded.display_name,
+			avatar_url = excluded.avatar_url, inbox = excluded.inbox, shared_inbox = excluded.shared_inbox,
+			url = excluded.url, document = COALESCE(excluded.document, remote_actors.document),
+			public_key_id = COALESCE(excluded.public_key_id, remote_actors.public_key_id),
+			public_key_pem = COALESCE(excluded.public_key_pem, remote_actors.public_key_pem),
+			fetched_at = now(), fetch_attempted_at = now(), updated_at = now()
+		RETURNING id, fetched_at, fetch_attempted_at, created_at, updated_at
+	`
+
+	err := rar.pool.QueryRow(ctx, query,
+		actor.ActorID, actor.Username, actor.Domain, actor.DisplayName, actor.AvatarURL,
+		actor.Inbox, actor.SharedInbox, actor.URL,
+		string(actor.Document), actor.PublicKeyID, actor.PublicKeyPem,
+	).Scan(&actor.ID, &actor.FetchedAt, &actor.FetchAttemptedAt, &actor.CreatedAt, &actor.UpdatedAt)
+
+	if err != nil {
+		return fmt.Errorf("fail to upsert remote actor: %w", err)
+	}
+
+	return nil
+}
+
+// GetByActorID
+func (rar *RemoteActorRepositoryImplement) GetByActorID(ctx context.Context, actorID string) (*RemoteActor, error) {
+	query := `SELECT ` + remoteActorColumns + ` FROM remote_actors WHERE actor_id = $1`
+
+	actor, err := scanRemoteActor(rar.pool.QueryRow(ctx, query, actorID))
+	if err != nil {
+		return nil, fmt.Errorf("fail to get remote actor by actor id: %w", err)
+	}
+
+	return actor, nil
+}
+
+// GetByPublicKeyID get remote actor of domain whose document has the signing key
+// domain is the key's host, actors of other servers can't claim the key
+func (rar *RemoteActorRepositoryImplement) GetByPublicKeyID(ctx context.Context, publicKeyID, domain string) (*RemoteActor, error) {
+	query := `
+		SELECT ` + remoteActorColumns + `
+		FROM remote_actors
+		WHERE public_key_id = $1 AND lower(domain) = lower($2)
+		ORDER BY fetched_at DESC
+		LIMIT 1
+	`
+
+	actor, err := scanRemoteActor(rar.pool.QueryRow(ctx, query, publicKeyID, domain))
+	if err != nil {
+		return nil, fmt.Errorf("fail to get remote actor by public key id: %w", err)
+	}
+
+	return actor, nil
+}
+
+// GetStaleRemoteActors get actors fetched and last tried before the given time, least recently fetched first
+func (rar *RemoteActorRepositoryImplement) GetStaleRemoteActors(ctx context.Context, before time.Time, limit int) ([]RemoteActor, error) {
+	query := `
+		SELECT ` + remoteActorColumns + `
+		FROM remote_actors
+		WHERE fetched_at < $1 AND fetch_attempted_at < $1
+		ORDER BY fetched_at
+		LIMIT $2
+	`
+
+	if limit <= 0 {
+		limit = 10
+	}
+
+	rows, err := rar.pool.Query(ctx, query, before, limit)
+	if err != nil {
+		return nil, fmt.Errorf("fail to get stale remote actors: %w", err)
+	}
+	defer rows.Close()
+
+	var actors []RemoteActor
+
+	for rows.Next() {
+		actor, err := scanRemoteActor(rows)
+		if err != nil {
+			return nil, fmt.Errorf("fail to scan remote actor: %w", err)
+		}
+
+		actors = append(actors, *actor)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("error on iterating remote actor rows: %w", err)
+	}
+
+	return actors, nil
+}
+
+// MarkFetchAttempted record a failed fetch, so the actor isn't fetched again right away
+func (rar *RemoteActorRepositoryImplement) MarkFetchAttempted(ctx context.Context, actorID string) error {
+	query := `UPDATE remote_actors SET fetch_attempted_at = now() WHERE actor_id = $1`
+
+	_, err := rar.pool.Exec(ctx, query, actorID)
+	if err != nil {
+		return fmt.Errorf("fail to mark remote actor fetch attempt: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,165 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Share boost (Announce) of a local user or a remote actor on a checkin
+type Share struct {
+	ID        uuid.UUID `json:"id"`
+	CheckinID uuid.UUID `json:"checkin_id"`
+	// UserID local user who boosts the checkin, uuid.Nil when booster is a remote actor
+	UserID     uuid.UUID `json:"user_id"`
+	ActorID    string    `json:"actor_id"`
+	ObjectID   string    `json:"object_id"`
+	ActivityID string    `json:"activity_id"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// ShareRepository manipulate share data
+type ShareRepository interface {
+	AddShare(ctx context.Context, share *Share) error
+	RemoveShare(ctx context.Context, checkinID uuid.UUID, actorID string) error
+	RemoveShareByActivityID(ctx context.Context, activityID, actorID string) error
+	GetShare(ctx context.Context, checkinID uuid.UUID, actorID string) (*Share, error)
+	GetSharesByCheckinID(ctx context.Context, checkinID uuid.UUID, cursor uuid.UUID, limit int) ([]Share, error)
+}
+
+// ShareRepositoryImplement
+type ShareRepositoryImplement struct {
+	pool *pgxpool.Pool
+}
+
+// NewShareRepository
+func NewShareRepository(pool *pgxpool.Pool) ShareRepository {
+	return &ShareRepositoryImplement{pool: pool}
+}
+
+// shareColumns columns selected for a Share, in scanShare order
+const shareColumns = `id, checkin_id, COALESCE(user_id, '00000000-0000-0000-0000-000000000000'::UUID), actor_id, object_id, activity_id, created_at`
+
+// scanShare scan a row selected with shareColumns
+func scanShare(row pgx.Row) (*Share, error) {
+	share := &Share{}
+
+	err := row.Scan(
+		&share.ID, &share.CheckinID, &share.UserID, &share.ActorID,
+		&share.ObjectID, &share.ActivityID, &share.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return share, nil
+}
+
+// AddShare store share, an actor boosts a checkin only once
+// when actor already boosts the checkin, share is filled with the stored one
+func (sr *ShareRepositoryImplement) AddShare(ctx context.Context, share *Share) error {
+	query := `
+		INSERT INTO shares (checkin_id, user_id, actor_id, object_id, activity_id)
+		VALUES ($1, NULLIF($2, '00000000-0000-0000-0000-000000000000'::UUID), $3, $4, $5)
+		ON CONFLICT DO NOTHING
+		RETURNING id, created_at
+	`
+
+	err := sr.pool.QueryRow(ctx, query,
+		share.CheckinID, share.UserID, share.ActorID, share.ObjectID, share.ActivityID,
+	).Scan(&share.ID, &share.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		existing, err := sr.GetShare(ctx, share.CheckinID, share.ActorID)
+		if err != nil {
+			return err
+		}
+
+		*share = *existing
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("fail to add share: %w", err)
+	}
+
+	return nil
+}
+
+// RemoveShare
+func (sr *ShareRepositoryImplement) RemoveShare(ctx context.Context, checkinID uuid.UUID, actorID string) error {
+	query := `DELETE FROM shares WHERE checkin_id = $1 AND actor_id = $2`
+
+	_, err := sr.pool.Exec(ctx, query, checkinID, actorID)
+	if err != nil {
+		return fmt.Errorf("fail to remove share: %w", err)
+	}
+
+	return nil
+}
+
+// RemoveShareByActivityID remove share by its Announce activity, only the actor who sent it can remove it
+func (sr *ShareRepositoryImplement) RemoveShareByActivityID(ctx context.Context, activityID, actorID string) error {
+	query := `DELETE FROM shares WHERE activity_id = $1 AND actor_id = $2`
+
+	_, err := sr.pool.Exec(ctx, query, activityID, actorID)
+	if err != nil {
+		return fmt.Errorf("fail to remove share by activity ID: %w", err)
+	}
+
+	return nil
+}
+
+// GetShare get share of an actor on a checkin
+func (sr *ShareRepositoryImplement) GetShare(ctx context.Context, checkinID uuid.UUID, actorID string) (*Share, error) {
+	query := `SELECT ` + shareColumns + ` FROM shares WHERE checkin_id = $1 AND actor_id = $2`
+
+	share, err := scanShare(sr.pool.QueryRow(ctx, query, checkinID, actorID))
+	if err != nil {
+		return nil, fmt.Errorf("fail to get share: %w", err)
+	}
+
+	return share, nil
+}
+
+// GetSharesByCheckinID get shares of a checkin after cursor, ordered by id
+func (sr *ShareRepositoryImplement) GetSharesByCheckinID(ctx context.Context, checkinID uuid.UUID, cursor uuid.UUID, limit int) ([]Share, error) {
+	query := `
+		SELECT ` + shareColumns + `
+		FROM shares
+		WHERE checkin_id = $1 AND id > $2
+		ORDER BY id
+		LIMIT $3
+	`
+
+	if limit <= 0 {
+		limit = 10
+	}
+
+	rows, err := sr.pool.Query(ctx, query, checkinID, cursor, limit)
+	if err != nil {
+		return nil, fmt.Errorf("fail to get shares: %w", err)
+	}
+	defer rows.Close()
+
+	var shares []Share
+
+	for rows.Next() {
+		share, err := scanShare(rows)
+		if err != nil {
+			return nil, fmt.Errorf("fail to scan share: %w", err)
+		}
+
+		shares = append(shares, *share)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("error on iterating share rows: %w", err)
+	}
+
+	return shares, nil
+}
@@ -0,0 +1,15 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Tombstone trace of a deleted local object, its object ID answers 410 Gone
+type Tombstone struct {
+	ID         uuid.UUID `json:"id"`
+	ObjectID   string    `json:"object_id"`
+	FormerType string    `json:"former_type"`
+	DeletedAt  time.Time `json:"deleted_at"`
+}
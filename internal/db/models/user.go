@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"time"
 )
@@ -18,8 +19,18 @@ type User struct {
 	ActorID      string    `json:"actor_id"`
 	PrivateKey   string    `json:"-"`
 	PublicKey    string    `json:"public_key,omitempty"`
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
+	// HideSocialGraph only publish totalItems of followers and following collections
+	HideSocialGraph bool `json:"hide_social_graph"`
+	// Locked followers need user's approval
+	Locked bool `json:"locked"`
+	// IsAdmin user can moderate the instance, it's only set in database
+	IsAdmin bool `json:"is_admin"`
+	// AlsoKnownAs actor IDs of user's other accounts
+	AlsoKnownAs []string `json:"also_known_as"`
+	// MovedTo actor ID of the account user moved to
+	MovedTo   string    `json:"moved_to,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
 }
 
 // UserRepository manipulate user data
@@ -29,8 +40,30 @@ type UserRepository interface {
 	GetByUsername(ctx context.Context, username string) (*User, error)
 	GetByEmail(ctx context.Context, email string) (*User, error)
 	GetByActorID(ctx context.Context, actorID string) (*User, error)
+	GetByPreviousUsername(ctx context.Context, username string) (*User, error)
 	UpdateUser(ctx context.Context, user *User) error
 	DeleteUser(ctx context.Context, id uuid.UUID) error
+	CountUsers(ctx context.Context) (int, error)
+	CountActiveUsers(ctx context.Context, since time.Time) (int, error)
+}
+
+// userColumns columns selected for a User, in scanUser order
+const userColumns = `id, username, display_name, email, password_hash, avatar_url, actor_id,
+	private_key, public_key, hide_social_graph, locked, is_admin, also_known_as, COALESCE(moved_to, ''), created_at, updated_at`
+
+// scanUser scan a row selected with userColumns
+func scanUser(row pgx.Row) (*User, error) {
+	user := &User{}
+
+	err := row.Scan(
+		&user.ID, &user.Username, &user.DisplayName, &user.Email, &user.PasswordHash, &user.AvatarURL, &user.ActorID,
+		&user.PrivateKey, &user.PublicKey, &user.HideSocialGraph, &user.Locked, &user.IsAdmin, &user.AlsoKnownAs, &user.MovedTo, &user.CreatedAt, &user.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
 }
 
 // UserRepositoryImplement implement functions in user repository interface
@@ -43,17 +76,23 @@ func NewUserRepository(pool *pgxpool.Pool) UserRepository {
 	return &UserRepositoryImplement{pool: pool}
 }
 
+// CreateUser store user, user.ID is generated when it's not set
+// actor ID is built from user ID, so it can be set before storing user
 func (ur *UserRepositoryImplement) CreateUser(ctx context.Context, user *User) error {
 	query := `
 		INSERT INTO users (
-			username, display_name, email, password_hash, avatar_url, actor_id, private_key, public_key
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-		RETURNING id, created_at, updated_at
+			id, username, display_name, email, password_hash, avatar_url, actor_id, private_key, public_key
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING created_at, updated_at
 	`
 
+	if user.ID == uuid.Nil {
+		user.ID = uuid.New()
+	}
+
 	err := ur.pool.QueryRow(ctx, query,
-		user.Username, user.DisplayName, user.Email, user.PasswordHash, user.AvatarURL, user.ActorID, user.PrivateKey, user.PublicKey,
-	).Scan(&user.ID, &user.CreatedAt, &user.UpdatedAt)
+		user.ID, user.Username, user.DisplayName, user.Email, user.PasswordHash, user.AvatarURL, user.ActorID, user.PrivateKey, user.PublicKey,
+	).Scan(&user.CreatedAt, &user.UpdatedAt)
 
 	if err != nil {
 		return fmt.Errorf("fail to create user: %w", err)
@@ -63,42 +102,21 @@ func (ur *UserRepositoryImplement) CreateUser(ctx context.Context, user *User) e
 }
 
 func (ur *UserRepositoryImplement) GetByID(ctx context.Context, id uuid.UUID) (*User, error) {
-	user := &User{}
-	query := `
-		SELECT
-			id, username, display_name, email, password_hash, avatar_url, actor_id, private_key, public_key, created_at, updated_at
-		FROM users
-		WHERE id = $1
-	`
+	query := `SELECT ` + userColumns + ` FROM users WHERE id = $1`
 
-	err := ur.pool.QueryRow(ctx, query, id).Scan(
-		&user.ID, &user.Username, &user.DisplayName, &user.Email, &user.PasswordHash, &user.AvatarURL, &user.ActorID,
-		&user.PrivateKey, &user.PublicKey, &user.CreatedAt, &user.UpdatedAt,
-	)
+	user, err := scanUser(ur.pool.QueryRow(ctx, query, id))
 	if err != nil {
 		return nil, fmt.Errorf("fail to get user by id: %w", err)
 	}
 	// TODO: add handling user not found error
-	//if err.Error() == "no rows in result set" {
-	//	return nil, fmt.Errorf("user not found: %w", err)
-	//}
 
 	return user, nil
 }
 
 func (ur *UserRepositoryImplement) GetByUsername(ctx context.Context, username string) (*User, error) {
-	user := &User{}
-	query := `
-    SELECT
-        id, username, display_name, email, password_hash, avatar_url, actor_id, private_key, public_key, created_at, updated_at
-    FROM users
-    WHERE username = $1
-`
+	query := `SELECT ` + userColumns + ` FROM users WHERE username = $1`
 
-	err := ur.pool.QueryRow(ctx, query, username).Scan(
-		&user.ID, &user.Username, &user.DisplayName, &user.Email, &user.PasswordHash, &user.AvatarURL, &user.ActorID,
-		&user.PrivateKey, &user.PublicKey, &user.CreatedAt, &user.UpdatedAt,
-	)
+	user, err := scanUser(ur.pool.QueryRow(ctx, query, username))
 	if err != nil {
 		return nil, fmt.Errorf("fail to get user by username: %w", err)
 	}
@@ -108,18 +126,9 @@ func (ur *UserRepositoryImplement) GetByUsername(ctx context.Context, username s
 }
 
 func (ur *UserRepositoryImplement) GetByEmail(ctx context.Context, email string) (*User, error) {
-	user := &User{}
-	query := `
-	SELECT
-		id, username, display_name, email, password_hash, avatar_url, actor_id, private_key, public_key, created_at, updated_at
-	FROM users
-	WHERE email = $1
-`
+	query := `SELECT ` + userColumns + ` FROM users WHERE email = $1`
 
-	err := ur.pool.QueryRow(ctx, query, email).Scan(
-		&user.ID, &user.Username, &user.DisplayName, &user.Email, &user.PasswordHash, &user.AvatarURL, &user.ActorID,
-		&user.PrivateKey, &user.PublicKey, &user.CreatedAt, &user.UpdatedAt,
-	)
+	user, err := scanUser(ur.pool.QueryRow(ctx, query, email))
 	if err != nil {
 		return nil, fmt.Errorf("fail to get user by email: %w", err)
 	}
@@ -129,19 +138,9 @@ func (ur *UserRepositoryImplement) GetByEmail(ctx context.Context, email string)
 }
 
 func (ur *UserRepositoryImplement) GetByActorID(ctx context.Context, actorID string) (*User, error) {
-	user := &User{}
-	query := `
-		SELECT id, username, display_name, email, password_hash, avatar_url, actor_id,
-			private_key, public_key, created_at, updated_at
-		FROM users
-		WHERE actor_id = $1
-	`
-
-	err := ur.pool.QueryRow(ctx, query, actorID).Scan(
-		&user.ID, &user.Username, &user.DisplayName, &user.Email, &user.PasswordHash, &user.AvatarURL, &user.ActorID,
-		&user.PrivateKey, &user.PublicKey, &user.CreatedAt, &user.UpdatedAt,
-	)
+	query := `SELECT ` + userColumns + ` FROM users WHERE actor_id = $1`
 
+	user, err := scanUser(ur.pool.QueryRow(ctx, query, actorID))
 	if err != nil {
 		return nil, fmt.Errorf("fail to get user by actor id: %w", err)
 	}
@@ -150,25 +149,83 @@ func (ur *UserRepositoryImplement) GetByActorID(ctx context.Context, actorID str
 	return user, nil
 }
 
-// UpdateUser
+// GetByPreviousUsername get user who used username before renaming
+func (ur *UserRepositoryImplement) GetByPreviousUsername(ctx context.Context, username string) (*User, error) {
+	query := `
+		SELECT ` + userColumns + ` FROM users
+		WHERE id = (SELECT user_id FROM username_history WHERE username = $1)
+	`
+
+	user, err := scanUser(ur.pool.QueryRow(ctx, query, username))
+	if err != nil {
+		return nil, fmt.Errorf("fail to get user by previous username: %w", err)
+	}
+
+	return user, nil
+}
+
+// UpdateUser update user, when username changes the previous one is kept in username history
+// actor ID never changes
 func (ur *UserRepositoryImplement) UpdateUser(ctx context.Context, user *User) error {
+	tx, err := ur.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("fail to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var previousUsername string
+	err = tx.QueryRow(ctx, `SELECT username FROM users WHERE id = $1 FOR UPDATE`, user.ID).Scan(&previousUsername)
+	if err != nil {
+		return fmt.Errorf("fail to get user: %w", err)
+	}
+
+	if previousUsername != user.Username {
+		// user can take back one of their previous usernames
+		_, err = tx.Exec(ctx, `DELETE FROM username_history WHERE username = $1 AND user_id = $2`, user.Username, user.ID)
+		if err != nil {
+			return fmt.Errorf("fail to remove username history: %w", err)
+		}
+
+		query := `
+			INSERT INTO username_history (user_id, username)
+			VALUES ($1, $2)
+			ON CONFLICT (username) DO NOTHING
+		`
+
+		_, err = tx.Exec(ctx, query, user.ID, previousUsername)
+		if err != nil {
+			return fmt.Errorf("fail to add username history: %w", err)
+		}
+	}
+
 	query := `
 		UPDATE users
-		SET username = $1, display_name = $2, email = $3, avatar_url = $4, actor_id = $5,
-		    private_key = $6, public_key = $7, updated_at = now()
-		WHERE id = $8
+		SET username = $1, display_name = $2, email = $3, avatar_url = $4,
+		    private_key = $5, public_key = $6, hide_social_graph = $7, locked = $8,
+		    also_known_as = $9, moved_to = NULLIF($10, ''), updated_at = now()
+		WHERE id = $11
 		RETURNING updated_at
 	`
 
-	err := ur.pool.QueryRow(ctx, query,
-		user.Username, user.DisplayName, user.Email, user.AvatarURL, user.ActorID,
-		user.PrivateKey, user.PublicKey, user.ID,
-	).Scan(&user.UpdatedAt)
+	alsoKnownAs := user.AlsoKnownAs
+	if alsoKnownAs == nil {
+		alsoKnownAs = []string{}
+	}
 
+	err = tx.QueryRow(ctx, query,
+		user.Username, user.DisplayName, user.Email, user.AvatarURL,
+		user.PrivateKey, user.PublicKey, user.HideSocialGraph, user.Locked,
+		alsoKnownAs, user.MovedTo, user.ID,
+	).Scan(&user.UpdatedAt)
 	if err != nil {
 		return fmt.Errorf("fail to update user: %w", err)
 	}
 
+	err = tx.Commit(ctx)
+	if err != nil {
+		return fmt.Errorf("fail to commit user update: %w", err)
+	}
+
 	return nil
 }
 
@@ -183,3 +240,33 @@ func (ur *UserRepositoryImplement) DeleteUser(ctx context.Context, id uuid.UUID)
 
 	return nil
 }
+
+// CountUsers count all local users
+func (ur *UserRepositoryImplement) CountUsers(ctx context.Context) (int, error) {
+	query := `SELECT count(*) FROM users`
+
+	var count int
+	err := ur.pool.QueryRow(ctx, query).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("fail to count users: %w", err)
+	}
+
+	return count, nil
+}
+
+// CountActiveUsers count users who have posted a checkin since the given time
+func (ur *UserRepositoryImplement) CountActiveUsers(ctx context.Context, since time.Time) (int, error) {
+	query := `
+		SELECT count(DISTINCT user_id)
+		FROM checkins
+		WHERE created_at >= $1
+	`
+
+	var count int
+	err := ur.pool.QueryRow(ctx, query, since).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("fail to count active users: %w", err)
+	}
+
+	return count, nil
+}
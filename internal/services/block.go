@@ -0,0 +1,206 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"je-suis-ici-activitypub/internal/activitypub"
+	"je-suis-ici-activitypub/internal/db/models"
+
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrInvalidBlockTarget account is user's own account
+	ErrInvalidBlockTarget = errors.New("user can't block or mute their own account")
+	// ErrNotBlocked user doesn't block the account
+	ErrNotBlocked = errors.New("not blocking this account")
+)
+
+// BlockService let local users block and mute accounts
+// a block is sent to the blocked actor's server, a mute only hides content locally
+type BlockService interface {
+	Block(ctx context.Context, userID uuid.UUID, account string) (*models.Block, error)
+	Unblock(ctx context.Context, userID uuid.UUID, account string) error
+	ListBlocks(ctx context.Context, userID uuid.UUID, cursor uuid.UUID, limit int) ([]models.Block, error)
+	Mute(ctx context.Context, userID uuid.UUID, account string) (*models.Mute, error)
+	Unmute(ctx context.Context, userID uuid.UUID, account string) error
+	ListMutes(ctx context.Context, userID uuid.UUID, cursor uuid.UUID, limit int) ([]models.Mute, error)
+}
+
+// BlockServiceImplement
+type BlockServiceImplement struct {
+	userRepo        models.UserRepository
+	blockRepo       models.BlockRepository
+	clientService   activitypub.ActivityPubClientService
+	actorCache      activitypub.RemoteActorCache
+	apServerService *activitypub.ActivityPubServerService
+	baseURL         string
+}
+
+// NewBlockService
+func NewBlockService(userRepo models.UserRepository, blockRepo models.BlockRepository, clientService activitypub.ActivityPubClientService, actorCache activitypub.RemoteActorCache, apServerService *activitypub.ActivityPubServerService, baseURL string) BlockService {
+	return &BlockServiceImplement{
+		userRepo:        userRepo,
+		blockRepo:       blockRepo,
+		clientService:   clientService,
+		actorCache:      actorCache,
+		apServerService: apServerService,
+		baseURL:         baseURL,
+	}
+}
+
+// Block block account, follows between user and account are removed in both directions and Block is sent to it
+// account is "user@host" or an actor URL, an actor URL is blocked even when its server can't be reached
+func (bs *BlockServiceImplement) Block(ctx context.Context, userID uuid.UUID, account string) (*models.Block, error) {
+	user, err := bs.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	targetActorID, targetInbox, err := bs.getActorInbox(ctx, account)
+	if err != nil {
+		return nil, err
+	}
+
+	if targetActorID == user.ActorID {
+		return nil, ErrInvalidBlockTarget
+	}
+
+	// already blocked
+	existing, err := bs.blockRepo.GetBlock(ctx, userID, targetActorID)
+	if err == nil {
+		return existing, nil
+	}
+
+	block := &models.Block{
+		UserID:        userID,
+		TargetActorID: targetActorID,
+		ActivityID:    activitypub.NewActivityID(bs.baseURL),
+	}
+
+	// store block first, Follows arriving meanwhile are rejected
+	err = bs.blockRepo.AddBlock(ctx, block)
+	if err != nil {
+		return nil, err
+	}
+
+	err = bs.apServerService.BlockActor(ctx, user, block, targetInbox)
+	if err != nil {
+		return nil, fmt.Errorf("fail to send block: %w", err)
+	}
+
+	return block, nil
+}
+
+// Unblock remove block and send Undo{Block}, follows removed by the block aren't restored
+func (bs *BlockServiceImplement) Unblock(ctx context.Context, userID uuid.UUID, account string) error {
+	user, err := bs.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	targetActorID, targetInbox, err := bs.getActorInbox(ctx, account)
+	if err != nil {
+		return err
+	}
+
+	block, err := bs.blockRepo.GetBlock(ctx, userID, targetActorID)
+	if err != nil {
+		return ErrNotBlocked
+	}
+
+	err = bs.blockRepo.RemoveBlock(ctx, userID, targetActorID)
+	if err != nil {
+		return err
+	}
+
+	err = bs.apServerService.UnblockActor(ctx, user, block, targetInbox)
+	if err != nil {
+		return fmt.Errorf("fail to send undo block: %w", err)
+	}
+
+	return nil
+}
+
+// ListBlocks list user's blocks after cursor
+func (bs *BlockServiceImplement) ListBlocks(ctx context.Context, userID uuid.UUID, cursor uuid.UUID, limit int) ([]models.Block, error) {
+	return bs.blockRepo.GetBlocks(ctx, userID, cursor, limit)
+}
+
+// Mute hide account's checkins and boosts from user, nothing is sent to account's server
+func (bs *BlockServiceImplement) Mute(ctx context.Context, userID uuid.UUID, account string) (*models.Mute, error) {
+	user, err := bs.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	targetActorID, err := bs.getActorID(ctx, account)
+	if err != nil {
+		return nil, err
+	}
+
+	if targetActorID == user.ActorID {
+		return nil, ErrInvalidBlockTarget
+	}
+
+	mute := &models.Mute{
+		UserID:        userID,
+		TargetActorID: targetActorID,
+	}
+
+	err = bs.blockRepo.AddMute(ctx, mute)
+	if err != nil {
+		return nil, err
+	}
+
+	return mute, nil
+}
+
+// Unmute
+func (bs *BlockServiceImplement) Unmute(ctx context.Context, userID uuid.UUID, account string) error {
+	targetActorID, err := bs.getActorID(ctx, account)
+	if err != nil {
+		return err
+	}
+
+	return bs.blockRepo.RemoveMute(ctx, userID, targetActorID)
+}
+
+// ListMutes list user's mutes after cursor
+func (bs *BlockServiceImplement) ListMutes(ctx context.Context, userID uuid.UUID, cursor uuid.UUID, limit int) ([]models.Mute, error) {
+	return bs.blockRepo.GetMutes(ctx, userID, cursor, limit)
+}
+
+// getActorID get actor ID of account, it's only resolved with WebFinger when account is "user@host"
+func (bs *BlockServiceImplement) getActorID(ctx context.Context, account string) (string, error) {
+	if isActorURL(account) {
+		return account, nil
+	}
+
+	target, err := bs.clientService.ResolveAccount(ctx, account)
+	if err != nil {
+		return "", fmt.Errorf("fail to resolve account: %w", err)
+	}
+
+	return target.ID, nil
+}
+
+// getActorInbox get actor ID and inbox of account without fetching an actor given by URL
+// inbox of an actor URL comes from remote actor cache, it's empty when actor isn't cached
+func (bs *BlockServiceImplement) getActorInbox(ctx context.Context, account string) (string, string, error) {
+	if isActorURL(account) {
+		remoteActor, err := bs.actorCache.GetCachedActor(ctx, account)
+		if err != nil {
+			return account, "", nil
+		}
+		return account, remoteActor.Inbox, nil
+	}
+
+	target, err := bs.clientService.ResolveAccount(ctx, account)
+	if err != nil {
+		return "", "", fmt.Errorf("fail to resolve account: %w", err)
+	}
+
+	return target.ID, target.Inbox, nil
+}
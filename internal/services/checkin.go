@@ -2,41 +2,71 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"je-suis-ici-activitypub/internal/activitypub"
 	"je-suis-ici-activitypub/internal/db/models"
 	"je-suis-ici-activitypub/internal/storage"
+	"time"
 
 	"github.com/google/uuid"
 )
 
+// ErrNotCheckinOwner user isn't author of the checkin
+var ErrNotCheckinOwner = errors.New("user is not author of the checkin")
+
+// ErrInvalidMedia media of checkin edit doesn't exist, isn't the user's upload or belongs to another checkin
+var ErrInvalidMedia = errors.New("media is not an upload of the user")
+
+// CheckinUpdate edit of a checkin, nil fields are unchanged
+// MediaIDs is the full media list after edit, media left out are deleted
+type CheckinUpdate struct {
+	Content      *string      `json:"content"`
+	LocationName *string      `json:"location_name"`
+	Latitude     *float64     `json:"latitude"`
+	Longitude    *float64     `json:"longitude"`
+	MediaIDs     *[]uuid.UUID `json:"media_ids"`
+}
+
 // CheckinService
 type CheckinService interface {
-	CreateCheckin(ctx context.Context, userID uuid.UUID, content, locationName string, latitude, longitude float64, mediaIDs []uuid.UUID, serverHost string) (*models.Checkin, error)
+	CreateCheckin(ctx context.Context, userID uuid.UUID, content, locationName string, latitude, longitude float64, mediaIDs []uuid.UUID, baseURL string) (*models.Checkin, error)
 	GetCheckinByID(ctx context.Context, id uuid.UUID) (*models.Checkin, error)
+	GetCheckinByActivityID(ctx context.Context, activityID string) (*models.Checkin, error)
 	GetCheckinsByUserID(ctx context.Context, userID uuid.UUID, page, pageSize int) ([]models.Checkin, error)
-	GetGlobalFeed(ctx context.Context, page, pageSize int) ([]models.Checkin, error)
+	CountCheckinsByUserID(ctx context.Context, userID uuid.UUID) (int, error)
+	GetGlobalFeed(ctx context.Context, viewerID uuid.UUID, page, pageSize int) ([]models.Checkin, error)
+	GetHomeFeed(ctx context.Context, userID uuid.UUID, page, pageSize int) ([]models.Checkin, error)
+	GetCheckinsByTag(ctx context.Context, viewerID uuid.UUID, tag string, page, pageSize int) ([]models.Checkin, error)
+	CountLocalCheckins(ctx context.Context) (int, error)
+	UpdateCheckin(ctx context.Context, userID, id uuid.UUID, update CheckinUpdate) (*models.Checkin, error)
+	GetCheckinEdits(ctx context.Context, id uuid.UUID) ([]models.CheckinEdit, error)
+	DeleteCheckin(ctx context.Context, userID, id uuid.UUID, baseURL string) (*models.Checkin, error)
+	GetTombstone(ctx context.Context, objectID string) (*models.Tombstone, error)
 }
 
 // CheckinServiceImplement
 type CheckinServiceImplement struct {
-	checkinRepo  models.CheckinRepository
-	mediaRepo    models.MediaRepository
-	minioService storage.MinioService
+	checkinRepo     models.CheckinRepository
+	mediaRepo       models.MediaRepository
+	minioService    storage.MinioService
+	apServerService *activitypub.ActivityPubServerService
 }
 
 // NewCheckinService
-func NewCheckinService(checkinRepo models.CheckinRepository, mediaRepo models.MediaRepository, minioService storage.MinioService) CheckinService {
+func NewCheckinService(checkinRepo models.CheckinRepository, mediaRepo models.MediaRepository, minioService storage.MinioService, apServerService *activitypub.ActivityPubServerService) CheckinService {
 	return &CheckinServiceImplement{
-		checkinRepo:  checkinRepo,
-		mediaRepo:    mediaRepo,
-		minioService: minioService,
+		checkinRepo:     checkinRepo,
+		mediaRepo:       mediaRepo,
+		minioService:    minioService,
+		apServerService: apServerService,
 	}
 }
 
-// CreateCheckin
-func (cs *CheckinServiceImplement) CreateCheckin(ctx context.Context, userID uuid.UUID, content, locationName string, latitude, longitude float64, mediaIDs []uuid.UUID, serverHost string) (*models.Checkin, error) {
+// CreateCheckin store checkin with hashtags and mentions of its content, mentions are resolved with WebFinger
+func (cs *CheckinServiceImplement) CreateCheckin(ctx context.Context, userID uuid.UUID, content, locationName string, latitude, longitude float64, mediaIDs []uuid.UUID, baseURL string) (*models.Checkin, error) {
 	// generate ActivityPub activities ID
-	activityID := fmt.Sprintf("https://%s/activities/%s", serverHost, uuid.New().String())
+	activityID := activitypub.NewActivityID(baseURL)
 
 	// build checkin model
 	checkin := &models.Checkin{
@@ -46,6 +76,8 @@ func (cs *CheckinServiceImplement) CreateCheckin(ctx context.Context, userID uui
 		Latitude:     latitude,
 		Longitude:    longitude,
 		ActivityID:   activityID,
+		Tags:         activitypub.ParseHashtags(content),
+		Mentions:     cs.apServerService.ResolveMentions(ctx, content),
 	}
 
 	// store checkin
@@ -80,7 +112,7 @@ func (cs *CheckinServiceImplement) CreateCheckin(ctx context.Context, userID uui
 	}
 
 	// get full checkin data
-	fullCheckin, err := cs.checkinRepo.GetCheckinByID(ctx, checkin.ID)
+	fullCheckin, err := cs.GetCheckinByID(ctx, checkin.ID)
 	if err != nil {
 		return checkin, nil
 	}
@@ -96,13 +128,21 @@ func (cs *CheckinServiceImplement) GetCheckinByID(ctx context.Context, id uuid.U
 	}
 
 	// generate media file URL
-	for i := range checkin.Media {
-		url, err := cs.minioService.GetFileURL(ctx, checkin.Media[i].FilePath)
-		if err == nil {
-			checkin.Media[i].URL = url
-		}
+	cs.setMediaURLs(ctx, checkin.Media)
+
+	return checkin, nil
+}
+
+// GetCheckinByActivityID
+func (cs *CheckinServiceImplement) GetCheckinByActivityID(ctx context.Context, activityID string) (*models.Checkin, error) {
+	checkin, err := cs.checkinRepo.GetCheckinByActivityID(ctx, activityID)
+	if err != nil {
+		return nil, fmt.Errorf("fail to get checkin: %w", err)
 	}
 
+	// generate media file URL
+	cs.setMediaURLs(ctx, checkin.Media)
+
 	return checkin, nil
 }
 
@@ -119,38 +159,179 @@ func (cs *CheckinServiceImplement) GetCheckinsByUserID(ctx context.Context, user
 
 	// generate media file URL for each checkin
 	for i := range checkins {
-		for j := range checkins[i].Media {
-			url, err := cs.minioService.GetFileURL(ctx, checkins[i].Media[j].FilePath)
-			if err == nil {
-				checkins[i].Media[j].URL = url
-			}
-		}
+		cs.setMediaURLs(ctx, checkins[i].Media)
 	}
 
 	return checkins, nil
 }
 
-// GetGlobalFeed
-// TODO: get global feed from other sites based on ActivityPub Protocol
-func (cs *CheckinServiceImplement) GetGlobalFeed(ctx context.Context, page, pageSize int) ([]models.Checkin, error) {
+// CountCheckinsByUserID
+func (cs *CheckinServiceImplement) CountCheckinsByUserID(ctx context.Context, userID uuid.UUID) (int, error) {
+	return cs.checkinRepo.CountCheckinsByUserID(ctx, userID)
+}
+
+// GetGlobalFeed get local checkins and remote checkins received from followed actors
+// checkins of actors viewer blocked or muted are left out, viewerID is uuid.Nil for anonymous viewers
+func (cs *CheckinServiceImplement) GetGlobalFeed(ctx context.Context, viewerID uuid.UUID, page, pageSize int) ([]models.Checkin, error) {
 	// calculate offset
 	offset := (page - 1) * pageSize
 
 	// get global feed from db
-	checkins, err := cs.checkinRepo.GetGlobalFeed(ctx, pageSize, offset)
+	checkins, err := cs.checkinRepo.GetGlobalFeed(ctx, viewerID, pageSize, offset)
 	if err != nil {
 		return nil, fmt.Errorf("fail to get global feed: %w", err)
 	}
 
-	// generate media URL for each checkin
+	// generate media file URL for each checkin
 	for i := range checkins {
-		for j := range checkins[i].Media {
-			url, err := cs.minioService.GetFileURL(ctx, checkins[i].Media[j].FilePath)
-			if err == nil {
-				checkins[i].Media[j].URL = url
-			}
-		}
+		cs.setMediaURLs(ctx, checkins[i].Media)
+	}
+
+	return checkins, nil
+}
+
+// GetHomeFeed get checkins of user and followed actors, including checkins they boost
+func (cs *CheckinServiceImplement) GetHomeFeed(ctx context.Context, userID uuid.UUID, page, pageSize int) ([]models.Checkin, error) {
+	// calculate offset
+	offset := (page - 1) * pageSize
+
+	checkins, err := cs.checkinRepo.GetHomeFeed(ctx, userID, pageSize, offset)
+	if err != nil {
+		return nil, fmt.Errorf("fail to get home feed: %w", err)
+	}
+
+	// generate media file URL for each checkin
+	for i := range checkins {
+		cs.setMediaURLs(ctx, checkins[i].Media)
 	}
 
 	return checkins, nil
 }
+
+// GetCheckinsByTag get local and remote checkins with hashtag, filtered like the global feed
+func (cs *CheckinServiceImplement) GetCheckinsByTag(ctx context.Context, viewerID uuid.UUID, tag string, page, pageSize int) ([]models.Checkin, error) {
+	// calculate offset
+	offset := (page - 1) * pageSize
+
+	checkins, err := cs.checkinRepo.GetCheckinsByTag(ctx, viewerID, activitypub.NormalizeTag(tag), pageSize, offset)
+	if err != nil {
+		return nil, fmt.Errorf("fail to get checkins by tag: %w", err)
+	}
+
+	// generate media file URL for each checkin
+	for i := range checkins {
+		cs.setMediaURLs(ctx, checkins[i].Media)
+	}
+
+	return checkins, nil
+}
+
+// CountLocalCheckins
+func (cs *CheckinServiceImplement) CountLocalCheckins(ctx context.Context) (int, error) {
+	return cs.checkinRepo.CountLocalCheckins(ctx)
+}
+
+// UpdateCheckin edit user's checkin, previous version is kept in edit history
+// hashtags and mentions are parsed again when content changes
+func (cs *CheckinServiceImplement) UpdateCheckin(ctx context.Context, userID, id uuid.UUID, update CheckinUpdate) (*models.Checkin, error) {
+	checkin, err := cs.checkinRepo.GetCheckinByID(ctx, id)
+	if err != nil {
+		return nil, ErrCheckinNotFound
+	}
+
+	if checkin.IsRemote || checkin.UserID != userID {
+		return nil, ErrNotCheckinOwner
+	}
+
+	if update.Content != nil {
+		checkin.Content = *update.Content
+		checkin.Tags = activitypub.ParseHashtags(checkin.Content)
+		checkin.Mentions = cs.apServerService.ResolveMentions(ctx, checkin.Content)
+	}
+	if update.LocationName != nil {
+		checkin.LocationName = *update.LocationName
+	}
+	if update.Latitude != nil {
+		checkin.Latitude = *update.Latitude
+	}
+	if update.Longitude != nil {
+		checkin.Longitude = *update.Longitude
+	}
+	checkin.UpdatedAt = time.Now().UTC()
+
+	// media are replaced in the same transaction as the edit, an empty list removes all of them
+	var mediaIDs []uuid.UUID
+	if update.MediaIDs != nil {
+		mediaIDs = append([]uuid.UUID{}, *update.MediaIDs...)
+	}
+
+	removed, err := cs.checkinRepo.UpdateCheckin(ctx, checkin, mediaIDs)
+	if errors.Is(err, models.ErrMediaNotAttachable) {
+		return nil, ErrInvalidMedia
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// a file left in bucket doesn't fail the edit
+	for _, media := range removed {
+		_ = cs.minioService.DeleteFile(ctx, media.FilePath)
+	}
+
+	// get full checkin data
+	return cs.GetCheckinByID(ctx, checkin.ID)
+}
+
+// GetCheckinEdits get previous versions of a checkin, newest first
+func (cs *CheckinServiceImplement) GetCheckinEdits(ctx context.Context, id uuid.UUID) ([]models.CheckinEdit, error) {
+	return cs.checkinRepo.GetCheckinEdits(ctx, id)
+}
+
+// DeleteCheckin delete user's checkin and its media files, a tombstone is left at its object ID
+// deleted checkin is returned to publish Delete
+func (cs *CheckinServiceImplement) DeleteCheckin(ctx context.Context, userID, id uuid.UUID, baseURL string) (*models.Checkin, error) {
+	checkin, err := cs.checkinRepo.GetCheckinByID(ctx, id)
+	if err != nil {
+		return nil, ErrCheckinNotFound
+	}
+
+	if checkin.IsRemote || checkin.UserID != userID {
+		return nil, ErrNotCheckinOwner
+	}
+
+	err = cs.checkinRepo.DeleteCheckin(ctx, checkin.ID, activitypub.CheckinObjectID(baseURL, checkin.ID), activitypub.ObjectTypeNote)
+	if err != nil {
+		return nil, err
+	}
+
+	// delete media files, a file left in bucket doesn't block deletion
+	for _, media := range checkin.Media {
+		if media.FilePath == "" {
+			continue
+		}
+
+		_ = cs.minioService.DeleteFile(ctx, media.FilePath)
+	}
+
+	return checkin, nil
+}
+
+// GetTombstone
+func (cs *CheckinServiceImplement) GetTombstone(ctx context.Context, objectID string) (*models.Tombstone, error) {
+	return cs.checkinRepo.GetTombstone(ctx, objectID)
+}
+
+// setMediaURLs generate URL of media files, media of remote checkins keeps its remote URL
+func (cs *CheckinServiceImplement) setMediaURLs(ctx context.Context, media []models.Media) {
+	for i := range media {
+		if media[i].RemoteURL != "" {
+			media[i].URL = media[i].RemoteURL
+			continue
+		}
+
+		url, err := cs.minioService.GetFileURL(ctx, media[i].FilePath)
+		if err == nil {
+			media[i].URL = url
+		}
+	}
+}
@@ -0,0 +1,301 @@
+package services
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"je-suis-ici-activitypub/internal/activitypub"
+	"je-suis-ici-activitypub/internal/db/models"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrNotAdmin user isn't an admin of this server
+	ErrNotAdmin = errors.New("only admins can manage domain blocks")
+	// ErrInvalidDomain domain isn't a host name, or it's this server's domain
+	ErrInvalidDomain = errors.New("invalid domain")
+	// ErrInvalidSeverity severity isn't "suspend" or "silence"
+	ErrInvalidSeverity = errors.New("severity must be suspend or silence")
+	// ErrInvalidDomainBlockCSV imported file isn't a domain block list
+	ErrInvalidDomainBlockCSV = errors.New("invalid domain block csv")
+)
+
+// domainBlockCSVHeader columns of Mastodon's domain block export
+var domainBlockCSVHeader = []string{"#domain", "#severity", "#reject_media", "#reject_reports", "#public_comment", "#obfuscate"}
+
+// domainPattern host name made of dot separated labels
+var domainPattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]*[a-z0-9])?(\.[a-z0-9]([a-z0-9-]*[a-z0-9])?)*$`)
+
+// DomainBlockService let admins suspend or silence remote domains
+type DomainBlockService interface {
+	GetDomainBlocks(ctx context.Context, userID uuid.UUID) ([]models.DomainBlock, error)
+	BlockDomain(ctx context.Context, userID uuid.UUID, block *models.DomainBlock) error
+	UnblockDomain(ctx context.Context, userID uuid.UUID, domain string) error
+	ImportDomainBlocks(ctx context.Context, userID uuid.UUID, r io.Reader) (int, error)
+	ExportDomainBlocks(ctx context.Context, userID uuid.UUID, w io.Writer) error
+}
+
+// DomainBlockServiceImplement
+type DomainBlockServiceImplement struct {
+	userRepo        models.UserRepository
+	domainBlockRepo models.DomainBlockRepository
+	apServerService *activitypub.ActivityPubServerService
+	serverHost      string
+}
+
+// NewDomainBlockService
+func NewDomainBlockService(userRepo models.UserRepository, domainBlockRepo models.DomainBlockRepository, apServerService *activitypub.ActivityPubServerService, serverHost string) DomainBlockService {
+	return &DomainBlockServiceImplement{
+		userRepo:        userRepo,
+		domainBlockRepo: domainBlockRepo,
+		apServerService: apServerService,
+		serverHost:      serverHost,
+	}
+}
+
+// GetDomainBlocks
+func (dbs *DomainBlockServiceImplement) GetDomainBlocks(ctx context.Context, userID uuid.UUID) ([]models.DomainBlock, error) {
+	err := dbs.requireAdmin(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return dbs.domainBlockRepo.GetDomainBlocks(ctx)
+}
+
+// BlockDomain add or replace block of a domain and its subdomains, "*.example.com" is the same as "example.com"
+// follow relations with actors of a suspended domain are removed
+func (dbs *DomainBlockServiceImplement) BlockDomain(ctx context.Context, userID uuid.UUID, block *models.DomainBlock) error {
+	err := dbs.requireAdmin(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	err = dbs.normalizeDomainBlock(block)
+	if err != nil {
+		return err
+	}
+
+	return dbs.applyDomainBlock(ctx, block)
+}
+
+// UnblockDomain
+func (dbs *DomainBlockServiceImplement) UnblockDomain(ctx context.Context, userID uuid.UUID, domain string) error {
+	err := dbs.requireAdmin(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	domain, err = dbs.normalizeDomain(domain)
+	if err != nil {
+		return err
+	}
+
+	return dbs.domainBlockRepo.DeleteDomainBlock(ctx, domain)
+}
+
+// ImportDomainBlocks add or replace blocks listed in a Mastodon domain block export
+// rows are "#domain,#severity,#reject_media,#reject_reports,#public_comment,#obfuscate", the header is optional
+// and a row may only have a domain, it's suspended then. "noop" rows are skipped
+// the whole file is checked before any block is stored, return number of stored blocks
+func (dbs *DomainBlockServiceImplement) ImportDomainBlocks(ctx context.Context, userID uuid.UUID, r io.Reader) (int, error) {
+	err := dbs.requireAdmin(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return 0, fmt.Errorf("%w: %w", ErrInvalidDomainBlockCSV, err)
+	}
+
+	// column of each field, header may list them in another order
+	columns := map[string]int{}
+	for i, name := range domainBlockCSVHeader {
+		columns[name] = i
+	}
+	if len(records) > 0 && len(records[0]) > 0 && strings.HasPrefix(records[0][0], "#") {
+		columns = map[string]int{}
+		for i, name := range records[0] {
+			columns[strings.TrimSpace(name)] = i
+		}
+		records = records[1:]
+
+		if _, ok := columns["#domain"]; !ok {
+			return 0, fmt.Errorf("%w: missing #domain column", ErrInvalidDomainBlockCSV)
+		}
+	}
+
+	var blocks []*models.DomainBlock
+	for i, record := range records {
+		field := func(name string) string {
+			column, ok := columns[name]
+			if !ok || column >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[column])
+		}
+
+		if field("#domain") == "" || field("#severity") == "noop" {
+			continue
+		}
+
+		block := &models.DomainBlock{
+			Domain:        field("#domain"),
+			Severity:      field("#severity"),
+			PublicComment: field("#public_comment"),
+		}
+
+		flags := map[string]*bool{
+			"#reject_media":   &block.RejectMedia,
+			"#reject_reports": &block.RejectReports,
+			"#obfuscate":      &block.Obfuscate,
+		}
+		for name, flag := range flags {
+			value := field(name)
+			if value == "" {
+				continue
+			}
+
+			*flag, err = strconv.ParseBool(value)
+			if err != nil {
+				return 0, fmt.Errorf("%w: row %d: %s is not true or false", ErrInvalidDomainBlockCSV, i+1, name)
+			}
+		}
+
+		err = dbs.normalizeDomainBlock(block)
+		if err != nil {
+			return 0, fmt.Errorf("%w: row %d: %v", ErrInvalidDomainBlockCSV, i+1, err)
+		}
+
+		blocks = append(blocks, block)
+	}
+
+	for i, block := range blocks {
+		err := dbs.applyDomainBlock(ctx, block)
+		if err != nil {
+			return i, err
+		}
+	}
+
+	return len(blocks), nil
+}
+
+// ExportDomainBlocks write all blocks in Mastodon's domain block export format
+func (dbs *DomainBlockServiceImplement) ExportDomainBlocks(ctx context.Context, userID uuid.UUID, w io.Writer) error {
+	blocks, err := dbs.GetDomainBlocks(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	writer := csv.NewWriter(w)
+
+	err = writer.Write(domainBlockCSVHeader)
+	if err != nil {
+		return fmt.Errorf("fail to write domain block csv: %w", err)
+	}
+
+	for _, block := range blocks {
+		err := writer.Write([]string{
+			block.Domain,
+			block.Severity,
+			strconv.FormatBool(block.RejectMedia),
+			strconv.FormatBool(block.RejectReports),
+			block.PublicComment,
+			strconv.FormatBool(block.Obfuscate),
+		})
+		if err != nil {
+			return fmt.Errorf("fail to write domain block csv: %w", err)
+		}
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("fail to write domain block csv: %w", err)
+	}
+
+	return nil
+}
+
+// applyDomainBlock store block, follow relations with a suspended domain are removed
+func (dbs *DomainBlockServiceImplement) applyDomainBlock(ctx context.Context, block *models.DomainBlock) error {
+	err := dbs.domainBlockRepo.UpsertDomainBlock(ctx, block)
+	if err != nil {
+		return err
+	}
+
+	if block.Severity == models.DomainBlockSeveritySuspend {
+		err = dbs.apServerService.PurgeDomain(ctx, block.Domain)
+		if err != nil {
+			return fmt.Errorf("domain is blocked but fail to remove its follows: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// requireAdmin return ErrNotAdmin when user isn't an admin
+func (dbs *DomainBlockServiceImplement) requireAdmin(ctx context.Context, userID uuid.UUID) error {
+	user, err := dbs.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if !user.IsAdmin {
+		return ErrNotAdmin
+	}
+
+	return nil
+}
+
+// normalizeDomainBlock normalize domain and default severity to suspend
+func (dbs *DomainBlockServiceImplement) normalizeDomainBlock(block *models.DomainBlock) error {
+	domain, err := dbs.normalizeDomain(block.Domain)
+	if err != nil {
+		return err
+	}
+	block.Domain = domain
+
+	block.Severity = strings.ToLower(strings.TrimSpace(block.Severity))
+	if block.Severity == "" {
+		block.Severity = models.DomainBlockSeveritySuspend
+	}
+	if block.Severity != models.DomainBlockSeveritySuspend && block.Severity != models.DomainBlockSeveritySilence {
+		return ErrInvalidSeverity
+	}
+
+	return nil
+}
+
+// normalizeDomain lowercase domain and drop "*." wildcard prefix
+// this server's domain and its parent domains can't be blocked
+func (dbs *DomainBlockServiceImplement) normalizeDomain(domain string) (string, error) {
+	domain = strings.ToLower(strings.TrimSpace(domain))
+	domain = strings.TrimPrefix(domain, "*.")
+	domain = strings.TrimSuffix(domain, ".")
+
+	if !domainPattern.MatchString(domain) {
+		return "", fmt.Errorf("%w: %q", ErrInvalidDomain, domain)
+	}
+
+	serverDomain := strings.ToLower(dbs.serverHost)
+	if host, _, err := net.SplitHostPort(serverDomain); err == nil {
+		serverDomain = host
+	}
+	if serverDomain == domain || strings.HasSuffix(serverDomain, "."+domain) {
+		return "", fmt.Errorf("%w: %s is this server's domain", ErrInvalidDomain, domain)
+	}
+
+	return domain, nil
+}
@@ -0,0 +1,169 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"je-suis-ici-activitypub/internal/activitypub"
+	"je-suis-ici-activitypub/internal/db/models"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrNotFollowing user doesn't follow the account
+var ErrNotFollowing = errors.New("not following this account")
+
+// FollowService let local users follow accounts on other servers
+type FollowService interface {
+	Follow(ctx context.Context, userID uuid.UUID, account string) (*activitypub.Following, error)
+	Unfollow(ctx context.Context, userID uuid.UUID, account string) error
+	ListFollowing(ctx context.Context, userID uuid.UUID) ([]activitypub.Following, error)
+}
+
+// FollowServiceImplement
+type FollowServiceImplement struct {
+	userRepo      models.UserRepository
+	followingRepo activitypub.FollowingRepository
+	deliveryRepo  activitypub.DeliveryRepository
+	clientService activitypub.ActivityPubClientService
+	baseURL       string
+}
+
+// NewFollowService
+func NewFollowService(userRepo models.UserRepository, followingRepo activitypub.FollowingRepository, deliveryRepo activitypub.DeliveryRepository, clientService activitypub.ActivityPubClientService, baseURL string) FollowService {
+	return &FollowServiceImplement{
+		userRepo:      userRepo,
+		followingRepo: followingRepo,
+		deliveryRepo:  deliveryRepo,
+		clientService: clientService,
+		baseURL:       baseURL,
+	}
+}
+
+// Follow resolve account with WebFinger, send Follow and record a pending follow
+// account is "user@host" or an actor URL
+func (fs *FollowServiceImplement) Follow(ctx context.Context, userID uuid.UUID, account string) (*activitypub.Following, error) {
+	user, err := fs.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// get remote actor
+	target, err := resolveActor(ctx, fs.clientService, account)
+	if err != nil {
+		return nil, err
+	}
+
+	if target.ID == user.ActorID {
+		return nil, fmt.Errorf("user can't follow himself")
+	}
+	if target.Inbox == "" {
+		return nil, fmt.Errorf("actor doesn't have an inbox")
+	}
+
+	// already followed, no need to ask again
+	existing, err := fs.followingRepo.GetFollowingByTarget(ctx, userID, target.ID)
+	if err == nil && existing.Status == activitypub.FollowingStatusAccepted {
+		return existing, nil
+	}
+
+	// record pending follow before queueing, Accept may arrive right after delivery
+	follow := &activitypub.Activity{
+		Context:   activitypub.DefaultContext(),
+		ID:        activitypub.NewActivityID(fs.baseURL),
+		Type:      activitypub.ActivityTypeFollow,
+		Actor:     activitypub.IRIProperty(user.ActorID),
+		Object:    activitypub.IRIProperty(target.ID),
+		To:        []string{target.ID},
+		Published: time.Now().UTC(),
+	}
+
+	following, err := fs.followingRepo.AddFollowing(ctx, userID, target.ID, target.Inbox, follow.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	// queue Follow
+	err = activitypub.EnqueueActivity(ctx, fs.deliveryRepo, follow, user, target.Inbox)
+	if err != nil {
+		return nil, fmt.Errorf("fail to send follow: %w", err)
+	}
+
+	return following, nil
+}
+
+// Unfollow send Undo{Follow} and remove the follow
+func (fs *FollowServiceImplement) Unfollow(ctx context.Context, userID uuid.UUID, account string) error {
+	user, err := fs.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	// get followed actor ID
+	targetActorID := account
+	if !isActorURL(account) {
+		target, err := fs.clientService.ResolveAccount(ctx, account)
+		if err != nil {
+			return fmt.Errorf("fail to resolve account: %w", err)
+		}
+		targetActorID = target.ID
+	}
+
+	following, err := fs.followingRepo.GetFollowingByTarget(ctx, userID, targetActorID)
+	if err != nil {
+		return ErrNotFollowing
+	}
+
+	// Undo refers to the Follow we sent
+	undo := &activitypub.Activity{
+		Context: activitypub.DefaultContext(),
+		ID:      activitypub.NewActivityID(fs.baseURL),
+		Type:    activitypub.ActivityTypeUndo,
+		Actor:   activitypub.IRIProperty(user.ActorID),
+		Object: activitypub.ObjectProperty(map[string]interface{}{
+			"id":     following.ActivityID,
+			"type":   activitypub.ActivityTypeFollow,
+			"actor":  user.ActorID,
+			"object": following.TargetActorID,
+		}),
+		To:        []string{following.TargetActorID},
+		Published: time.Now().UTC(),
+	}
+
+	err = activitypub.EnqueueActivity(ctx, fs.deliveryRepo, undo, user, following.TargetInbox)
+	if err != nil {
+		return fmt.Errorf("fail to send undo follow: %w", err)
+	}
+
+	return fs.followingRepo.RemoveFollowing(ctx, userID, following.TargetActorID)
+}
+
+// ListFollowing list user's follows, including pending and rejected ones
+func (fs *FollowServiceImplement) ListFollowing(ctx context.Context, userID uuid.UUID) ([]activitypub.Following, error) {
+	return fs.followingRepo.ListFollowing(ctx, userID)
+}
+
+// resolveActor get actor of "user@host" account or actor URL
+func resolveActor(ctx context.Context, clientService activitypub.ActivityPubClientService, account string) (*activitypub.Person, error) {
+	if isActorURL(account) {
+		actor, err := clientService.FetchActorPublicInformation(ctx, account)
+		if err != nil {
+			return nil, fmt.Errorf("fail to get actor: %w", err)
+		}
+		return actor, nil
+	}
+
+	actor, err := clientService.ResolveAccount(ctx, account)
+	if err != nil {
+		return nil, fmt.Errorf("fail to resolve account: %w", err)
+	}
+
+	return actor, nil
+}
+
+// isActorURL check if account is given as actor URL instead of "user@host"
+func isActorURL(account string) bool {
+	return strings.HasPrefix(account, "https://") || strings.HasPrefix(account, "http://")
+}
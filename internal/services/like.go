@@ -0,0 +1,169 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"je-suis-ici-activitypub/internal/activitypub"
+	"je-suis-ici-activitypub/internal/db/models"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrCheckinNotFound checkin doesn't exist
+var ErrCheckinNotFound = errors.New("checkin not found")
+
+// ErrNotLiked user doesn't like the checkin
+var ErrNotLiked = errors.New("checkin is not liked")
+
+// LikeService let local users like local and remote checkins
+type LikeService interface {
+	Like(ctx context.Context, userID, checkinID uuid.UUID) (*models.Like, error)
+	Unlike(ctx context.Context, userID, checkinID uuid.UUID) error
+}
+
+// LikeServiceImplement
+type LikeServiceImplement struct {
+	userRepo        models.UserRepository
+	checkinRepo     models.CheckinRepository
+	likeRepo        models.LikeRepository
+	remoteActorRepo models.RemoteActorRepository
+	deliveryRepo    activitypub.DeliveryRepository
+	baseURL         string
+}
+
+// NewLikeService
+func NewLikeService(userRepo models.UserRepository, checkinRepo models.CheckinRepository, likeRepo models.LikeRepository, remoteActorRepo models.RemoteActorRepository, deliveryRepo activitypub.DeliveryRepository, baseURL string) LikeService {
+	return &LikeServiceImplement{
+		userRepo:        userRepo,
+		checkinRepo:     checkinRepo,
+		likeRepo:        likeRepo,
+		remoteActorRepo: remoteActorRepo,
+		deliveryRepo:    deliveryRepo,
+		baseURL:         baseURL,
+	}
+}
+
+// Like record user's like on checkin, Like is sent to author when checkin is remote
+// liking a checkin twice returns the first like
+func (ls *LikeServiceImplement) Like(ctx context.Context, userID, checkinID uuid.UUID) (*models.Like, error) {
+	user, err := ls.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	checkin, err := ls.checkinRepo.GetCheckinByID(ctx, checkinID)
+	if err != nil {
+		return nil, ErrCheckinNotFound
+	}
+
+	existing, err := ls.likeRepo.GetLike(ctx, checkin.ID, user.ActorID)
+	if err == nil {
+		return existing, nil
+	}
+
+	like := &models.Like{
+		CheckinID:  checkin.ID,
+		UserID:     user.ID,
+		ActorID:    user.ActorID,
+		ObjectID:   ls.checkinObjectID(checkin),
+		ActivityID: activitypub.NewActivityID(ls.baseURL),
+	}
+
+	err = ls.likeRepo.AddLike(ctx, like)
+	if err != nil {
+		return nil, err
+	}
+
+	// local author reads the like from database
+	if !checkin.IsRemote {
+		return like, nil
+	}
+
+	activity := &activitypub.Activity{
+		Context:   activitypub.DefaultContext(),
+		ID:        like.ActivityID,
+		Type:      activitypub.ActivityTypeLike,
+		Actor:     activitypub.IRIProperty(user.ActorID),
+		Object:    activitypub.IRIProperty(like.ObjectID),
+		To:        []string{checkin.User.ActorID},
+		Published: like.CreatedAt.UTC(),
+	}
+
+	err = ls.sendToAuthor(ctx, activity, user, checkin)
+	if err != nil {
+		return nil, fmt.Errorf("fail to send like: %w", err)
+	}
+
+	return like, nil
+}
+
+// Unlike remove user's like on checkin, Undo{Like} is sent to author when checkin is remote
+func (ls *LikeServiceImplement) Unlike(ctx context.Context, userID, checkinID uuid.UUID) error {
+	user, err := ls.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	checkin, err := ls.checkinRepo.GetCheckinByID(ctx, checkinID)
+	if err != nil {
+		return ErrCheckinNotFound
+	}
+
+	like, err := ls.likeRepo.GetLike(ctx, checkin.ID, user.ActorID)
+	if err != nil {
+		return ErrNotLiked
+	}
+
+	err = ls.likeRepo.RemoveLike(ctx, checkin.ID, user.ActorID)
+	if err != nil {
+		return err
+	}
+
+	if !checkin.IsRemote {
+		return nil
+	}
+
+	// Undo refers to the Like we sent
+	undo := &activitypub.Activity{
+		Context: activitypub.DefaultContext(),
+		ID:      activitypub.NewActivityID(ls.baseURL),
+		Type:    activitypub.ActivityTypeUndo,
+		Actor:   activitypub.IRIProperty(user.ActorID),
+		Object: activitypub.ObjectProperty(map[string]interface{}{
+			"id":     like.ActivityID,
+			"type":   activitypub.ActivityTypeLike,
+			"actor":  user.ActorID,
+			"object": like.ObjectID,
+		}),
+		To:        []string{checkin.User.ActorID},
+		Published: time.Now().UTC(),
+	}
+
+	err = ls.sendToAuthor(ctx, undo, user, checkin)
+	if err != nil {
+		return fmt.Errorf("fail to send undo like: %w", err)
+	}
+
+	return nil
+}
+
+// sendToAuthor queue activity to inbox of remote checkin's author
+func (ls *LikeServiceImplement) sendToAuthor(ctx context.Context, activity *activitypub.Activity, sender *models.User, checkin *models.Checkin) error {
+	author, err := ls.remoteActorRepo.GetByActorID(ctx, checkin.User.ActorID)
+	if err != nil {
+		return err
+	}
+
+	return activitypub.EnqueueActivity(ctx, ls.deliveryRepo, activity, sender, author.Inbox)
+}
+
+// checkinObjectID get Note ID of local or remote checkin
+func (ls *LikeServiceImplement) checkinObjectID(checkin *models.Checkin) string {
+	if checkin.IsRemote {
+		return checkin.ObjectID
+	}
+
+	return activitypub.CheckinObjectID(ls.baseURL, checkin.ID)
+}
@@ -10,7 +10,7 @@ import (
 
 // MediaService
 type MediaService interface {
-	UploadMedia(ctx context.Context, data []byte, fileType, contentType string) (*models.Media, error)
+	UploadMedia(ctx context.Context, userID uuid.UUID, data []byte, fileType, contentType string) (*models.Media, error)
 	GetMediaByID(ctx context.Context, id uuid.UUID) (*models.Media, error)
 }
 
@@ -31,7 +31,7 @@ func NewMediaService(mediaRepo models.MediaRepository, minioService storage.Mini
 // UploadMedia
 // upload media file then store file name and related information to media table
 // return media data including media file URL
-func (ms *MediaServiceImplement) UploadMedia(ctx context.Context, fileData []byte, fileType, contentType string) (*models.Media, error) {
+func (ms *MediaServiceImplement) UploadMedia(ctx context.Context, userID uuid.UUID, fileData []byte, fileType, contentType string) (*models.Media, error) {
 	// upload media file to minio
 	filePath, err := ms.minioService.UploadFile(ctx, fileData, fileType, contentType)
 	if err != nil {
@@ -41,6 +41,7 @@ func (ms *MediaServiceImplement) UploadMedia(ctx context.Context, fileData []byt
 	// build media model
 	media := &models.Media{
 		// initially CheckinID is nil, it will be filled after checkin data is created
+		UserID:   userID,
 		FilePath: filePath,
 		FileType: fileType,
 		FileSize: len(fileData),
@@ -0,0 +1,135 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"je-suis-ici-activitypub/internal/activitypub"
+	"je-suis-ici-activitypub/internal/db/models"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrInvalidAlias alias is user's own account
+	ErrInvalidAlias = errors.New("account can't be an alias of itself")
+	// ErrMoveTargetNotAliased target account doesn't list user's actor in its alsoKnownAs
+	ErrMoveTargetNotAliased = errors.New("target account must list this account in its aliases")
+)
+
+// MoveService let local users declare their other accounts and move to another account
+// https://docs.joinmastodon.org/spec/activitypub/#Move
+type MoveService interface {
+	SetAliases(ctx context.Context, userID uuid.UUID, accounts []string) (*models.User, error)
+	Move(ctx context.Context, userID uuid.UUID, account string) (*models.User, error)
+}
+
+// MoveServiceImplement
+type MoveServiceImplement struct {
+	userRepo        models.UserRepository
+	clientService   activitypub.ActivityPubClientService
+	apServerService *activitypub.ActivityPubServerService
+	baseURL         string
+}
+
+// NewMoveService
+func NewMoveService(userRepo models.UserRepository, clientService activitypub.ActivityPubClientService, apServerService *activitypub.ActivityPubServerService, baseURL string) MoveService {
+	return &MoveServiceImplement{
+		userRepo:        userRepo,
+		clientService:   clientService,
+		apServerService: apServerService,
+		baseURL:         baseURL,
+	}
+}
+
+// SetAliases replace user's alsoKnownAs with actors of accounts and send Update{Person} to followers
+// account is "user@host" or an actor URL, an empty list removes all aliases
+func (ms *MoveServiceImplement) SetAliases(ctx context.Context, userID uuid.UUID, accounts []string) (*models.User, error) {
+	user, err := ms.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	aliases := []string{}
+	for _, account := range accounts {
+		actor, err := resolveActor(ctx, ms.clientService, account)
+		if err != nil {
+			return nil, err
+		}
+
+		if actor.ID == user.ActorID {
+			return nil, ErrInvalidAlias
+		}
+		if !slices.Contains(aliases, actor.ID) {
+			aliases = append(aliases, actor.ID)
+		}
+	}
+
+	user.AlsoKnownAs = aliases
+
+	err = ms.userRepo.UpdateUser(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+
+	err = ms.apServerService.PublishActorUpdate(ctx, user)
+	if err != nil {
+		return nil, fmt.Errorf("fail to publish aliases: %w", err)
+	}
+
+	return user, nil
+}
+
+// Move point user's actor to the target account and send Move to followers, so they follow the target
+// target account must list user's actor in its alsoKnownAs first
+func (ms *MoveServiceImplement) Move(ctx context.Context, userID uuid.UUID, account string) (*models.User, error) {
+	user, err := ms.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	target, err := resolveActor(ctx, ms.clientService, account)
+	if err != nil {
+		return nil, err
+	}
+
+	if target.ID == user.ActorID {
+		return nil, ErrInvalidAlias
+	}
+	if !slices.Contains(target.AlsoKnownAs, user.ActorID) {
+		return nil, ErrMoveTargetNotAliased
+	}
+
+	user.MovedTo = target.ID
+
+	err = ms.userRepo.UpdateUser(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+
+	// actor document with movedTo first, receivers check it when handling Move
+	err = ms.apServerService.PublishActorUpdate(ctx, user)
+	if err != nil {
+		return nil, fmt.Errorf("fail to publish move: %w", err)
+	}
+
+	move := &activitypub.Activity{
+		Context:   activitypub.DefaultContext(),
+		ID:        activitypub.NewActivityID(ms.baseURL),
+		Type:      activitypub.ActivityTypeMove,
+		Actor:     activitypub.IRIProperty(user.ActorID),
+		Object:    activitypub.IRIProperty(user.ActorID),
+		Target:    activitypub.IRIProperty(target.ID),
+		To:        []string{fmt.Sprintf("%s/followers", user.ActorID)},
+		Published: time.Now().UTC(),
+	}
+
+	err = ms.apServerService.SendToFollowers(ctx, move, user)
+	if err != nil {
+		return nil, fmt.Errorf("fail to send move: %w", err)
+	}
+
+	return user, nil
+}
@@ -0,0 +1,45 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"je-suis-ici-activitypub/internal/db/models"
+
+	"github.com/google/uuid"
+)
+
+// NotificationService let local users read notifications, e.g. mentions in local and remote checkins
+type NotificationService interface {
+	GetNotifications(ctx context.Context, userID uuid.UUID, page, pageSize int) ([]models.Notification, error)
+	MarkNotificationsRead(ctx context.Context, userID uuid.UUID) error
+}
+
+// NotificationServiceImplement
+type NotificationServiceImplement struct {
+	notificationRepo models.NotificationRepository
+}
+
+// NewNotificationService
+func NewNotificationService(notificationRepo models.NotificationRepository) NotificationService {
+	return &NotificationServiceImplement{
+		notificationRepo: notificationRepo,
+	}
+}
+
+// GetNotifications get user's notifications, newest first
+func (ns *NotificationServiceImplement) GetNotifications(ctx context.Context, userID uuid.UUID, page, pageSize int) ([]models.Notification, error) {
+	// calculate offset
+	offset := (page - 1) * pageSize
+
+	notifications, err := ns.notificationRepo.GetNotifications(ctx, userID, pageSize, offset)
+	if err != nil {
+		return nil, fmt.Errorf("fail to get notifications: %w", err)
+	}
+
+	return notifications, nil
+}
+
+// MarkNotificationsRead mark all user's notifications as read
+func (ns *NotificationServiceImplement) MarkNotificationsRead(ctx context.Context, userID uuid.UUID) error {
+	return ns.notificationRepo.MarkNotificationsRead(ctx, userID)
+}
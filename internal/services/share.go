@@ -0,0 +1,165 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"je-suis-ici-activitypub/internal/activitypub"
+	"je-suis-ici-activitypub/internal/db/models"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrNotBoosted user doesn't boost the checkin
+var ErrNotBoosted = errors.New("checkin is not boosted")
+
+// ShareService let local users boost local and remote checkins to their followers
+type ShareService interface {
+	Boost(ctx context.Context, userID, checkinID uuid.UUID) (*models.Share, error)
+	Unboost(ctx context.Context, userID, checkinID uuid.UUID) error
+}
+
+// ShareServiceImplement
+type ShareServiceImplement struct {
+	userRepo        models.UserRepository
+	checkinRepo     models.CheckinRepository
+	shareRepo       models.ShareRepository
+	remoteActorRepo models.RemoteActorRepository
+	apServerService *activitypub.ActivityPubServerService
+	baseURL         string
+}
+
+// NewShareService
+func NewShareService(userRepo models.UserRepository, checkinRepo models.CheckinRepository, shareRepo models.ShareRepository, remoteActorRepo models.RemoteActorRepository, apServerService *activitypub.ActivityPubServerService, baseURL string) ShareService {
+	return &ShareServiceImplement{
+		userRepo:        userRepo,
+		checkinRepo:     checkinRepo,
+		shareRepo:       shareRepo,
+		remoteActorRepo: remoteActorRepo,
+		apServerService: apServerService,
+		baseURL:         baseURL,
+	}
+}
+
+// Boost record user's boost on checkin and send Announce to user's followers and remote author
+// boosting a checkin twice returns the first boost
+func (ss *ShareServiceImplement) Boost(ctx context.Context, userID, checkinID uuid.UUID) (*models.Share, error) {
+	user, err := ss.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	checkin, err := ss.checkinRepo.GetCheckinByID(ctx, checkinID)
+	if err != nil {
+		return nil, ErrCheckinNotFound
+	}
+
+	existing, err := ss.shareRepo.GetShare(ctx, checkin.ID, user.ActorID)
+	if err == nil {
+		return existing, nil
+	}
+
+	share := &models.Share{
+		CheckinID:  checkin.ID,
+		UserID:     user.ID,
+		ActorID:    user.ActorID,
+		ObjectID:   ss.checkinObjectID(checkin),
+		ActivityID: activitypub.NewActivityID(ss.baseURL),
+	}
+
+	err = ss.shareRepo.AddShare(ctx, share)
+	if err != nil {
+		return nil, err
+	}
+
+	announce := &activitypub.Activity{
+		Context:   activitypub.DefaultContext(),
+		ID:        share.ActivityID,
+		Type:      activitypub.ActivityTypeAnnounce,
+		Actor:     activitypub.IRIProperty(user.ActorID),
+		Object:    activitypub.IRIProperty(share.ObjectID),
+		To:        []string{activitypub.PublicAddress},
+		Cc:        []string{checkin.User.ActorID, fmt.Sprintf("%s/followers", user.ActorID)},
+		Published: share.CreatedAt.UTC(),
+	}
+
+	err = ss.send(ctx, announce, user, checkin)
+	if err != nil {
+		return nil, fmt.Errorf("fail to send announce: %w", err)
+	}
+
+	return share, nil
+}
+
+// Unboost remove user's boost on checkin and send Undo{Announce} to the same audience
+func (ss *ShareServiceImplement) Unboost(ctx context.Context, userID, checkinID uuid.UUID) error {
+	user, err := ss.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	checkin, err := ss.checkinRepo.GetCheckinByID(ctx, checkinID)
+	if err != nil {
+		return ErrCheckinNotFound
+	}
+
+	share, err := ss.shareRepo.GetShare(ctx, checkin.ID, user.ActorID)
+	if err != nil {
+		return ErrNotBoosted
+	}
+
+	err = ss.shareRepo.RemoveShare(ctx, checkin.ID, user.ActorID)
+	if err != nil {
+		return err
+	}
+
+	// Undo refers to the Announce we sent
+	undo := &activitypub.Activity{
+		Context: activitypub.DefaultContext(),
+		ID:      activitypub.NewActivityID(ss.baseURL),
+		Type:    activitypub.ActivityTypeUndo,
+		Actor:   activitypub.IRIProperty(user.ActorID),
+		Object: activitypub.ObjectProperty(map[string]interface{}{
+			"id":     share.ActivityID,
+			"type":   activitypub.ActivityTypeAnnounce,
+			"actor":  user.ActorID,
+			"object": share.ObjectID,
+		}),
+		To:        []string{activitypub.PublicAddress},
+		Cc:        []string{checkin.User.ActorID, fmt.Sprintf("%s/followers", user.ActorID)},
+		Published: time.Now().UTC(),
+	}
+
+	err = ss.send(ctx, undo, user, checkin)
+	if err != nil {
+		return fmt.Errorf("fail to send undo announce: %w", err)
+	}
+
+	return nil
+}
+
+// send queue activity to sender's followers, and to author's inbox when checkin is remote
+func (ss *ShareServiceImplement) send(ctx context.Context, activity *activitypub.Activity, sender *models.User, checkin *models.Checkin) error {
+	var inboxes []string
+
+	if checkin.IsRemote {
+		author, err := ss.remoteActorRepo.GetByActorID(ctx, checkin.User.ActorID)
+		if err != nil {
+			return err
+		}
+
+		inboxes = append(inboxes, author.Inbox)
+	}
+
+	return ss.apServerService.SendToFollowers(ctx, activity, sender, inboxes...)
+}
+
+// checkinObjectID get Note ID of local or remote checkin
+func (ss *ShareServiceImplement) checkinObjectID(checkin *models.Checkin) string {
+	if checkin.IsRemote {
+		return checkin.ObjectID
+	}
+
+	return activitypub.CheckinObjectID(ss.baseURL, checkin.ID)
+}
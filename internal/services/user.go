@@ -2,12 +2,14 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"je-suis-ici-activitypub/internal/activitypub"
 	"je-suis-ici-activitypub/internal/db/models"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
@@ -16,14 +18,24 @@ import (
 
 var tracer = otel.Tracer("services/user")
 
+var (
+	// ErrUsernameTaken username is used by another user, now or before renaming
+	ErrUsernameTaken = errors.New("username is taken")
+	// ErrInvalidUsername username can't be used, a UUID would shadow actor IDs
+	ErrInvalidUsername = errors.New("invalid username")
+)
+
 // UserService
 type UserService interface {
-	Register(ctx context.Context, serverHost, username, email, password string) (*models.User, error)
+	Register(ctx context.Context, baseURL, username, email, password string) (*models.User, error)
 	Authenticate(ctx context.Context, usernameOrEmail, password string) (*models.User, error)
 	GetUserByID(ctx context.Context, id uuid.UUID) (*models.User, error)
 	GetUserByUsername(ctx context.Context, username string) (*models.User, error)
+	GetUserByHandle(ctx context.Context, handle string) (*models.User, error)
 	UpdateUser(ctx context.Context, user *models.User) error
 	DeleteUser(ctx context.Context, id uuid.UUID) error
+	CountUsers(ctx context.Context) (int, error)
+	CountActiveUsers(ctx context.Context, since time.Time) (int, error)
 }
 
 // UserServiceImplement
@@ -41,7 +53,7 @@ func NewUserService(userRepo models.UserRepository, actorService activitypub.Act
 }
 
 // Register user register an account
-func (us *UserServiceImplement) Register(ctx context.Context, serverHost, username, email, password string) (*models.User, error) {
+func (us *UserServiceImplement) Register(ctx context.Context, baseURL, username, email, password string) (*models.User, error) {
 	// add child tracer
 	ctx, span := tracer.Start(ctx, "Register")
 	defer span.End()
@@ -64,8 +76,21 @@ func (us *UserServiceImplement) Register(ctx context.Context, serverHost, userna
 		return nil, fmt.Errorf("fail to hash password: %w", err)
 	}
 
-	// generate actorID
-	actorID := us.actorService.GenerateActorID(serverHost, username)
+	// check username
+	err = us.checkUsername(ctx, uuid.Nil, username)
+	if err != nil {
+		span.RecordError(err)
+		span.SetAttributes(
+			attribute.String("error.type", "check_username_error"),
+			attribute.String("error.message", err.Error()),
+		)
+
+		return nil, err
+	}
+
+	// generate actorID, it's built from user ID so it won't change with username
+	userID := uuid.New()
+	actorID := us.actorService.GenerateActorID(baseURL, userID)
 
 	// generate private and public key pair
 	privateKey, publicKey, err := us.actorService.GenerateKeyPair()
@@ -81,6 +106,7 @@ func (us *UserServiceImplement) Register(ctx context.Context, serverHost, userna
 
 	// build user model
 	user := &models.User{
+		ID:           userID,
 		Username:     username,
 		Email:        email,
 		PasswordHash: string(hashedPassword),
@@ -220,11 +246,60 @@ func (us *UserServiceImplement) UpdateUser(ctx context.Context, user *models.Use
 		return fmt.Errorf("invalid user input")
 	}
 
+	err := us.checkUsername(ctx, user.ID, user.Username)
+	if err != nil {
+		return err
+	}
+
 	user.UpdatedAt = time.Now()
 
 	return us.userRepo.UpdateUser(ctx, user)
 }
 
+// GetUserByHandle get user by handle in actor URL, a handle is user ID, username or previous username
+func (us *UserServiceImplement) GetUserByHandle(ctx context.Context, handle string) (*models.User, error) {
+	id, err := uuid.Parse(handle)
+	if err == nil {
+		return us.userRepo.GetByID(ctx, id)
+	}
+
+	// previous usernames are only looked up when no user has the username, not on database errors
+	user, err := us.userRepo.GetByUsername(ctx, handle)
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return user, err
+	}
+
+	return us.userRepo.GetByPreviousUsername(ctx, handle)
+}
+
+// checkUsername check if user can use username
+// usernames of other users, including their previous ones, are taken
+func (us *UserServiceImplement) checkUsername(ctx context.Context, userID uuid.UUID, username string) error {
+	_, err := uuid.Parse(username)
+	if err == nil {
+		return ErrInvalidUsername
+	}
+
+	// a database error doesn't mean username is free
+	user, err := us.userRepo.GetByUsername(ctx, username)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return err
+	}
+	if err == nil && user.ID != userID {
+		return ErrUsernameTaken
+	}
+
+	user, err = us.userRepo.GetByPreviousUsername(ctx, username)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return err
+	}
+	if err == nil && user.ID != userID {
+		return ErrUsernameTaken
+	}
+
+	return nil
+}
+
 // DeleteUser
 func (us *UserServiceImplement) DeleteUser(ctx context.Context, id uuid.UUID) error {
 	if id == uuid.Nil {
@@ -233,3 +308,13 @@ func (us *UserServiceImplement) DeleteUser(ctx context.Context, id uuid.UUID) er
 
 	return us.userRepo.DeleteUser(ctx, id)
 }
+
+// CountUsers
+func (us *UserServiceImplement) CountUsers(ctx context.Context) (int, error) {
+	return us.userRepo.CountUsers(ctx)
+}
+
+// CountActiveUsers count users who have posted since the given time
+func (us *UserServiceImplement) CountActiveUsers(ctx context.Context, since time.Time) (int, error) {
+	return us.userRepo.CountActiveUsers(ctx, since)
+}
@@ -29,6 +29,7 @@ type MinioConfig struct {
 type MinioService interface {
 	UploadFile(ctx context.Context, fileData []byte, fileType, contentType string) (string, error)
 	GetFileURL(ctx context.Context, fileName string) (string, error)
+	DeleteFile(ctx context.Context, filePath string) error
 }
 
 // MinioServiceImplement
@@ -138,3 +139,13 @@ func getExtension(contentType string) string {
 		return ".bin"
 	}
 }
+
+// DeleteFile remove file from bucket
+func (mis *MinioServiceImplement) DeleteFile(ctx context.Context, filePath string) error {
+	err := mis.client.RemoveObject(ctx, mis.bucket, filePath, minio.RemoveObjectOptions{})
+	if err != nil {
+		return fmt.Errorf("fail to delete file: %w", err)
+	}
+
+	return nil
+}